@@ -0,0 +1,10 @@
+// Package api holds the definitions of the calendar APIs: the gRPC service in EventService.proto
+// and the OpenAPI document of the HTTP API.
+package api
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 document of the HTTP API, see internal/server/http.
+//
+//go:embed openapi.json
+var OpenAPI []byte
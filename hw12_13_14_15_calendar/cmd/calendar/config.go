@@ -1,20 +1,151 @@
 package main
 
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+)
+
+// envPrefix prefixes environment variables overriding config keys, e.g. CALENDAR_STORAGE_DSN.
+const envPrefix = "CALENDAR"
+
 // При желании конфигурацию можно вынести в internal/config.
 // Организация конфига в main принуждает нас сужать API компонентов, использовать
 // при их конструировании только необходимые параметры, а также уменьшает вероятность циклической зависимости.
 type Config struct {
-	Logger LoggerConf
-	// TODO
+	Logger    LoggerConf    `toml:"logger"`
+	Storage   StorageConf   `toml:"storage"`
+	HTTP      HTTPConf      `toml:"http"`
+	GRPC      GRPCConf      `toml:"grpc"`
+	RateLimit RateLimitConf `toml:"ratelimit"`
 }
 
 type LoggerConf struct {
-	Level string
-	// TODO
+	Level  string `toml:"level"`
+	Format string `toml:"format"`
+}
+
+// StorageConf selects the events storage backend and configures its connection.
+// DSN, PoolSize and ConnectTimeout are used by the "sql" backend only.
+type StorageConf struct {
+	Type           string        `toml:"type"`
+	DSN            string        `toml:"dsn"`
+	PoolSize       int           `toml:"pool_size"`
+	ConnectTimeout time.Duration `toml:"connect_timeout"`
+}
+
+type HTTPConf struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
+func (c HTTPConf) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
 }
 
-func NewConfig() Config {
-	return Config{}
+type GRPCConf struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
 }
 
-// TODO
+func (c GRPCConf) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// RateLimitConf limits requests of every user over HTTP and gRPC by class: reads are GET requests
+// and calls that change nothing, writes are the others.
+type RateLimitConf struct {
+	Read  LimitConf `toml:"read"`
+	Write LimitConf `toml:"write"`
+}
+
+// LimitConf lets Rate requests per second on average and up to Burst at once, a zero Rate means no limit.
+type LimitConf struct {
+	Rate  float64 `toml:"rate"`
+	Burst int     `toml:"burst"`
+}
+
+func (c RateLimitConf) Limits() map[ratelimit.Class]ratelimit.Limit {
+	return map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassRead:  {Rate: c.Read.Rate, Burst: c.Read.Burst},
+		ratelimit.ClassWrite: {Rate: c.Write.Rate, Burst: c.Write.Burst},
+	}
+}
+
+// NewConfig reads the config file at path, applies CALENDAR_* environment overrides and validates the result.
+func NewConfig(path string) (Config, error) {
+	cfg := Config{
+		Logger: LoggerConf{Level: "INFO", Format: logger.FormatJSON},
+		Storage: StorageConf{
+			Type:           storageTypeMemory,
+			PoolSize:       10,
+			ConnectTimeout: 5 * time.Second,
+		},
+		HTTP: HTTPConf{Host: "0.0.0.0", Port: 8888},
+		GRPC: GRPCConf{Host: "0.0.0.0", Port: 50051},
+		RateLimit: RateLimitConf{
+			Read:  LimitConf{Rate: 50, Burst: 100},
+			Write: LimitConf{Rate: 10, Burst: 20},
+		},
+	}
+	if err := config.Load(path, envPrefix, &cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	switch strings.ToUpper(c.Logger.Level) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		return config.Invalid("logger.level", "unknown level %q; supported: DEBUG, INFO, WARN, ERROR", c.Logger.Level)
+	}
+	switch c.Logger.Format {
+	case logger.FormatJSON, logger.FormatText:
+	default:
+		return config.Invalid("logger.format", "unknown format %q; supported: %s, %s",
+			c.Logger.Format, logger.FormatJSON, logger.FormatText)
+	}
+
+	switch c.Storage.Type {
+	case storageTypeMemory:
+	case storageTypeSQL:
+		if c.Storage.DSN == "" {
+			return config.Invalid("storage.dsn", "must be set for %q storage", storageTypeSQL)
+		}
+		if c.Storage.PoolSize < 0 {
+			return config.Invalid("storage.pool_size", "must not be negative")
+		}
+		if c.Storage.ConnectTimeout < 0 {
+			return config.Invalid("storage.connect_timeout", "must not be negative")
+		}
+	default:
+		return config.Invalid("storage.type", "unknown type %q; supported: %s, %s",
+			c.Storage.Type, storageTypeMemory, storageTypeSQL)
+	}
+
+	if c.HTTP.Port <= 0 || c.HTTP.Port > 65535 {
+		return config.Invalid("http.port", "must be in range 1-65535, got %d", c.HTTP.Port)
+	}
+	if c.GRPC.Port <= 0 || c.GRPC.Port > 65535 {
+		return config.Invalid("grpc.port", "must be in range 1-65535, got %d", c.GRPC.Port)
+	}
+	if c.GRPC.Addr() == c.HTTP.Addr() {
+		return config.Invalid("grpc.port", "must differ from http.port on the same host")
+	}
+
+	for class, limit := range map[string]LimitConf{"read": c.RateLimit.Read, "write": c.RateLimit.Write} {
+		if limit.Rate < 0 {
+			return config.Invalid("ratelimit."+class+".rate", "must not be negative")
+		}
+		if limit.Rate > 0 && limit.Burst < 1 {
+			return config.Invalid("ratelimit."+class+".burst", "must be positive, got %d", limit.Burst)
+		}
+	}
+	return nil
+}
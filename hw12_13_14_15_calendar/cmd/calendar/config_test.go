@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("sample config", func(t *testing.T) {
+		cfg, err := NewConfig("../../configs/config.toml")
+		require.NoError(t, err)
+		require.Equal(t, "INFO", cfg.Logger.Level)
+		require.Equal(t, storageTypeMemory, cfg.Storage.Type)
+		require.Equal(t, 5*time.Second, cfg.Storage.ConnectTimeout)
+		require.Equal(t, LimitConf{Rate: 10, Burst: 20}, cfg.RateLimit.Write)
+	})
+
+	t.Run("env overrides", func(t *testing.T) {
+		t.Setenv("CALENDAR_STORAGE_TYPE", "sql")
+		t.Setenv("CALENDAR_STORAGE_DSN", "postgres://db")
+
+		cfg, err := NewConfig("../../configs/config.toml")
+		require.NoError(t, err)
+		require.Equal(t, storageTypeSQL, cfg.Storage.Type)
+		require.Equal(t, "postgres://db", cfg.Storage.DSN)
+
+		t.Setenv("CALENDAR_RATELIMIT_READ_RATE", "0.5")
+		cfg, err = NewConfig("../../configs/config.toml")
+		require.NoError(t, err)
+		require.Equal(t, 0.5, cfg.RateLimit.Read.Rate)
+	})
+
+	t.Run("invalid values name the key", func(t *testing.T) {
+		for key, env := range map[string]map[string]string{
+			"logger.level":          {"CALENDAR_LOGGER_LEVEL": "LOUD"},
+			"logger.format":         {"CALENDAR_LOGGER_FORMAT": "xml"},
+			"http.port":             {"CALENDAR_HTTP_PORT": "0"},
+			"grpc.port":             {"CALENDAR_GRPC_PORT": "8888"},
+			"storage.type":          {"CALENDAR_STORAGE_TYPE": "redis"},
+			"storage.dsn":           {"CALENDAR_STORAGE_TYPE": "sql", "CALENDAR_STORAGE_DSN": ""},
+			"storage.pool_size":     {"CALENDAR_STORAGE_TYPE": "sql", "CALENDAR_STORAGE_POOL_SIZE": "-1"},
+			"ratelimit.read.rate":   {"CALENDAR_RATELIMIT_READ_RATE": "-1"},
+			"ratelimit.write.burst": {"CALENDAR_RATELIMIT_WRITE_BURST": "0"},
+		} {
+			t.Run(key, func(t *testing.T) {
+				for name, value := range env {
+					t.Setenv(name, value)
+				}
+
+				_, err := NewConfig("../../configs/config.toml")
+				var keyErr config.KeyError
+				require.ErrorAs(t, err, &keyErr)
+				require.Equal(t, key, keyErr.Key)
+			})
+		}
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	internalstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const exportDateLayout = "2006-01-02"
+
+var errExportUsage = errors.New(
+	"usage: calendar [-config <path>] export -user <id> -from YYYY-MM-DD -to YYYY-MM-DD [-tz <zone>] [-out <file>]")
+
+// runExport writes user's events from the "from" till the "to" day inclusive as an iCalendar file
+// to -out or stdout. Days are taken in the -tz time zone, UTC by default. It reads the configured
+// storage, so it is only useful with the sql one.
+func runExport(ctx context.Context, conf StorageConf, args []string, stdout io.Writer) error {
+	var userID, rawFrom, rawTo, zone, out string
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.StringVar(&userID, "user", "", "ID of the user whose events are exported")
+	fs.StringVar(&rawFrom, "from", "", "First day of the exported period")
+	fs.StringVar(&rawTo, "to", "", "Last day of the exported period")
+	fs.StringVar(&zone, "tz", "", "IANA time zone of the days, UTC by default")
+	fs.StringVar(&out, "out", "", "Path of the file to write, stdout by default")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if userID == "" || fs.NArg() != 0 {
+		return errExportUsage
+	}
+	loc, err := internalstorage.LoadLocation(zone)
+	if err != nil {
+		return fmt.Errorf("invalid -tz: %w", errExportUsage)
+	}
+	from, err := time.Parse(exportDateLayout, rawFrom)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", errExportUsage)
+	}
+	to, err := time.Parse(exportDateLayout, rawTo)
+	if err != nil || to.Before(from) {
+		return fmt.Errorf("invalid -to: %w", errExportUsage)
+	}
+	from, _ = internalstorage.DayRange(internalstorage.DateIn(from, loc))
+	_, to = internalstorage.DayRange(internalstorage.DateIn(to, loc))
+
+	storage, err := newStorage(conf)
+	if err != nil {
+		return err
+	}
+	if err := storage.Connect(ctx); err != nil {
+		return err
+	}
+	defer storage.Close(ctx)
+
+	events, err := storage.ListEvents(ctx, userID, from, to)
+	if err != nil {
+		return fmt.Errorf("list events: %w", err)
+	}
+
+	if out == "" {
+		return ical.Encode(stdout, events, time.Now())
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := ical.Encode(f, events, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExport(t *testing.T) {
+	conf := StorageConf{Type: storageTypeMemory}
+	ctx := context.Background()
+
+	t.Run("stdout", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, runExport(ctx, conf,
+			[]string{"-user", "u1", "-from", "2024-03-01", "-to", "2024-03-31", "-tz", "Europe/Berlin"}, &out))
+		require.True(t, strings.HasPrefix(out.String(), "BEGIN:VCALENDAR\r\n"))
+	})
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "calendar.ics")
+		require.NoError(t, runExport(ctx, conf,
+			[]string{"-user", "u1", "-from", "2024-03-01", "-to", "2024-03-01", "-out", path}, nil))
+		require.FileExists(t, path)
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"-from", "2024-03-01", "-to", "2024-03-31"},
+			{"-user", "u1", "-from", "01.03.2024", "-to", "2024-03-31"},
+			{"-user", "u1", "-from", "2024-03-31", "-to", "2024-03-01"},
+			{"-user", "u1", "-from", "2024-03-01", "-to", "2024-03-31", "-tz", "Mars/Olympus"},
+		} {
+			require.ErrorIs(t, runExport(ctx, conf, args, nil), errExportUsage, args)
+		}
+	})
+}
@@ -3,15 +3,21 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // The image has no zone database, events may be planned in any zone.
 
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
 	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	internalgrpc "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/grpc"
 	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
-	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
 )
 
 var configFile string
@@ -28,13 +34,60 @@ func main() {
 		return
 	}
 
-	config := NewConfig()
-	logg := logger.New(config.Logger.Level)
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration: "+err.Error())
+		os.Exit(1)
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(context.Background(), config.Storage.DSN, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "export" {
+		if err := runExport(context.Background(), config.Storage, flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	logg, err := logger.New(config.Logger.Level, config.Logger.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create logger: "+err.Error())
+		os.Exit(1)
+	}
+
+	storage, err := newStorage(config.Storage)
+	if err != nil {
+		logg.Error("failed to create storage", "err", err)
+		os.Exit(1)
+	}
+	if err := storage.Connect(context.Background()); err != nil {
+		logg.Error("failed to connect to storage", "err", err)
+		os.Exit(1)
+	}
+	closeStorage := func() {
+		if err := storage.Close(context.Background()); err != nil {
+			logg.Error("failed to close storage", "err", err)
+		}
+	}
+	defer closeStorage()
+
+	registry := metrics.NewRegistry()
+	calendar := app.New(logg, metrics.NewStorage(registry, storage))
+	checker := health.NewChecker(map[string]health.Check{"storage": storage.Ping})
 
-	storage := memorystorage.New()
-	calendar := app.New(logg, storage)
+	limiter := ratelimit.New(config.RateLimit.Limits())
 
-	server := internalhttp.NewServer(logg, calendar)
+	server := internalhttp.NewServer(logg, calendar, metrics.NewHTTP(registry), limiter,
+		health.Handler(checker, buildInfo()), config.HTTP.Addr())
+	grpcServer := internalgrpc.NewServer(logg, calendar, metrics.NewGRPC(registry), limiter,
+		health.NewGRPCServer(checker, eventpb.EventService_ServiceDesc.ServiceName), config.GRPC.Addr())
 
 	ctx, cancel := signal.NotifyContext(context.Background(),
 		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
@@ -47,15 +100,35 @@ func main() {
 		defer cancel()
 
 		if err := server.Stop(ctx); err != nil {
-			logg.Error("failed to stop http server: " + err.Error())
+			logg.Error("failed to stop http server", "err", err)
+		}
+		if err := grpcServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop grpc server", "err", err)
+		}
+	}()
+
+	grpcFailed := make(chan struct{})
+	go func() {
+		if err := grpcServer.Start(ctx); err != nil {
+			logg.Error("failed to start grpc server", "err", err)
+			close(grpcFailed)
+			cancel()
 		}
 	}()
 
 	logg.Info("calendar is running...")
 
 	if err := server.Start(ctx); err != nil {
-		logg.Error("failed to start http server: " + err.Error())
+		logg.Error("failed to start http server", "err", err)
 		cancel()
+		closeStorage()
+		os.Exit(1) //nolint:gocritic
+	}
+	// A failed gRPC server stops the HTTP one, which then returns no error.
+	select {
+	case <-grpcFailed:
+		closeStorage()
 		os.Exit(1) //nolint:gocritic
+	default:
 	}
 }
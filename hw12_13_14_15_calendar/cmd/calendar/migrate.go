@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+var errMigrateUsage = errors.New("usage: calendar [-config <path>] migrate [-dsn <dsn>] up|down|status")
+
+// runMigrate manages schema migrations of the sql storage, dsn from the config may be overridden by -dsn flag.
+func runMigrate(ctx context.Context, dsn string, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.StringVar(&dsn, "dsn", dsn, "Database connection string")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if dsn == "" || fs.NArg() != 1 {
+		return errMigrateUsage
+	}
+
+	return sqlstorage.Migrate(ctx, dsn, fs.Arg(0), os.Stdout)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+const (
+	storageTypeMemory = "memory"
+	storageTypeSQL    = "sql"
+)
+
+// newStorage returns an events storage with a connection lifecycle managed by main.
+func newStorage(conf StorageConf) (metrics.EventStorage, error) {
+	switch conf.Type {
+	case storageTypeMemory:
+		return memorystorage.New(), nil
+	case storageTypeSQL:
+		return sqlstorage.New(conf.DSN, conf.PoolSize, conf.ConnectTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown storage type %q; supported: %s, %s",
+			conf.Type, storageTypeMemory, storageTypeSQL)
+	}
+}
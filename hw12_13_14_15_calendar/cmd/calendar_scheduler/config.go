@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+)
+
+// envPrefix prefixes environment variables overriding config keys, e.g. SCHEDULER_STORAGE_DSN.
+const envPrefix = "SCHEDULER"
+
+type Config struct {
+	Logger  LoggerConf  `toml:"logger"`
+	Storage StorageConf `toml:"storage"`
+	Queue   QueueConf   `toml:"queue"`
+	Scan    ScanConf    `toml:"scan"`
+	Leader  LeaderConf  `toml:"leader"`
+	Metrics MetricsConf `toml:"metrics"`
+	GRPC    GRPCConf    `toml:"grpc"`
+}
+
+type LoggerConf struct {
+	Level  string `toml:"level"`
+	Format string `toml:"format"`
+}
+
+// StorageConf configures the connection to the events database shared with the calendar.
+type StorageConf struct {
+	DSN            string        `toml:"dsn"`
+	PoolSize       int           `toml:"pool_size"`
+	ConnectTimeout time.Duration `toml:"connect_timeout"`
+}
+
+// QueueConf points to the queue notifications are published to.
+// URI is used by the "rabbitmq" queue only, Dir by the "file" one.
+type QueueConf struct {
+	Type string `toml:"type"`
+	Name string `toml:"name"`
+	URI  string `toml:"uri"`
+	Dir  string `toml:"dir"`
+}
+
+// ScanConf sets how often the storage is scanned for due notifications and old events,
+// and how far back a starting scheduler looks for notifications due while it was down.
+type ScanConf struct {
+	Interval time.Duration `toml:"interval"`
+	Lookback time.Duration `toml:"lookback"`
+}
+
+// LeaderConf elects the replica doing the scans, standby replicas take over within Lease after
+// the leader is gone. Name is used by the "postgres" lock only, Path by the "file" one.
+type LeaderConf struct {
+	Lock  string        `toml:"lock"`
+	Name  string        `toml:"name"`
+	Path  string        `toml:"path"`
+	Lease time.Duration `toml:"lease"`
+}
+
+// minLease keeps checks of the leader lock from flooding the storage.
+const minLease = time.Second
+
+// MetricsConf sets where Prometheus metrics are exposed.
+type MetricsConf struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
+func (c MetricsConf) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// GRPCConf sets where the gRPC health checking protocol is served.
+type GRPCConf struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
+func (c GRPCConf) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// NewConfig reads the config file at path, applies SCHEDULER_* environment overrides and validates the result.
+func NewConfig(path string) (Config, error) {
+	cfg := Config{
+		Logger:  LoggerConf{Level: "INFO", Format: logger.FormatJSON},
+		Storage: StorageConf{PoolSize: 2, ConnectTimeout: 5 * time.Second},
+		Queue:   QueueConf{Type: queueTypeRabbitMQ, Name: "notifications"},
+		Scan:    ScanConf{Interval: time.Minute, Lookback: time.Hour},
+		Leader: LeaderConf{
+			Lock:  leaderLockPostgres,
+			Name:  "calendar_scheduler",
+			Path:  "./scheduler.lock",
+			Lease: 15 * time.Second,
+		},
+		Metrics: MetricsConf{Host: "0.0.0.0", Port: 9101},
+		GRPC:    GRPCConf{Host: "0.0.0.0", Port: 50052},
+	}
+	if err := config.Load(path, envPrefix, &cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	switch strings.ToUpper(c.Logger.Level) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		return config.Invalid("logger.level", "unknown level %q; supported: DEBUG, INFO, WARN, ERROR", c.Logger.Level)
+	}
+	switch c.Logger.Format {
+	case logger.FormatJSON, logger.FormatText:
+	default:
+		return config.Invalid("logger.format", "unknown format %q; supported: %s, %s",
+			c.Logger.Format, logger.FormatJSON, logger.FormatText)
+	}
+
+	if c.Storage.DSN == "" {
+		return config.Invalid("storage.dsn", "must be set")
+	}
+	if c.Storage.PoolSize < 0 {
+		return config.Invalid("storage.pool_size", "must not be negative")
+	}
+	if c.Storage.ConnectTimeout < 0 {
+		return config.Invalid("storage.connect_timeout", "must not be negative")
+	}
+
+	if c.Queue.Name == "" {
+		return config.Invalid("queue.name", "must be set")
+	}
+	switch c.Queue.Type {
+	case queueTypeRabbitMQ:
+		if c.Queue.URI == "" {
+			return config.Invalid("queue.uri", "must be set for %q queue", queueTypeRabbitMQ)
+		}
+	case queueTypeFile:
+		if c.Queue.Dir == "" {
+			return config.Invalid("queue.dir", "must be set for %q queue", queueTypeFile)
+		}
+	default:
+		return config.Invalid("queue.type", "unknown type %q; supported: %s, %s",
+			c.Queue.Type, queueTypeRabbitMQ, queueTypeFile)
+	}
+
+	if c.Scan.Interval <= 0 {
+		return config.Invalid("scan.interval", "must be positive")
+	}
+	if c.Scan.Lookback < c.Scan.Interval || c.Scan.Lookback > scheduler.MaxLookback {
+		return config.Invalid("scan.lookback", "must be from scan.interval to %s, got %s",
+			scheduler.MaxLookback, c.Scan.Lookback)
+	}
+
+	switch c.Leader.Lock {
+	case leaderLockPostgres:
+		if c.Leader.Name == "" {
+			return config.Invalid("leader.name", "must be set for %q lock", leaderLockPostgres)
+		}
+		if c.Storage.PoolSize == 1 {
+			return config.Invalid("storage.pool_size", "must be 0 or at least 2, the %q leader lock holds a connection",
+				leaderLockPostgres)
+		}
+	case leaderLockFile:
+		if c.Leader.Path == "" {
+			return config.Invalid("leader.path", "must be set for %q lock", leaderLockFile)
+		}
+	default:
+		return config.Invalid("leader.lock", "unknown lock %q; supported: %s, %s",
+			c.Leader.Lock, leaderLockPostgres, leaderLockFile)
+	}
+	if c.Leader.Lease < minLease {
+		return config.Invalid("leader.lease", "must be at least %s, got %s", minLease, c.Leader.Lease)
+	}
+
+	if c.Metrics.Port <= 0 || c.Metrics.Port > 65535 {
+		return config.Invalid("metrics.port", "must be in range 1-65535, got %d", c.Metrics.Port)
+	}
+	if c.GRPC.Port <= 0 || c.GRPC.Port > 65535 {
+		return config.Invalid("grpc.port", "must be in range 1-65535, got %d", c.GRPC.Port)
+	}
+	if c.GRPC.Addr() == c.Metrics.Addr() {
+		return config.Invalid("grpc.port", "must differ from metrics.port on the same host")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/leader"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("sample config", func(t *testing.T) {
+		cfg, err := NewConfig("../../configs/scheduler_config.toml")
+		require.NoError(t, err)
+		require.Equal(t, "notifications", cfg.Queue.Name)
+		require.Equal(t, time.Minute, cfg.Scan.Interval)
+		require.Equal(t, time.Hour, cfg.Scan.Lookback)
+		require.Equal(t, leaderLockPostgres, cfg.Leader.Lock)
+		require.Equal(t, 15*time.Second, cfg.Leader.Lease)
+		require.Equal(t, "0.0.0.0:50052", cfg.GRPC.Addr())
+	})
+
+	t.Run("env overrides", func(t *testing.T) {
+		t.Setenv("SCHEDULER_SCAN_INTERVAL", "10s")
+		t.Setenv("SCHEDULER_QUEUE_TYPE", "file")
+		t.Setenv("SCHEDULER_QUEUE_DIR", "/var/lib/calendar/queue")
+		t.Setenv("SCHEDULER_LEADER_LOCK", "file")
+		t.Setenv("SCHEDULER_LEADER_PATH", "/run/calendar/scheduler.lock")
+
+		cfg, err := NewConfig("../../configs/scheduler_config.toml")
+		require.NoError(t, err)
+		require.Equal(t, 10*time.Second, cfg.Scan.Interval)
+		require.Equal(t, queueTypeFile, cfg.Queue.Type)
+		require.Equal(t, "/var/lib/calendar/queue", cfg.Queue.Dir)
+		require.Equal(t, leaderLockFile, cfg.Leader.Lock)
+
+		lock, err := newLeaderLock(cfg.Leader, nil)
+		require.NoError(t, err)
+		require.IsType(t, &leader.FileLock{}, lock)
+	})
+
+	t.Run("invalid values name the key", func(t *testing.T) {
+		for key, env := range map[string]string{
+			"logger.level":  "SCHEDULER_LOGGER_LEVEL",
+			"storage.dsn":   "SCHEDULER_STORAGE_DSN",
+			"queue.type":    "SCHEDULER_QUEUE_TYPE",
+			"queue.name":    "SCHEDULER_QUEUE_NAME",
+			"queue.uri":     "SCHEDULER_QUEUE_URI",
+			"scan.interval": "SCHEDULER_SCAN_INTERVAL",
+			"scan.lookback": "SCHEDULER_SCAN_LOOKBACK",
+			"leader.lock":   "SCHEDULER_LEADER_LOCK",
+			"leader.name":   "SCHEDULER_LEADER_NAME",
+			"leader.lease":  "SCHEDULER_LEADER_LEASE",
+			"metrics.port":  "SCHEDULER_METRICS_PORT",
+			"grpc.port":     "SCHEDULER_GRPC_PORT",
+		} {
+			t.Run(key, func(t *testing.T) {
+				value := ""
+				switch key {
+				case "scan.interval":
+					value = "0s"
+				case "scan.lookback":
+					value = "48h"
+				case "leader.lease":
+					value = "500ms"
+				case "metrics.port":
+					value = "0"
+				case "grpc.port":
+					value = "9101"
+				}
+				t.Setenv(env, value)
+
+				_, err := NewConfig("../../configs/scheduler_config.toml")
+				var keyErr config.KeyError
+				require.ErrorAs(t, err, &keyErr)
+				require.Equal(t, key, keyErr.Key)
+			})
+		}
+	})
+
+	t.Run("postgres lock needs a spare connection", func(t *testing.T) {
+		t.Setenv("SCHEDULER_STORAGE_POOL_SIZE", "1")
+
+		_, err := NewConfig("../../configs/scheduler_config.toml")
+		var keyErr config.KeyError
+		require.ErrorAs(t, err, &keyErr)
+		require.Equal(t, "storage.pool_size", keyErr.Key)
+	})
+}
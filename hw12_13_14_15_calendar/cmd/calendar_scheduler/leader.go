@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/leader"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+const (
+	leaderLockPostgres = "postgres"
+	leaderLockFile     = "file"
+)
+
+func newLeaderLock(conf LeaderConf, storage *sqlstorage.Storage) (leader.Lock, error) {
+	switch conf.Lock {
+	case leaderLockPostgres:
+		// The session of a lost leader is ended before a standby tries the lock for the last time within the lease.
+		return storage.NewLeaderLock(conf.Name, conf.Lease-leader.RenewInterval(conf.Lease)), nil
+	case leaderLockFile:
+		return leader.NewFileLock(conf.Path), nil
+	default:
+		return nil, fmt.Errorf("unknown leader lock %q; supported: %s, %s",
+			conf.Lock, leaderLockPostgres, leaderLockFile)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	_ "time/tzdata" // The image has no zone database, events may be planned in any zone.
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/leader"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+	sqlstorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/sql"
+)
+
+var configFile string
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/scheduler_config.toml", "Path to configuration file")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration: "+err.Error())
+		os.Exit(1)
+	}
+
+	logg, err := logger.New(config.Logger.Level, config.Logger.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create logger: "+err.Error())
+		os.Exit(1)
+	}
+
+	if err := run(logg, config); err != nil {
+		logg.Error("scheduler failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run(logg *logger.Logger, config Config) error {
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	storage := sqlstorage.New(config.Storage.DSN, config.Storage.PoolSize, config.Storage.ConnectTimeout)
+	if err := storage.Connect(ctx); err != nil {
+		return fmt.Errorf("connect to storage: %w", err)
+	}
+	defer func() {
+		if err := storage.Close(context.Background()); err != nil {
+			logg.Error("failed to close storage", "err", err)
+		}
+	}()
+
+	publisher, err := newPublisher(config.Queue)
+	if err != nil {
+		return err
+	}
+	if err := publisher.Connect(ctx); err != nil {
+		return fmt.Errorf("connect to queue: %w", err)
+	}
+	defer func() {
+		if err := publisher.Close(context.Background()); err != nil {
+			logg.Error("failed to close queue connection", "err", err)
+		}
+	}()
+
+	checker := health.NewChecker(map[string]health.Check{"storage": storage.Ping, "queue": publisher.Ping})
+	registry := metrics.NewRegistry()
+	metricsServer := metrics.NewServer(config.Metrics.Addr(), registry, health.Handler(checker, buildInfo()))
+	go func() {
+		if err := metricsServer.Start(); err != nil {
+			logg.Error("failed to start metrics server", "err", err)
+			cancel()
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := metricsServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop metrics server", "err", err)
+		}
+	}()
+
+	grpcServer := health.NewServer(checker, config.GRPC.Addr())
+	go func() {
+		if err := grpcServer.Start(ctx); err != nil {
+			logg.Error("failed to start grpc server", "err", err)
+			cancel()
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := grpcServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop grpc server", "err", err)
+		}
+	}()
+
+	lock, err := newLeaderLock(config.Leader, storage)
+	if err != nil {
+		return err
+	}
+	schedulerMetrics := metrics.NewScheduler(registry)
+	s := scheduler.New(logg, metrics.NewStorage(registry, storage), publisher, schedulerMetrics,
+		config.Scan.Interval, config.Scan.Lookback)
+
+	logg.Info("scheduler is running...", "interval", config.Scan.Interval, "leader_lock", config.Leader.Lock)
+	leader.New(logg, lock, schedulerMetrics, config.Leader.Lease).Run(ctx, s.Run)
+	logg.Info("scheduler stopped")
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/filequeue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbitmq"
+)
+
+const (
+	queueTypeRabbitMQ = "rabbitmq"
+	queueTypeFile     = "file"
+)
+
+// publisher is a queue publisher with a connection lifecycle managed by main.
+type publisher interface {
+	queue.Publisher
+	Connect(ctx context.Context) error
+	Close(ctx context.Context) error
+	Ping(ctx context.Context) error
+}
+
+func newPublisher(conf QueueConf) (publisher, error) {
+	switch conf.Type {
+	case queueTypeRabbitMQ:
+		return rabbitmq.NewPublisher(conf.URI, conf.Name), nil
+	case queueTypeFile:
+		// Deliveries and polling are settings of the consumer.
+		return filequeue.New(conf.Dir, conf.Name, 0, 0), nil
+	default:
+		return nil, fmt.Errorf("unknown queue type %q; supported: %s, %s",
+			conf.Type, queueTypeRabbitMQ, queueTypeFile)
+	}
+}
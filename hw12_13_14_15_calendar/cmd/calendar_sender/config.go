@@ -0,0 +1,229 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+)
+
+// envPrefix prefixes environment variables overriding config keys, e.g. SENDER_QUEUE_URI.
+const envPrefix = "SENDER"
+
+const (
+	sinkLog     = "log"
+	sinkWebhook = "webhook"
+	sinkFile    = "file"
+	sinkEmail   = "email"
+)
+
+type Config struct {
+	Logger   LoggerConf   `toml:"logger"`
+	Queue    QueueConf    `toml:"queue"`
+	Delivery DeliveryConf `toml:"delivery"`
+	Webhook  WebhookConf  `toml:"webhook"`
+	File     FileConf     `toml:"file"`
+	Email    EmailConf    `toml:"email"`
+	Metrics  MetricsConf  `toml:"metrics"`
+	GRPC     GRPCConf     `toml:"grpc"`
+}
+
+type LoggerConf struct {
+	Level  string `toml:"level"`
+	Format string `toml:"format"`
+}
+
+// QueueConf points to the queue notifications are consumed from. A message that fails
+// MaxDeliveries times is moved to the dead letter queue. URI is used by the "rabbitmq" queue only,
+// Dir and PollInterval by the "file" one.
+type QueueConf struct {
+	Type          string        `toml:"type"`
+	Name          string        `toml:"name"`
+	MaxDeliveries int           `toml:"max_deliveries"`
+	URI           string        `toml:"uri"`
+	Dir           string        `toml:"dir"`
+	PollInterval  time.Duration `toml:"poll_interval"`
+}
+
+// DeliveryConf selects sinks and limits delivery attempts to each of them. Notifications with
+// the ID of one delivered within DedupeWindow are dropped, zero disables that.
+type DeliveryConf struct {
+	Sinks          []string      `toml:"sinks"`
+	MaxAttempts    int           `toml:"max_attempts"`
+	InitialBackoff time.Duration `toml:"initial_backoff"`
+	MaxBackoff     time.Duration `toml:"max_backoff"`
+	DedupeWindow   time.Duration `toml:"dedupe_window"`
+}
+
+type WebhookConf struct {
+	URL     string        `toml:"url"`
+	Timeout time.Duration `toml:"timeout"`
+}
+
+type FileConf struct {
+	Path string `toml:"path"`
+}
+
+// EmailConf points to an SMTP relay accepting mail without authentication, such as a local MailHog.
+// Notifications are mailed to <user id>@Domain.
+type EmailConf struct {
+	Addr    string        `toml:"addr"`
+	From    string        `toml:"from"`
+	Domain  string        `toml:"domain"`
+	Timeout time.Duration `toml:"timeout"`
+}
+
+// MetricsConf sets where Prometheus metrics are exposed.
+type MetricsConf struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
+func (c MetricsConf) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// GRPCConf sets where the gRPC health checking protocol is served.
+type GRPCConf struct {
+	Host string `toml:"host"`
+	Port int    `toml:"port"`
+}
+
+func (c GRPCConf) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// NewConfig reads the config file at path, applies SENDER_* environment overrides and validates the result.
+func NewConfig(path string) (Config, error) {
+	cfg := Config{
+		Logger: LoggerConf{Level: "INFO", Format: logger.FormatJSON},
+		Queue: QueueConf{
+			Type:          queueTypeRabbitMQ,
+			Name:          "notifications",
+			MaxDeliveries: 5,
+			PollInterval:  time.Second,
+		},
+		Delivery: DeliveryConf{
+			Sinks:          []string{sinkLog},
+			MaxAttempts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     30 * time.Second,
+			DedupeWindow:   time.Hour,
+		},
+		Webhook: WebhookConf{Timeout: 5 * time.Second},
+		Email: EmailConf{
+			Addr:    "localhost:1025",
+			From:    "calendar@localhost",
+			Domain:  "localhost",
+			Timeout: 5 * time.Second,
+		},
+		Metrics: MetricsConf{Host: "0.0.0.0", Port: 9102},
+		GRPC:    GRPCConf{Host: "0.0.0.0", Port: 50053},
+	}
+	if err := config.Load(path, envPrefix, &cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	switch strings.ToUpper(c.Logger.Level) {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		return config.Invalid("logger.level", "unknown level %q; supported: DEBUG, INFO, WARN, ERROR", c.Logger.Level)
+	}
+	switch c.Logger.Format {
+	case logger.FormatJSON, logger.FormatText:
+	default:
+		return config.Invalid("logger.format", "unknown format %q; supported: %s, %s",
+			c.Logger.Format, logger.FormatJSON, logger.FormatText)
+	}
+
+	if c.Queue.Name == "" {
+		return config.Invalid("queue.name", "must be set")
+	}
+	if c.Queue.MaxDeliveries < 1 {
+		return config.Invalid("queue.max_deliveries", "must be positive")
+	}
+	switch c.Queue.Type {
+	case queueTypeRabbitMQ:
+		if c.Queue.URI == "" {
+			return config.Invalid("queue.uri", "must be set for %q queue", queueTypeRabbitMQ)
+		}
+	case queueTypeFile:
+		if c.Queue.Dir == "" {
+			return config.Invalid("queue.dir", "must be set for %q queue", queueTypeFile)
+		}
+		if c.Queue.PollInterval <= 0 {
+			return config.Invalid("queue.poll_interval", "must be positive")
+		}
+	default:
+		return config.Invalid("queue.type", "unknown type %q; supported: %s, %s",
+			c.Queue.Type, queueTypeRabbitMQ, queueTypeFile)
+	}
+
+	if len(c.Delivery.Sinks) == 0 {
+		return config.Invalid("delivery.sinks", "at least one sink must be set")
+	}
+	for _, sink := range c.Delivery.Sinks {
+		switch sink {
+		case sinkLog:
+		case sinkWebhook:
+			if c.Webhook.URL == "" {
+				return config.Invalid("webhook.url", "must be set for %q sink", sinkWebhook)
+			}
+		case sinkFile:
+			if c.File.Path == "" {
+				return config.Invalid("file.path", "must be set for %q sink", sinkFile)
+			}
+		case sinkEmail:
+			if err := c.Email.validate(); err != nil {
+				return err
+			}
+		default:
+			return config.Invalid("delivery.sinks", "unknown sink %q; supported: %s, %s, %s, %s",
+				sink, sinkLog, sinkWebhook, sinkFile, sinkEmail)
+		}
+	}
+	if c.Delivery.MaxAttempts < 1 {
+		return config.Invalid("delivery.max_attempts", "must be positive")
+	}
+	if c.Delivery.InitialBackoff < 0 {
+		return config.Invalid("delivery.initial_backoff", "must not be negative")
+	}
+	if c.Delivery.MaxBackoff < c.Delivery.InitialBackoff {
+		return config.Invalid("delivery.max_backoff", "must not be less than delivery.initial_backoff")
+	}
+	if c.Delivery.DedupeWindow < 0 {
+		return config.Invalid("delivery.dedupe_window", "must not be negative")
+	}
+	if c.Metrics.Port <= 0 || c.Metrics.Port > 65535 {
+		return config.Invalid("metrics.port", "must be in range 1-65535, got %d", c.Metrics.Port)
+	}
+	if c.GRPC.Port <= 0 || c.GRPC.Port > 65535 {
+		return config.Invalid("grpc.port", "must be in range 1-65535, got %d", c.GRPC.Port)
+	}
+	if c.GRPC.Addr() == c.Metrics.Addr() {
+		return config.Invalid("grpc.port", "must differ from metrics.port on the same host")
+	}
+	return nil
+}
+
+func (c EmailConf) validate() error {
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return config.Invalid("email.addr", "must be host:port, got %q", c.Addr)
+	}
+	if c.From == "" {
+		return config.Invalid("email.from", "must be set for %q sink", sinkEmail)
+	}
+	if c.Domain == "" {
+		return config.Invalid("email.domain", "must be set for %q sink", sinkEmail)
+	}
+	if c.Timeout <= 0 {
+		return config.Invalid("email.timeout", "must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("sample config", func(t *testing.T) {
+		cfg, err := NewConfig("../../configs/sender_config.toml")
+		require.NoError(t, err)
+		require.Equal(t, []string{sinkLog}, cfg.Delivery.Sinks)
+		require.Equal(t, 30*time.Second, cfg.Delivery.MaxBackoff)
+		require.Equal(t, time.Hour, cfg.Delivery.DedupeWindow)
+		require.Equal(t, "0.0.0.0:50053", cfg.GRPC.Addr())
+	})
+
+	t.Run("env overrides", func(t *testing.T) {
+		t.Setenv("SENDER_DELIVERY_SINKS", "log, file, email")
+		t.Setenv("SENDER_FILE_PATH", "/tmp/notifications.jsonl")
+		t.Setenv("SENDER_EMAIL_ADDR", "mailhog:1025")
+
+		cfg, err := NewConfig("../../configs/sender_config.toml")
+		require.NoError(t, err)
+		require.Equal(t, []string{sinkLog, sinkFile, sinkEmail}, cfg.Delivery.Sinks)
+		require.Equal(t, "mailhog:1025", cfg.Email.Addr)
+		require.Len(t, newSinks(nil, cfg), 3)
+	})
+
+	t.Run("invalid values name the key", func(t *testing.T) {
+		for key, env := range map[string]map[string]string{
+			"queue.type":             {"SENDER_QUEUE_TYPE": "kafka"},
+			"queue.uri":              {"SENDER_QUEUE_URI": ""},
+			"queue.dir":              {"SENDER_QUEUE_TYPE": "file", "SENDER_QUEUE_DIR": ""},
+			"queue.max_deliveries":   {"SENDER_QUEUE_MAX_DELIVERIES": "0"},
+			"delivery.sinks":         {"SENDER_DELIVERY_SINKS": "sms"},
+			"delivery.max_attempts":  {"SENDER_DELIVERY_MAX_ATTEMPTS": "0"},
+			"delivery.max_backoff":   {"SENDER_DELIVERY_MAX_BACKOFF": "1ms"},
+			"delivery.dedupe_window": {"SENDER_DELIVERY_DEDUPE_WINDOW": "-1s"},
+			"webhook.url":            {"SENDER_DELIVERY_SINKS": "webhook", "SENDER_WEBHOOK_URL": ""},
+			"file.path":              {"SENDER_DELIVERY_SINKS": "file", "SENDER_FILE_PATH": ""},
+			"email.addr":             {"SENDER_DELIVERY_SINKS": "email", "SENDER_EMAIL_ADDR": "mailhog"},
+			"email.timeout":          {"SENDER_DELIVERY_SINKS": "email", "SENDER_EMAIL_TIMEOUT": "0s"},
+			"metrics.port":           {"SENDER_METRICS_PORT": "0"},
+			"grpc.port":              {"SENDER_GRPC_PORT": "9102"},
+		} {
+			t.Run(key, func(t *testing.T) {
+				for name, value := range env {
+					t.Setenv(name, value)
+				}
+
+				_, err := NewConfig("../../configs/sender_config.toml")
+				var keyErr config.KeyError
+				require.ErrorAs(t, err, &keyErr)
+				require.Equal(t, key, keyErr.Key)
+			})
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/logger"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+)
+
+var configFile string
+
+func init() {
+	flag.StringVar(&configFile, "config", "/etc/calendar/sender_config.toml", "Path to configuration file")
+}
+
+func main() {
+	flag.Parse()
+
+	if flag.Arg(0) == "version" {
+		printVersion()
+		return
+	}
+
+	config, err := NewConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration: "+err.Error())
+		os.Exit(1)
+	}
+
+	logg, err := logger.New(config.Logger.Level, config.Logger.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create logger: "+err.Error())
+		os.Exit(1)
+	}
+
+	if err := run(logg, config); err != nil {
+		logg.Error("sender failed", "err", err)
+		os.Exit(1)
+	}
+}
+
+func run(logg *logger.Logger, config Config) error {
+	ctx, cancel := signal.NotifyContext(context.Background(),
+		syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer cancel()
+
+	consumer, err := newConsumer(config.Queue)
+	if err != nil {
+		return err
+	}
+	if err := consumer.Connect(ctx); err != nil {
+		return fmt.Errorf("connect to queue: %w", err)
+	}
+	defer func() {
+		if err := consumer.Close(context.Background()); err != nil {
+			logg.Error("failed to close queue connection", "err", err)
+		}
+	}()
+
+	checker := health.NewChecker(map[string]health.Check{"queue": consumer.Ping})
+	registry := metrics.NewRegistry()
+	metricsServer := metrics.NewServer(config.Metrics.Addr(), registry, health.Handler(checker, buildInfo()))
+	go func() {
+		if err := metricsServer.Start(); err != nil {
+			logg.Error("failed to start metrics server", "err", err)
+			cancel()
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := metricsServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop metrics server", "err", err)
+		}
+	}()
+
+	grpcServer := health.NewServer(checker, config.GRPC.Addr())
+	go func() {
+		if err := grpcServer.Start(ctx); err != nil {
+			logg.Error("failed to start grpc server", "err", err)
+			cancel()
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := grpcServer.Stop(ctx); err != nil {
+			logg.Error("failed to stop grpc server", "err", err)
+		}
+	}()
+
+	retry := sender.RetryPolicy{
+		MaxAttempts:    config.Delivery.MaxAttempts,
+		InitialBackoff: config.Delivery.InitialBackoff,
+		MaxBackoff:     config.Delivery.MaxBackoff,
+	}
+	s := sender.New(logg, consumer, newSinks(logg, config), metrics.NewSender(registry), retry,
+		config.Delivery.DedupeWindow)
+
+	logg.Info("sender is running...", "sinks", config.Delivery.Sinks)
+	if err := s.Run(ctx); err != nil {
+		return err
+	}
+	logg.Info("sender stopped")
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/filequeue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/rabbitmq"
+)
+
+const (
+	queueTypeRabbitMQ = "rabbitmq"
+	queueTypeFile     = "file"
+)
+
+// consumer is a queue consumer with a connection lifecycle managed by main.
+type consumer interface {
+	queue.Consumer
+	Connect(ctx context.Context) error
+	Close(ctx context.Context) error
+	Ping(ctx context.Context) error
+}
+
+func newConsumer(conf QueueConf) (consumer, error) {
+	switch conf.Type {
+	case queueTypeRabbitMQ:
+		return rabbitmq.NewConsumer(conf.URI, conf.Name, conf.MaxDeliveries), nil
+	case queueTypeFile:
+		return filequeue.New(conf.Dir, conf.Name, conf.MaxDeliveries, conf.PollInterval), nil
+	default:
+		return nil, fmt.Errorf("unknown queue type %q; supported: %s, %s",
+			conf.Type, queueTypeRabbitMQ, queueTypeFile)
+	}
+}
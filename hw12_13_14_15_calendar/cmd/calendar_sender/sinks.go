@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+)
+
+func newSinks(logg sender.Logger, config Config) []sender.Sink {
+	sinks := make([]sender.Sink, 0, len(config.Delivery.Sinks))
+	for _, name := range config.Delivery.Sinks {
+		switch name {
+		case sinkLog:
+			sinks = append(sinks, sender.NewLogSink(logg))
+		case sinkWebhook:
+			client := &http.Client{Timeout: config.Webhook.Timeout}
+			sinks = append(sinks, sender.NewWebhookSink(config.Webhook.URL, client))
+		case sinkFile:
+			sinks = append(sinks, sender.NewFileSink(config.File.Path))
+		case sinkEmail:
+			sinks = append(sinks, sender.NewEmailSink(config.Email.Addr, config.Email.From, config.Email.Domain,
+				config.Email.Timeout))
+		}
+	}
+	return sinks
+}
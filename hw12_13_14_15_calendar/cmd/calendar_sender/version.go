@@ -0,0 +1,29 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+)
+
+var (
+	release   = "UNKNOWN"
+	buildDate = "UNKNOWN"
+	gitHash   = "UNKNOWN"
+)
+
+func buildInfo() health.BuildInfo {
+	return health.BuildInfo{
+		Release:   release,
+		BuildDate: buildDate,
+		GitHash:   gitHash,
+	}
+}
+
+func printVersion() {
+	if err := json.NewEncoder(os.Stdout).Encode(buildInfo()); err != nil {
+		fmt.Printf("error while decode version info: %v\n", err)
+	}
+}
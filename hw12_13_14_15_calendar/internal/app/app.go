@@ -2,25 +2,194 @@ package app
 
 import (
 	"context"
+	"errors"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
 )
 
-type App struct { // TODO
+type App struct {
+	logger  Logger
+	storage Storage
 }
 
-type Logger interface { // TODO
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
 }
 
-type Storage interface { // TODO
+type Storage interface {
+	CreateEvent(ctx context.Context, e storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, id string, e storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id string) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error)
+	ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error)
+	ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error)
+	ExcludeOccurrence(ctx context.Context, id string, start time.Time, version int64) error
+	DetachOccurrence(ctx context.Context, id string, start time.Time, e storage.Event) (storage.Event, error)
+	GetEventByUID(ctx context.Context, userID, uid string, recurrenceID time.Time) (storage.Event, error)
+	ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+	ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error)
+	RespondToEvent(ctx context.Context, id, userID string, status storage.RSVPStatus) (storage.Event, error)
+	ListChanges(ctx context.Context, userID string, seq int64) (storage.Changes, error)
+	SearchEvents(ctx context.Context, q storage.SearchQuery) ([]storage.SearchHit, error)
 }
 
+var (
+	ErrNoUser    = errors.New("user id is not specified")
+	ErrForbidden = errors.New("event is owned by another user")
+	// ErrInvalidQuery means a free/busy or search query cannot be answered as asked.
+	ErrInvalidQuery = errors.New("invalid query")
+)
+
 func New(logger Logger, storage Storage) *App {
-	return &App{}
+	return &App{logger: logger, storage: storage}
+}
+
+// CreateEvent creates an event owned by the user. Attendees of a new event need to respond.
+func (a *App) CreateEvent(ctx context.Context, userID string, e storage.Event) (storage.Event, error) {
+	if userID == "" {
+		return storage.Event{}, ErrNoUser
+	}
+	e.UserID = userID
+	e = e.Invite(nil)
+	created, err := a.storage.CreateEvent(ctx, e)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.logger.Debug("event created", "event_id", created.ID, "user_id", userID)
+	return created, nil
+}
+
+// UpdateEvent replaces user's event keeping responses of its attendees.
+func (a *App) UpdateEvent(ctx context.Context, userID, id string, e storage.Event) (storage.Event, error) {
+	stored, err := a.getOwned(ctx, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	e.UserID = userID
+	e = e.Invite(stored.Attendees)
+	updated, err := a.storage.UpdateEvent(ctx, id, e)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.logger.Debug("event updated", "event_id", id, "user_id", userID)
+	return updated, nil
 }
 
-func (a *App) CreateEvent(ctx context.Context, id, title string) error {
-	// TODO
+// DeleteEvent deletes user's event.
+func (a *App) DeleteEvent(ctx context.Context, userID, id string) error {
+	if _, err := a.getOwned(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := a.storage.DeleteEvent(ctx, id); err != nil {
+		return err
+	}
+	a.logger.Debug("event deleted", "event_id", id, "user_id", userID)
 	return nil
-	// return a.storage.CreateEvent(storage.Event{ID: id, Title: title})
 }
 
-// TODO
+// UpdateOccurrence replaces a single occurrence of user's recurring event starting at start
+// with e and returns the new event detached from the series. Attendees of the series keep
+// their responses. A non-zero e.Version must equal the version of the series.
+func (a *App) UpdateOccurrence(
+	ctx context.Context, userID, id string, start time.Time, e storage.Event,
+) (storage.Event, error) {
+	series, err := a.getOwned(ctx, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	e.UserID = userID
+	e = e.Invite(series.Attendees)
+	detached, err := a.storage.DetachOccurrence(ctx, id, start, e)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.logger.Debug("occurrence updated", "event_id", id, "occurrence", start, "user_id", userID)
+	return detached, nil
+}
+
+// DeleteOccurrence deletes a single occurrence of user's recurring event starting at start.
+// A non-zero version must equal the version of the series.
+func (a *App) DeleteOccurrence(ctx context.Context, userID, id string, start time.Time, version int64) error {
+	if _, err := a.getOwned(ctx, userID, id); err != nil {
+		return err
+	}
+	if err := a.storage.ExcludeOccurrence(ctx, id, start, version); err != nil {
+		return err
+	}
+	a.logger.Debug("occurrence deleted", "event_id", id, "occurrence", start, "user_id", userID)
+	return nil
+}
+
+// ListConflicts returns other events of the owner of a saved event that it overlaps. Such events
+// are only saved in contexts returned by storage.WithOverlap.
+func (a *App) ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error) {
+	return a.storage.ListConflicts(ctx, e)
+}
+
+// RespondToEvent sets the RSVP status of the user attending the event.
+func (a *App) RespondToEvent(
+	ctx context.Context, userID, id string, status storage.RSVPStatus,
+) (storage.Event, error) {
+	if userID == "" {
+		return storage.Event{}, ErrNoUser
+	}
+	e, err := a.storage.RespondToEvent(ctx, id, userID, status)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	a.logger.Debug("event responded", "event_id", id, "user_id", userID, "status", status)
+	return e, nil
+}
+
+// GetEvent returns an event the user owns or attends. Other events are reported as storage.ErrNotFound.
+func (a *App) GetEvent(ctx context.Context, userID, id string) (storage.Event, error) {
+	if userID == "" {
+		return storage.Event{}, ErrNoUser
+	}
+	e, err := a.storage.GetEvent(ctx, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if !e.Involves(userID) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return e, nil
+}
+
+// getOwned returns user's event; attendees get ErrForbidden, others storage.ErrNotFound.
+func (a *App) getOwned(ctx context.Context, userID, id string) (storage.Event, error) {
+	e, err := a.GetEvent(ctx, userID, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	if e.UserID != userID {
+		return storage.Event{}, ErrForbidden
+	}
+	return e, nil
+}
+
+func (a *App) ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	return a.storage.ListDayEvents(ctx, userID, date)
+}
+
+func (a *App) ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	return a.storage.ListWeekEvents(ctx, userID, weekStart)
+}
+
+func (a *App) ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	return a.storage.ListMonthEvents(ctx, userID, monthStart)
+}
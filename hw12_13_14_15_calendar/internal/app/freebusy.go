@@ -0,0 +1,167 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	// maxFreeBusyRange limits the queried period, recurring events are expanded over all of it.
+	maxFreeBusyRange = 366 * 24 * time.Hour
+	maxFreeSlots     = 100
+)
+
+// Interval is the half-open time interval [Start, End).
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// WorkingHours is the time of day free slots are suggested within.
+type WorkingHours struct {
+	// Start and End are wall clock times in Location as durations since midnight.
+	Start time.Duration
+	End   time.Duration
+	// Days are the working days of week, every day if empty.
+	Days     []time.Weekday
+	Location *time.Location
+}
+
+// DefaultWorkingHours are from 9:00 till 18:00 on weekdays in UTC.
+var DefaultWorkingHours = WorkingHours{
+	Start:    9 * time.Hour,
+	End:      18 * time.Hour,
+	Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	Location: time.UTC,
+}
+
+// FreeBusyQuery asks for the busy time of a user within [From, To) and the first Slots
+// free slots of SlotDuration within WorkingHours.
+type FreeBusyQuery struct {
+	From         time.Time
+	To           time.Time
+	SlotDuration time.Duration
+	Slots        int
+	WorkingHours WorkingHours
+}
+
+// FreeBusy is the answer to FreeBusyQuery, intervals are in UTC in chronological order.
+type FreeBusy struct {
+	// Busy are occurrences of user's events merged and clipped to the queried period.
+	Busy []Interval
+	Free []Interval
+}
+
+func (q FreeBusyQuery) validate() error {
+	hours := q.WorkingHours
+	switch {
+	case !q.To.After(q.From):
+		return fmt.Errorf("%w: end of the period must be after its start", ErrInvalidQuery)
+	case q.To.Sub(q.From) > maxFreeBusyRange:
+		return fmt.Errorf("%w: period must not be longer than %s", ErrInvalidQuery, maxFreeBusyRange)
+	case q.Slots < 0 || q.Slots > maxFreeSlots:
+		return fmt.Errorf("%w: number of slots must be from 0 to %d", ErrInvalidQuery, maxFreeSlots)
+	case q.Slots > 0 && q.SlotDuration <= 0:
+		return fmt.Errorf("%w: slot duration must be positive", ErrInvalidQuery)
+	case hours.Start < 0 || hours.End > 24*time.Hour || hours.Start >= hours.End:
+		return fmt.Errorf("%w: working hours must start before they end within a day", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// FreeBusy returns user's busy time and suggests free slots, see FreeBusyQuery.
+func (a *App) FreeBusy(ctx context.Context, userID string, q FreeBusyQuery) (FreeBusy, error) {
+	if userID == "" {
+		return FreeBusy{}, ErrNoUser
+	}
+	if err := q.validate(); err != nil {
+		return FreeBusy{}, err
+	}
+	q.From, q.To = q.From.UTC(), q.To.UTC()
+
+	events, err := a.storage.ListEvents(ctx, userID, q.From, q.To)
+	if err != nil {
+		return FreeBusy{}, err
+	}
+	busy := make([]Interval, 0, len(events))
+	for _, e := range events {
+		for _, o := range e.Occurrences(q.From, q.To) {
+			busy = append(busy, Interval{Start: latest(o.StartAt, q.From), End: earliest(o.EndAt, q.To)})
+		}
+	}
+	busy = mergeIntervals(busy)
+	return FreeBusy{Busy: busy, Free: q.freeSlots(busy)}, nil
+}
+
+// mergeIntervals sorts intervals and joins the ones that intersect or touch.
+func mergeIntervals(intervals []Interval) []Interval {
+	slices.SortFunc(intervals, func(a, b Interval) int { return a.Start.Compare(b.Start) })
+	merged := make([]Interval, 0, len(intervals))
+	for _, in := range intervals {
+		if last := len(merged) - 1; last >= 0 && !in.Start.After(merged[last].End) {
+			merged[last].End = latest(merged[last].End, in.End)
+			continue
+		}
+		merged = append(merged, in)
+	}
+	return merged
+}
+
+// freeSlots returns the first q.Slots back-to-back slots within working hours of the queried
+// period that do not intersect merged busy intervals.
+func (q FreeBusyQuery) freeSlots(busy []Interval) []Interval {
+	free := make([]Interval, 0, q.Slots)
+	hours := q.WorkingHours
+	loc := hours.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for day := storage.DateIn(q.From.In(loc), loc); day.Before(q.To) && len(free) < q.Slots; {
+		y, m, d := day.Date()
+		weekday := day.Weekday()
+		_, day = storage.DayRange(day)
+		if len(hours.Days) > 0 && !slices.Contains(hours.Days, weekday) {
+			continue
+		}
+		// Nanoseconds overflowing the day are normalized by time.Date into wall clock time.
+		start := latest(time.Date(y, m, d, 0, 0, 0, int(hours.Start), loc).UTC(), q.From)
+		end := earliest(time.Date(y, m, d, 0, 0, 0, int(hours.End), loc).UTC(), q.To)
+
+		i := sort.Search(len(busy), func(i int) bool { return busy[i].End.After(start) })
+		for t := start; len(free) < q.Slots; {
+			for i < len(busy) && !busy[i].End.After(t) {
+				i++
+			}
+			if i < len(busy) && busy[i].Start.Before(t.Add(q.SlotDuration)) {
+				t = busy[i].End
+				continue
+			}
+			if t.Add(q.SlotDuration).After(end) {
+				break
+			}
+			free = append(free, Interval{Start: t, End: t.Add(q.SlotDuration)})
+			t = t.Add(q.SlotDuration)
+		}
+	}
+	return free
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+var monday = time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+func at(day int, clock time.Duration) time.Time {
+	return monday.AddDate(0, 0, day).Add(clock)
+}
+
+func TestFreeBusy(t *testing.T) {
+	ctx := storage.WithOverlap(context.Background())
+
+	t.Run("busy intervals and free slots", func(t *testing.T) {
+		a := New(nopLogger{}, memorystorage.New())
+		for _, e := range []storage.Event{
+			{Title: "late call", StartAt: at(-1, 23*time.Hour), EndAt: at(0, time.Hour)},
+			{Title: "meeting", StartAt: at(0, 10*time.Hour), EndAt: at(0, 11*time.Hour)},
+			{Title: "overlapping", StartAt: at(0, 10*time.Hour+30*time.Minute), EndAt: at(0, 12*time.Hour)},
+			{Title: "adjacent", StartAt: at(0, 12*time.Hour), EndAt: at(0, 13*time.Hour)},
+			{
+				Title: "standup", StartAt: at(0, 9*time.Hour), EndAt: at(0, 9*time.Hour+30*time.Minute),
+				RRule: "FREQ=DAILY",
+			},
+		} {
+			_, err := a.CreateEvent(ctx, "u1", e)
+			require.NoError(t, err)
+		}
+		_, err := a.CreateEvent(ctx, "u2",
+			storage.Event{Title: "other user", StartAt: at(0, 13*time.Hour), EndAt: at(0, 18*time.Hour)})
+		require.NoError(t, err)
+
+		fb, err := a.FreeBusy(ctx, "u1", FreeBusyQuery{
+			From: at(0, 0), To: at(2, 0), SlotDuration: time.Hour, Slots: 4, WorkingHours: DefaultWorkingHours,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Interval{
+			{at(0, 0), at(0, time.Hour)},
+			{at(0, 9*time.Hour), at(0, 9*time.Hour+30*time.Minute)},
+			{at(0, 10*time.Hour), at(0, 13*time.Hour)},
+			{at(1, 9*time.Hour), at(1, 9*time.Hour+30*time.Minute)},
+		}, fb.Busy)
+		require.Equal(t, []Interval{
+			{at(0, 13*time.Hour), at(0, 14*time.Hour)},
+			{at(0, 14*time.Hour), at(0, 15*time.Hour)},
+			{at(0, 15*time.Hour), at(0, 16*time.Hour)},
+			{at(0, 16*time.Hour), at(0, 17*time.Hour)},
+		}, fb.Free)
+	})
+
+	t.Run("working days in another zone", func(t *testing.T) {
+		a := New(nopLogger{}, memorystorage.New())
+		// From 9:00 till 18:00 in Berlin, the whole working Friday.
+		_, err := a.CreateEvent(ctx, "u1",
+			storage.Event{Title: "offsite", StartAt: at(4, 8*time.Hour), EndAt: at(4, 17*time.Hour)})
+		require.NoError(t, err)
+
+		berlin, err := storage.LoadLocation("Europe/Berlin")
+		require.NoError(t, err)
+		hours := DefaultWorkingHours
+		hours.Location = berlin
+		fb, err := a.FreeBusy(ctx, "u1", FreeBusyQuery{
+			From: at(4, 0), To: at(8, 0), SlotDuration: 30 * time.Minute, Slots: 2, WorkingHours: hours,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Interval{
+			{at(7, 8*time.Hour), at(7, 8*time.Hour+30*time.Minute)},
+			{at(7, 8*time.Hour+30*time.Minute), at(7, 9*time.Hour)},
+		}, fb.Free)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		a := New(nopLogger{}, memorystorage.New())
+		valid := FreeBusyQuery{
+			From: at(0, 0), To: at(1, 0), SlotDuration: time.Hour, Slots: 1, WorkingHours: DefaultWorkingHours,
+		}
+
+		_, err := a.FreeBusy(ctx, "", valid)
+		require.ErrorIs(t, err, ErrNoUser)
+
+		for name, modify := range map[string]func(q *FreeBusyQuery){
+			"empty period":     func(q *FreeBusyQuery) { q.To = q.From },
+			"too long period":  func(q *FreeBusyQuery) { q.To = q.From.AddDate(2, 0, 0) },
+			"negative slots":   func(q *FreeBusyQuery) { q.Slots = -1 },
+			"too many slots":   func(q *FreeBusyQuery) { q.Slots = maxFreeSlots + 1 },
+			"no slot duration": func(q *FreeBusyQuery) { q.SlotDuration = 0 },
+			"inverted hours":   func(q *FreeBusyQuery) { q.WorkingHours.Start = 20 * time.Hour },
+			"hours beyond day": func(q *FreeBusyQuery) { q.WorkingHours.End = 25 * time.Hour },
+			"negative hours":   func(q *FreeBusyQuery) { q.WorkingHours.Start = -time.Hour },
+		} {
+			q := valid
+			modify(&q)
+			_, err := a.FreeBusy(ctx, "u1", q)
+			require.ErrorIs(t, err, ErrInvalidQuery, name)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ImportStatus is the outcome of importing an entry of an iCalendar file.
+type ImportStatus string
+
+const (
+	ImportCreated   ImportStatus = "created"
+	ImportUpdated   ImportStatus = "updated"
+	ImportUnchanged ImportStatus = "unchanged"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportResult reports how an entry of an iCalendar file was imported.
+type ImportResult struct {
+	Line         int
+	UID          string
+	RecurrenceID time.Time
+	// ID of the created, updated or unchanged event.
+	ID     string
+	Status ImportStatus
+	Err    error
+}
+
+// ExportEvents returns user's events active within [from, to) with recurring events not expanded,
+// the way they are written to iCalendar files.
+func (a *App) ExportEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+	return a.storage.ListEvents(ctx, userID, from, to)
+}
+
+// ImportEvents creates user's events from entries of an iCalendar file or updates the ones
+// imported before under the same UID. Events an entry would not change are left as they are,
+// so importing a file again changes nothing. Recurring events are imported before edited
+// occurrences and keep their deleted occurrences deleted. Attendees, which are not imported,
+// are kept as well, and so are reminders when the entry has none.
+// Results are in the order of entries; an entry that fails does not stop the import.
+func (a *App) ImportEvents(ctx context.Context, userID string, entries []ical.Entry) ([]ImportResult, error) {
+	if userID == "" {
+		return nil, ErrNoUser
+	}
+
+	results := make([]ImportResult, len(entries))
+	for _, occurrences := range []bool{false, true} {
+		for i, entry := range entries {
+			if entry.Event.RecurrenceID.IsZero() == occurrences {
+				continue
+			}
+			result := ImportResult{
+				Line:         entry.Line,
+				UID:          entry.Event.UID,
+				RecurrenceID: entry.Event.RecurrenceID,
+				Status:       ImportFailed,
+				Err:          entry.Err,
+			}
+			if result.Err == nil {
+				var e storage.Event
+				e, result.Status, result.Err = a.importEvent(ctx, userID, entry.Event)
+				result.ID = e.ID
+			}
+			if result.Err != nil {
+				result.Status = ImportFailed
+			}
+			results[i] = result
+		}
+	}
+	a.logger.Debug("events imported", "user_id", userID, "entries", len(entries))
+	return results, nil
+}
+
+func (a *App) importEvent(ctx context.Context, userID string, e storage.Event) (storage.Event, ImportStatus, error) {
+	e.UserID = userID
+	existing, err := a.storage.GetEventByUID(ctx, userID, e.UID, e.RecurrenceID)
+	switch {
+	case err == nil:
+		if e.Recurring() {
+			e.ExDates = append(e.ExDates, existing.ExDates...)
+		}
+		e.Attendees = existing.Attendees
+		if len(e.Reminders) == 0 {
+			e.Reminders = existing.Reminders
+		}
+		e = e.Normalize()
+		if unchanged(e, existing) {
+			return existing, ImportUnchanged, nil
+		}
+		updated, err := a.storage.UpdateEvent(ctx, existing.ID, e)
+		return updated, ImportUpdated, err
+	case !errors.Is(err, storage.ErrNotFound):
+		return storage.Event{}, ImportFailed, err
+	case e.RecurrenceID.IsZero():
+		created, err := a.storage.CreateEvent(ctx, e)
+		return created, ImportCreated, err
+	}
+
+	series, err := a.storage.GetEventByUID(ctx, userID, e.UID, time.Time{})
+	if err != nil {
+		return storage.Event{}, ImportFailed, fmt.Errorf("recurring event of the occurrence: %w", err)
+	}
+	e.Attendees = series.Attendees
+	detached, err := a.storage.DetachOccurrence(ctx, series.ID, e.RecurrenceID, e)
+	return detached, ImportCreated, err
+}
+
+// unchanged reports whether updating the stored event with e, a normalized event of an entry,
+// would only bump its version.
+func unchanged(e, stored storage.Event) bool {
+	e.ID, e.SeriesID, e.Version = stored.ID, stored.SeriesID, stored.Version
+	return reflect.DeepEqual(e, stored.Normalize())
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// searchCursorPrefix versions the format of search cursors.
+	searchCursorPrefix = "v1:"
+)
+
+// SearchQuery asks for events the user owns or attends whose title or description contain
+// every word of Text, the most relevant first.
+type SearchQuery struct {
+	Text string
+	// OwnerID selects only events owned by that user, if set.
+	OwnerID string
+	// From and To select only events active within [From, To), if set.
+	From, To time.Time
+	// Limit is the maximum number of events, DefaultSearchLimit if zero.
+	Limit int
+	// Cursor continues the search from the page it was returned with.
+	Cursor string
+}
+
+// SearchResult is a page of events found by a search.
+type SearchResult struct {
+	// Events are found events, recurring ones unexpanded.
+	Events []storage.Event
+	// Cursor is the opaque cursor of the next page, empty for the last page.
+	Cursor string
+}
+
+func (q SearchQuery) validate() error {
+	switch {
+	case len(storage.SearchTerms(q.Text)) == 0:
+		return fmt.Errorf("%w: search text must contain a word", ErrInvalidQuery)
+	case q.From.IsZero() != q.To.IsZero():
+		return fmt.Errorf("%w: period must have both start and end", ErrInvalidQuery)
+	case !q.From.IsZero() && !q.To.After(q.From):
+		return fmt.Errorf("%w: end of the period must be after its start", ErrInvalidQuery)
+	case q.Limit < 0 || q.Limit > maxSearchLimit:
+		return fmt.Errorf("%w: limit must be from 0 to %d", ErrInvalidQuery, maxSearchLimit)
+	}
+	return nil
+}
+
+// SearchEvents returns a page of events matching the query, see SearchQuery.
+func (a *App) SearchEvents(ctx context.Context, userID string, q SearchQuery) (SearchResult, error) {
+	if userID == "" {
+		return SearchResult{}, ErrNoUser
+	}
+	if err := q.validate(); err != nil {
+		return SearchResult{}, err
+	}
+	after, err := parseSearchCursor(q.Cursor)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+
+	// One more hit tells whether there is a next page.
+	hits, err := a.storage.SearchEvents(ctx, storage.SearchQuery{
+		UserID: userID, Text: q.Text, OwnerID: q.OwnerID, From: q.From.UTC(), To: q.To.UTC(),
+		After: after, Limit: limit + 1,
+	})
+	if err != nil {
+		return SearchResult{}, err
+	}
+	result := SearchResult{Events: make([]storage.Event, 0, len(hits))}
+	if len(hits) > limit {
+		hits = hits[:limit]
+		result.Cursor = searchCursor(hits[limit-1].Cursor())
+	}
+	for _, hit := range hits {
+		result.Events = append(result.Events, hit.Event)
+	}
+	return result, nil
+}
+
+func searchCursor(c storage.SearchCursor) string {
+	raw := searchCursorPrefix + strconv.FormatFloat(c.Rank, 'g', -1, 64) + ":" +
+		strconv.FormatInt(c.StartAt.UnixMicro(), 10) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseSearchCursor(cursor string) (*storage.SearchCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	errInvalid := fmt.Errorf("%w: invalid cursor", ErrInvalidQuery)
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), searchCursorPrefix) {
+		return nil, errInvalid
+	}
+	parts := strings.SplitN(strings.TrimPrefix(string(raw), searchCursorPrefix), ":", 3)
+	if len(parts) != 3 {
+		return nil, errInvalid
+	}
+	if _, err := uuid.Parse(parts[2]); err != nil {
+		return nil, errInvalid
+	}
+	rank, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, errInvalid
+	}
+	start, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, errInvalid
+	}
+	return &storage.SearchCursor{Rank: rank, StartAt: time.UnixMicro(start).UTC(), ID: parts[2]}, nil
+}
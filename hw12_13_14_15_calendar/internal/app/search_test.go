@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchEvents(t *testing.T) {
+	ctx := storage.WithOverlap(context.Background())
+
+	t.Run("pages", func(t *testing.T) {
+		a := New(nopLogger{}, memorystorage.New())
+		var created []storage.Event
+		for day := 0; day < 5; day++ {
+			e, err := a.CreateEvent(ctx, "u1", storage.Event{
+				Title: "standup", StartAt: at(day, 9*time.Hour), EndAt: at(day, 10*time.Hour),
+			})
+			require.NoError(t, err)
+			created = append(created, e)
+		}
+		_, err := a.CreateEvent(ctx, "u1", storage.Event{Title: "retro", StartAt: at(0, 0), EndAt: at(0, time.Hour)})
+		require.NoError(t, err)
+
+		var found []storage.Event
+		q := SearchQuery{Text: "Standup", Limit: 2}
+		for pages := 1; ; pages++ {
+			result, err := a.SearchEvents(ctx, "u1", q)
+			require.NoError(t, err)
+			found = append(found, result.Events...)
+			if result.Cursor == "" {
+				require.Equal(t, 3, pages)
+				break
+			}
+			q.Cursor = result.Cursor
+		}
+		require.Equal(t, created, found)
+
+		result, err := a.SearchEvents(ctx, "u1", SearchQuery{Text: "standup", From: at(1, 0), To: at(3, 0)})
+		require.NoError(t, err)
+		require.Equal(t, created[1:3], result.Events)
+		require.Empty(t, result.Cursor)
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		a := New(nopLogger{}, memorystorage.New())
+		_, err := a.SearchEvents(ctx, "", SearchQuery{Text: "standup"})
+		require.ErrorIs(t, err, ErrNoUser)
+
+		for name, q := range map[string]SearchQuery{
+			"no words":       {Text: " - "},
+			"half a period":  {Text: "standup", From: at(0, 0)},
+			"empty period":   {Text: "standup", From: at(1, 0), To: at(0, 0)},
+			"negative limit": {Text: "standup", Limit: -1},
+			"large limit":    {Text: "standup", Limit: maxSearchLimit + 1},
+			"invalid cursor": {Text: "standup", Cursor: "garbage"},
+			"foreign cursor": {Text: "standup", Cursor: syncToken(1)},
+			"cursor id":      {Text: "standup", Cursor: searchCursor(storage.SearchCursor{StartAt: at(0, 0), ID: "1"})},
+		} {
+			_, err := a.SearchEvents(ctx, "u1", q)
+			require.ErrorIs(t, err, ErrInvalidQuery, name)
+		}
+	})
+}
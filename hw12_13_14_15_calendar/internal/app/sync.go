@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// syncTokenPrefix versions the format of sync tokens.
+const syncTokenPrefix = "v1:"
+
+var (
+	ErrInvalidSyncToken = errors.New("invalid sync token")
+	// ErrSyncTokenExpired means changes since the token are no longer kept and a full sync is required.
+	ErrSyncTokenExpired = errors.New("sync token expired, a full sync is required")
+)
+
+// SyncResult is what changed in user's calendar since a sync token.
+type SyncResult struct {
+	// Events are created and updated events, recurring ones unexpanded.
+	Events []storage.Event
+	// Deleted are IDs of events deleted or no longer shared with the user.
+	Deleted []string
+	// Token is the opaque token to sync from next time.
+	Token string
+}
+
+// Sync returns changes of the events the user owns or attends since the token,
+// or all of them for an empty token.
+func (a *App) Sync(ctx context.Context, userID, token string) (SyncResult, error) {
+	if userID == "" {
+		return SyncResult{}, ErrNoUser
+	}
+	seq, err := parseSyncToken(token)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	changes, err := a.storage.ListChanges(ctx, userID, seq)
+	if errors.Is(err, storage.ErrChangesCompacted) {
+		return SyncResult{}, fmt.Errorf("%w: %w", ErrSyncTokenExpired, err)
+	}
+	if err != nil {
+		return SyncResult{}, err
+	}
+	return SyncResult{Events: changes.Events, Deleted: changes.Deleted, Token: syncToken(changes.Seq)}, nil
+}
+
+func syncToken(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatInt(seq, 10)))
+}
+
+func parseSyncToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), syncTokenPrefix) {
+		return 0, ErrInvalidSyncToken
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(string(raw), syncTokenPrefix), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidSyncToken
+	}
+	return seq, nil
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("incremental sync", func(t *testing.T) {
+		a := New(nopLogger{}, memorystorage.New())
+		first, err := a.CreateEvent(ctx, "u1", storage.Event{Title: "first", StartAt: at(0, 0), EndAt: at(0, time.Hour)})
+		require.NoError(t, err)
+
+		full, err := a.Sync(ctx, "u1", "")
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{first}, full.Events)
+		require.NotEmpty(t, full.Token)
+
+		second, err := a.CreateEvent(ctx, "u1", storage.Event{Title: "second", StartAt: at(1, 0), EndAt: at(1, time.Hour)})
+		require.NoError(t, err)
+		require.NoError(t, a.DeleteEvent(ctx, "u1", first.ID))
+
+		changes, err := a.Sync(ctx, "u1", full.Token)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{second}, changes.Events)
+		require.Equal(t, []string{first.ID}, changes.Deleted)
+		require.NotEqual(t, full.Token, changes.Token)
+	})
+
+	t.Run("invalid and expired tokens", func(t *testing.T) {
+		s := memorystorage.New()
+		a := New(nopLogger{}, s)
+		_, err := a.Sync(ctx, "", "")
+		require.ErrorIs(t, err, ErrNoUser)
+		for _, token := range []string{"not base64!", syncToken(-1), "djI6MQ"} {
+			_, err = a.Sync(ctx, "u1", token)
+			require.ErrorIs(t, err, ErrInvalidSyncToken, token)
+		}
+
+		for day := range 2 {
+			_, err = a.CreateEvent(ctx, "u1", storage.Event{Title: "e", StartAt: at(day, 0), EndAt: at(day, time.Hour)})
+			require.NoError(t, err)
+		}
+		_, err = s.CompactChanges(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		_, err = a.Sync(ctx, "u1", syncToken(1))
+		require.ErrorIs(t, err, ErrSyncTokenExpired)
+		_, err = a.Sync(ctx, "u1", syncToken(2))
+		require.NoError(t, err)
+	})
+}
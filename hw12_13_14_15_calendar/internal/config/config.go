@@ -0,0 +1,142 @@
+// Package config loads service configuration from a TOML file with environment variable overrides.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+var (
+	ErrUnknownKey      = errors.New("unknown key")
+	ErrUnsupportedType = errors.New("unsupported field type")
+)
+
+// KeyError describes a problem with a single configuration key.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+func (e KeyError) Error() string {
+	return fmt.Sprintf("config key %q: %s", e.Key, e.Err)
+}
+
+func (e KeyError) Unwrap() error {
+	return e.Err
+}
+
+// Invalid returns a KeyError for the key with a formatted reason.
+func Invalid(key, format string, args ...interface{}) error {
+	return KeyError{Key: key, Err: fmt.Errorf(format, args...)}
+}
+
+// Load decodes the TOML file at path into cfg, which must be a pointer to a struct
+// prefilled with defaults, then applies environment overrides. Every key may be overridden
+// by a variable named after its path, e.g. prefix CALENDAR and key storage.dsn give CALENDAR_STORAGE_DSN.
+// Struct fields are mapped to keys with `toml` tags.
+func Load(path, envPrefix string, cfg interface{}) error {
+	md, err := toml.DecodeFile(path, cfg)
+	if err != nil {
+		return fmt.Errorf("read config file %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return KeyError{Key: undecoded[0].String(), Err: ErrUnknownKey}
+	}
+
+	return applyEnv(reflect.ValueOf(cfg).Elem(), "", envPrefix)
+}
+
+// EnvName returns the name of the environment variable overriding the key.
+func EnvName(envPrefix, key string) string {
+	return strings.ToUpper(envPrefix + "_" + strings.ReplaceAll(key, ".", "_"))
+}
+
+func applyEnv(v reflect.Value, keyPrefix, envPrefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("toml")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		key := keyPrefix + name
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(v.Field(i), key+".", envPrefix); err != nil {
+				return err
+			}
+			continue
+		}
+
+		envName := EnvName(envPrefix, key)
+		raw, ok := os.LookupEnv(envName)
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), raw); err != nil {
+			return KeyError{Key: key, Err: fmt.Errorf("%s=%q: %w", envName, raw, err)}
+		}
+	}
+	return nil
+}
+
+func setValue(v reflect.Value, raw string) error {
+	if _, ok := v.Interface().(time.Duration); ok {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return nil
+}
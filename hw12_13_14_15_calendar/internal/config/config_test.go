@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Server  serverConf  `toml:"server"`
+	Storage storageConf `toml:"storage"`
+}
+
+type serverConf struct {
+	Host  string   `toml:"host"`
+	Port  int      `toml:"port"`
+	Debug bool     `toml:"debug"`
+	Tags  []string `toml:"tags"`
+}
+
+type storageConf struct {
+	DSN            string        `toml:"dsn"`
+	ConnectTimeout time.Duration `toml:"connect_timeout"`
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("file with defaults", func(t *testing.T) {
+		path := writeConfig(t, `
+[server]
+host = "0.0.0.0"
+
+[storage]
+connect_timeout = "3s"
+`)
+		cfg := testConfig{Server: serverConf{Host: "localhost", Port: 8080}}
+
+		require.NoError(t, Load(path, "TEST", &cfg))
+		require.Equal(t, "0.0.0.0", cfg.Server.Host)
+		require.Equal(t, 8080, cfg.Server.Port)
+		require.Equal(t, 3*time.Second, cfg.Storage.ConnectTimeout)
+	})
+
+	t.Run("env overrides", func(t *testing.T) {
+		path := writeConfig(t, `
+[server]
+host = "0.0.0.0"
+port = 80
+`)
+		t.Setenv("TEST_SERVER_PORT", "8888")
+		t.Setenv("TEST_SERVER_DEBUG", "true")
+		t.Setenv("TEST_SERVER_TAGS", "a, b,,c")
+		t.Setenv("TEST_STORAGE_DSN", "postgres://db")
+		t.Setenv("TEST_STORAGE_CONNECT_TIMEOUT", "1m")
+		cfg := testConfig{}
+
+		require.NoError(t, Load(path, "TEST", &cfg))
+		require.Equal(t, testConfig{
+			Server:  serverConf{Host: "0.0.0.0", Port: 8888, Debug: true, Tags: []string{"a", "b", "c"}},
+			Storage: storageConf{DSN: "postgres://db", ConnectTimeout: time.Minute},
+		}, cfg)
+	})
+
+	t.Run("bad env value names the key", func(t *testing.T) {
+		path := writeConfig(t, "")
+		t.Setenv("TEST_SERVER_PORT", "eighty")
+
+		err := Load(path, "TEST", &testConfig{})
+		var keyErr KeyError
+		require.ErrorAs(t, err, &keyErr)
+		require.Equal(t, "server.port", keyErr.Key)
+		require.Contains(t, err.Error(), "TEST_SERVER_PORT")
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		path := writeConfig(t, `
+[server]
+hots = "localhost"
+`)
+		err := Load(path, "TEST", &testConfig{})
+		require.ErrorIs(t, err, ErrUnknownKey)
+		require.Contains(t, err.Error(), "server.hots")
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		path := writeConfig(t, `[server`)
+		require.Error(t, Load(path, "TEST", &testConfig{}))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		require.ErrorIs(t, Load(filepath.Join(t.TempDir(), "nope.toml"), "TEST", &testConfig{}), os.ErrNotExist)
+	})
+}
+
+func TestEnvName(t *testing.T) {
+	require.Equal(t, "CALENDAR_STORAGE_CONNECT_TIMEOUT", EnvName("CALENDAR", "storage.connect_timeout"))
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer implements the gRPC health checking protocol with the checks of a Checker.
+// Every service of the server shares the same status, Watch is not supported.
+type GRPCServer struct {
+	healthpb.UnimplementedHealthServer
+
+	checker  *Checker
+	services map[string]bool
+}
+
+// NewGRPCServer returns the health server of the named services. The empty service name,
+// which stands for the whole server, is always known.
+func NewGRPCServer(c *Checker, services ...string) *GRPCServer {
+	known := map[string]bool{"": true}
+	for _, s := range services {
+		known[s] = true
+	}
+	return &GRPCServer{checker: c, services: known}
+}
+
+func (s *GRPCServer) Check(
+	ctx context.Context, req *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	if !s.services[req.GetService()] {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	if err := s.checker.Ready(ctx); err != nil {
+		return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+// Server serves the gRPC health checking protocol alone, for services without a gRPC API.
+type Server struct {
+	server *grpc.Server
+	addr   string
+}
+
+func NewServer(c *Checker, addr string) *Server {
+	s := &Server{server: grpc.NewServer(), addr: addr}
+	healthpb.RegisterHealthServer(s.server, NewGRPCServer(c))
+	return s
+}
+
+// Start serves calls until Stop is called.
+func (s *Server) Start(ctx context.Context) error {
+	lis, err := (&net.ListenConfig{}).Listen(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.server.Serve(lis)
+}
+
+// Stop gracefully shuts the server down, active calls are cancelled when ctx is done.
+func (s *Server) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,64 @@
+// Package health reports whether a service is alive and ready to serve, so that orchestration
+// can probe it over HTTP or with the gRPC health checking protocol.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// checkTimeout limits a readiness check of all dependencies.
+const checkTimeout = 3 * time.Second
+
+// Check reports whether a dependency of the service is usable, e.g. pings its storage.
+type Check func(ctx context.Context) error
+
+// BuildInfo identifies the build of a binary, its fields are set with linker flags.
+type BuildInfo struct {
+	Release   string
+	BuildDate string
+	GitHash   string
+}
+
+// Checker runs readiness checks of the service dependencies.
+type Checker struct {
+	checks map[string]Check
+}
+
+// NewChecker returns a checker running checks by name. A service with no checks is always ready.
+func NewChecker(checks map[string]Check) *Checker {
+	return &Checker{checks: checks}
+}
+
+// Check runs all checks and returns the results by name, nil for passed ones.
+func (c *Checker) Check(ctx context.Context) map[string]error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	results := make(map[string]error, len(c.checks))
+	for name, check := range c.checks {
+		results[name] = check(ctx)
+	}
+	return results
+}
+
+// Ready returns an error naming every failed check.
+func (c *Checker) Ready(ctx context.Context) error {
+	results := c.Check(ctx)
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := results[name]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestChecker(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	t.Run("ready", func(t *testing.T) {
+		c := NewChecker(map[string]Check{"storage": ok, "queue": ok})
+		require.NoError(t, c.Ready(context.Background()))
+
+		rec := httptest.NewRecorder()
+		Handler(c, BuildInfo{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"status":"ok","checks":{"storage":"ok","queue":"ok"}}`, rec.Body.String())
+	})
+
+	t.Run("not ready", func(t *testing.T) {
+		c := NewChecker(map[string]Check{"storage": ok, "queue": down})
+		require.EqualError(t, c.Ready(context.Background()), "queue: connection refused")
+
+		rec := httptest.NewRecorder()
+		Handler(c, BuildInfo{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.JSONEq(t, `{"status":"unavailable","checks":{"storage":"ok","queue":"connection refused"}}`,
+			rec.Body.String())
+
+		resp, err := NewGRPCServer(c).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+	})
+
+	t.Run("alive while not ready", func(t *testing.T) {
+		c := NewChecker(map[string]Check{"storage": down})
+
+		rec := httptest.NewRecorder()
+		Handler(c, BuildInfo{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+func TestServer(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	s := NewServer(NewChecker(map[string]Check{"queue": func(context.Context) error { return nil }}), addr)
+	started := make(chan error, 1)
+	go func() { started <- s.Start(context.Background()) }()
+	t.Cleanup(func() {
+		require.NoError(t, s.Stop(context.Background()))
+		require.NoError(t, <-started)
+	})
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	require.Eventually(t, func() bool {
+		resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && resp.Status == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+}
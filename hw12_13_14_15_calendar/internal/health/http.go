@@ -0,0 +1,50 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+type statusResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Handler serves the probes:
+//   - GET /healthz answers 200 while the process is running;
+//   - GET /readyz answers 200 when all checks pass and 503 otherwise, with the result of every check;
+//   - GET /version returns the build info in the format of the version command.
+func Handler(c *Checker, info BuildInfo) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, statusResponse{Status: statusOK})
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		resp := statusResponse{Status: statusOK, Checks: map[string]string{}}
+		code := http.StatusOK
+		for name, err := range c.Check(r.Context()) {
+			resp.Checks[name] = statusOK
+			if err != nil {
+				resp.Checks[name] = err.Error()
+				resp.Status = statusUnavailable
+				code = http.StatusServiceUnavailable
+			}
+		}
+		writeJSON(w, code, resp)
+	})
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, info)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
@@ -0,0 +1,312 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// maxContentLine limits the length of an unfolded content line.
+const maxContentLine = 1 << 20
+
+var ErrInvalidCalendar = errors.New("invalid calendar")
+
+// Entry is a VEVENT of a decoded calendar: the event or the reason it cannot be decoded.
+type Entry struct {
+	// Line is the number of the BEGIN:VEVENT line.
+	Line  int
+	Event storage.Event
+	Err   error
+}
+
+// Decode reads VEVENT components of a VCALENDAR. Events have UID and RecurrenceID of the VEVENT
+// and no ID and UserID; TZID of a local DTSTART becomes the time zone of the event. A VEVENT
+// that cannot be decoded is returned with Err, while a broken calendar structure fails decoding
+// as a whole with ErrInvalidCalendar.
+func Decode(r io.Reader) ([]Entry, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries []Entry
+		stack   []string
+		event   *vevent
+	)
+	for _, l := range lines {
+		p, err := parseProperty(l.text)
+		if err != nil {
+			if event != nil {
+				event.err = fmt.Errorf("line %d: %w", l.number, err)
+				continue
+			}
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidCalendar, l.number, err)
+		}
+
+		switch p.name {
+		case "BEGIN":
+			component := strings.ToUpper(p.value)
+			if len(stack) == 0 && component != "VCALENDAR" {
+				return nil, fmt.Errorf("%w: line %d: VCALENDAR expected", ErrInvalidCalendar, l.number)
+			}
+			stack = append(stack, component)
+			switch {
+			case component == "VEVENT" && len(stack) == 2:
+				event = &vevent{line: l.number}
+			case component == "VALARM" && event != nil:
+				event.alarms = append(event.alarms, nil)
+			}
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(p.value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidCalendar, l.number, p.value)
+			}
+			stack = stack[:len(stack)-1]
+			if event != nil && len(stack) == 1 {
+				entries = append(entries, event.entry())
+				event = nil
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: VCALENDAR expected", ErrInvalidCalendar, l.number)
+			}
+			if event != nil {
+				event.add(stack[len(stack)-1], p)
+			}
+		}
+	}
+	if lines == nil {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidCalendar)
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: %s is not closed", ErrInvalidCalendar, stack[len(stack)-1])
+	}
+	return entries, nil
+}
+
+type contentLine struct {
+	number int
+	text   string
+}
+
+// unfold joins folded lines; numbers point to the first line of each content line.
+func unfold(r io.Reader) ([]contentLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxContentLine)
+
+	var lines []contentLine
+	for number := 1; scanner.Scan(); number++ {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		switch {
+		case text == "":
+			continue
+		case (text[0] == ' ' || text[0] == '\t') && len(lines) > 0:
+			lines[len(lines)-1].text += text[1:]
+		default:
+			lines = append(lines, contentLine{number: number, text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCalendar, err)
+	}
+	return lines, nil
+}
+
+// property is a content line: NAME;PARAM=VALUE:VALUE. Names are upper-cased.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func (p property) param(name string) string {
+	return p.params[name]
+}
+
+func parseProperty(line string) (property, error) {
+	var (
+		parts  []string
+		start  int
+		quoted bool
+	)
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				parts = append(parts, line[start:i])
+				start = i + 1
+			}
+		case ':':
+			if quoted {
+				continue
+			}
+			parts = append(parts, line[start:i])
+			p := property{name: strings.ToUpper(parts[0]), value: line[i+1:]}
+			for _, param := range parts[1:] {
+				name, value, ok := strings.Cut(param, "=")
+				if !ok {
+					return property{}, fmt.Errorf("invalid parameter %q", param)
+				}
+				if p.params == nil {
+					p.params = make(map[string]string)
+				}
+				p.params[strings.ToUpper(name)] = strings.Trim(value, `"`)
+			}
+			if p.name == "" {
+				return property{}, errors.New("property name is empty")
+			}
+			return p, nil
+		}
+	}
+	return property{}, fmt.Errorf("invalid content line %q", line)
+}
+
+// vevent collects properties of a VEVENT and its VALARM components.
+type vevent struct {
+	line    int
+	props   map[string]property
+	exDates []property
+	alarms  []map[string]property
+	err     error
+}
+
+func (v *vevent) add(component string, p property) {
+	switch {
+	case component == "VALARM" && len(v.alarms) > 0:
+		v.alarms[len(v.alarms)-1] = addFirst(v.alarms[len(v.alarms)-1], p)
+	case component != "VEVENT":
+	case p.name == "EXDATE":
+		v.exDates = append(v.exDates, p)
+	default:
+		v.props = addFirst(v.props, p)
+	}
+}
+
+// addFirst adds the property unless props already has one of the same name.
+func addFirst(props map[string]property, p property) map[string]property {
+	if props == nil {
+		props = make(map[string]property)
+	}
+	if _, ok := props[p.name]; !ok {
+		props[p.name] = p
+	}
+	return props
+}
+
+// entry returns the decoded event; errors wrap storage.ErrInvalidEvent.
+func (v *vevent) entry() Entry {
+	err := v.err
+	var e storage.Event
+	if err == nil {
+		e, err = v.event()
+	}
+	if err != nil {
+		e = storage.Event{UID: unescapeText(v.props["UID"].value)}
+		err = fmt.Errorf("%w: %w", storage.ErrInvalidEvent, err)
+	}
+	return Entry{Line: v.line, Event: e, Err: err}
+}
+
+func (v *vevent) event() (storage.Event, error) {
+	e := storage.Event{
+		UID:         unescapeText(v.props["UID"].value),
+		Title:       unescapeText(v.props["SUMMARY"].value),
+		Description: unescapeText(v.props["DESCRIPTION"].value),
+		RRule:       v.props["RRULE"].value,
+	}
+	if e.UID == "" {
+		return e, errors.New("UID is missing")
+	}
+
+	dtStart, ok := v.props["DTSTART"]
+	if !ok {
+		return e, errors.New("DTSTART is missing")
+	}
+	var err error
+	if e.StartAt, err = parseTime(dtStart); err != nil {
+		return e, fmt.Errorf("DTSTART: %w", err)
+	}
+	if e.EndAt, err = v.end(e.StartAt, dtStart); err != nil {
+		return e, err
+	}
+	if tzid := dtStart.param("TZID"); tzid != "" && !strings.HasSuffix(dtStart.value, "Z") {
+		e.TimeZone = strings.TrimPrefix(tzid, "/")
+	}
+
+	for _, p := range v.exDates {
+		exDates, err := parseTimes(p)
+		if err != nil {
+			return e, fmt.Errorf("EXDATE: %w", err)
+		}
+		e.ExDates = append(e.ExDates, exDates...)
+	}
+	if p, ok := v.props["RECURRENCE-ID"]; ok {
+		if e.RecurrenceID, err = parseTime(p); err != nil {
+			return e, fmt.Errorf("RECURRENCE-ID: %w", err)
+		}
+	}
+	e.NotifyBefore, e.Reminders = v.reminders()
+	return e, nil
+}
+
+// end returns the end of the event from DTEND or DURATION. Without both, an event of
+// a DATE lasts the day and of a DATE-TIME takes no time.
+func (v *vevent) end(start time.Time, dtStart property) (time.Time, error) {
+	if p, ok := v.props["DTEND"]; ok {
+		end, err := parseTime(p)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("DTEND: %w", err)
+		}
+		return end, nil
+	}
+	if p, ok := v.props["DURATION"]; ok {
+		d, err := parseDuration(p.value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("DURATION: %w", err)
+		}
+		return start.Add(d), nil
+	}
+	if dtStart.param("VALUE") == "DATE" || len(dtStart.value) == len(dateLayout) {
+		return start.AddDate(0, 0, 1), nil
+	}
+	return start, nil
+}
+
+// reminders returns reminders of alarms triggered before the start of the event. Alarms with
+// a channel property and EMAIL ones of other tools become reminders of their channel; the first
+// of the others sets the notification time of the event and the rest are ignored.
+func (v *vevent) reminders() (time.Duration, []storage.Reminder) {
+	var (
+		notifyBefore time.Duration
+		notified     bool
+		reminders    []storage.Reminder
+	)
+	for _, alarm := range v.alarms {
+		p := alarm["TRIGGER"]
+		if p.param("VALUE") == "DATE-TIME" || p.param("RELATED") == "END" {
+			continue
+		}
+		d, err := parseDuration(p.value)
+		if err != nil || d > 0 {
+			continue
+		}
+		channel := storage.Channel(unescapeText(alarm[channelProperty].value))
+		if channel == "" && strings.EqualFold(alarm["ACTION"].value, "EMAIL") {
+			channel = storage.ChannelEmail
+		}
+		switch {
+		case channel != "":
+			reminders = append(reminders, storage.Reminder{Before: -d, Channel: channel})
+		case !notified:
+			notifyBefore, notified = -d, true
+		}
+	}
+	return notifyBefore, reminders
+}
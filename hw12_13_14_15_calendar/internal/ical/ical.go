@@ -0,0 +1,131 @@
+// Package ical encodes and decodes events as iCalendar (RFC 5545) files: a VCALENDAR of VEVENT
+// components with UID, SUMMARY, DESCRIPTION, DTSTART, DTEND or DURATION, RRULE, EXDATE,
+// RECURRENCE-ID and VALARM components: one for the notification time of the event and one
+// for each of its reminders, with the reminder channel in X-CALENDAR-CHANNEL.
+// Other properties and components are ignored on decoding.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// ContentType is the media type of iCalendar files.
+const ContentType = "text/calendar"
+
+const (
+	prodID = "-//fixme_my_friend//calendar//EN"
+	// maxLineLength is the limit of a content line in octets, longer lines are folded.
+	maxLineLength = 75
+	// channelProperty is the VALARM property keeping the channel of a reminder.
+	channelProperty = "X-CALENDAR-CHANNEL"
+)
+
+// Encode writes events as a VCALENDAR stamped with the moment it is created. An edited occurrence
+// is written with the UID of its recurring event and a RECURRENCE-ID. Times of events planned
+// in a zone other than UTC are local with the IANA zone name as TZID, VTIMEZONE is not written.
+func Encode(w io.Writer, events []storage.Event, stamp time.Time) error {
+	enc := encoder{w: bufio.NewWriter(w)}
+	enc.line("BEGIN", "VCALENDAR")
+	enc.line("VERSION", "2.0")
+	enc.line("PRODID", prodID)
+	enc.line("CALSCALE", "GREGORIAN")
+	for _, e := range events {
+		enc.event(e, stamp)
+	}
+	enc.line("END", "VCALENDAR")
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (enc *encoder) event(e storage.Event, stamp time.Time) {
+	enc.line("BEGIN", "VEVENT")
+	enc.line("UID", escapeText(e.UID))
+	enc.line("DTSTAMP", formatTime(stamp))
+	enc.times("DTSTART", e, e.StartAt)
+	enc.times("DTEND", e, e.EndAt)
+	enc.line("SUMMARY", escapeText(e.Title))
+	if e.Description != "" {
+		enc.line("DESCRIPTION", escapeText(e.Description))
+	}
+	if e.RRule != "" {
+		enc.line("RRULE", e.RRule)
+	}
+	if len(e.ExDates) > 0 {
+		enc.times("EXDATE", e, e.ExDates...)
+	}
+	if !e.RecurrenceID.IsZero() {
+		enc.times("RECURRENCE-ID", e, e.RecurrenceID)
+	}
+	for _, r := range e.AllReminders() {
+		enc.alarm(e, r)
+	}
+	enc.line("END", "VEVENT")
+}
+
+// alarm writes a reminder as a VALARM. Reminders of the email channel are EMAIL alarms,
+// the others are DISPLAY ones; the reminder of NotifyBefore has no channel property.
+func (enc *encoder) alarm(e storage.Event, r storage.Reminder) {
+	enc.line("BEGIN", "VALARM")
+	if r.Channel == storage.ChannelEmail {
+		enc.line("ACTION", "EMAIL")
+		enc.line("SUMMARY", escapeText(e.Title))
+	} else {
+		enc.line("ACTION", "DISPLAY")
+	}
+	enc.line("DESCRIPTION", escapeText(e.Title))
+	enc.line("TRIGGER", formatDuration(-r.Before))
+	if r.Channel != "" {
+		enc.line(channelProperty, escapeText(string(r.Channel)))
+	}
+	enc.line("END", "VALARM")
+}
+
+// times writes a DATE-TIME property in UTC or, for an event planned in another zone, in local time.
+func (enc *encoder) times(name string, e storage.Event, times ...time.Time) {
+	utc := e.TimeZone == "" || e.TimeZone == "UTC"
+	loc := e.Location()
+	values := make([]string, 0, len(times))
+	for _, t := range times {
+		if utc {
+			values = append(values, formatTime(t))
+		} else {
+			values = append(values, t.In(loc).Format(floatingLayout))
+		}
+	}
+	if !utc {
+		name += ";TZID=" + e.TimeZone
+	}
+	enc.line(name, strings.Join(values, ","))
+}
+
+// line writes a content line folded to maxLineLength octets without splitting UTF-8 characters.
+func (enc *encoder) line(name, value string) {
+	if enc.err != nil {
+		return
+	}
+	line := name + ":" + value
+	for limit := maxLineLength; len(line) > limit; limit = maxLineLength - 1 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if _, enc.err = enc.w.WriteString(line[:cut] + "\r\n "); enc.err != nil {
+			return
+		}
+		line = line[cut:]
+	}
+	_, enc.err = enc.w.WriteString(line + "\r\n")
+}
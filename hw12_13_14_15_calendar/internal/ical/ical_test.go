@@ -0,0 +1,196 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+
+func TestEncodeDecode(t *testing.T) {
+	events := []storage.Event{
+		{
+			UID:          "5b0b1f7e-3f57-4a43-9d6b-1b6b7f0c2a11",
+			Title:        "sync; planning, review",
+			StartAt:      baseTime,
+			EndAt:        baseTime.Add(time.Hour),
+			TimeZone:     "Europe/Berlin",
+			Description:  "agenda:\n" + strings.Repeat("discuss everything ", 10),
+			NotifyBefore: 90 * time.Minute,
+			Reminders: []storage.Reminder{
+				{Before: time.Hour, Channel: storage.ChannelEmail},
+				{Before: time.Hour, Channel: storage.ChannelWebhook},
+				{Before: 0, Channel: storage.ChannelLog},
+			},
+			RRule:   "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=10",
+			ExDates: []time.Time{baseTime.AddDate(0, 0, 7)},
+		},
+		{
+			UID:          "5b0b1f7e-3f57-4a43-9d6b-1b6b7f0c2a11",
+			Title:        "moved sync",
+			StartAt:      baseTime.AddDate(0, 0, 7).Add(2 * time.Hour),
+			EndAt:        baseTime.AddDate(0, 0, 7).Add(3 * time.Hour),
+			RecurrenceID: baseTime.AddDate(0, 0, 7),
+			TimeZone:     "Europe/Berlin",
+		},
+		{
+			UID:     "utc@example.com",
+			Title:   "utc",
+			StartAt: baseTime.AddDate(0, 0, 1),
+			EndAt:   baseTime.AddDate(0, 0, 1).Add(time.Hour),
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, events, baseTime))
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		require.LessOrEqual(t, len(line), maxLineLength, line)
+	}
+	require.Contains(t, buf.String(), "TRIGGER:-PT1H30M\r\n")
+	require.Equal(t, 1, strings.Count(buf.String(), "ACTION:EMAIL\r\n"))
+	require.Contains(t, buf.String(), "X-CALENDAR-CHANNEL:webhook\r\n")
+	require.Contains(t, buf.String(), `SUMMARY:sync\; planning\, review`)
+	require.Contains(t, buf.String(), "DTSTART;TZID=Europe/Berlin:20240304T110000\r\n")
+	require.Contains(t, buf.String(), "DTSTART:20240305T100000Z\r\n")
+
+	entries, err := Decode(&buf)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for i, entry := range entries {
+		require.NoError(t, entry.Err)
+		require.Equal(t, events[i].Normalize(), entry.Event.Normalize())
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("other tools", func(t *testing.T) {
+		entries, err := Decode(strings.NewReader(strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"PRODID:-//Other//Tool//EN",
+			"BEGIN:VTIMEZONE",
+			"TZID:Europe/Berlin",
+			"END:VTIMEZONE",
+			"BEGIN:VEVENT",
+			"UID:all-day@example.com",
+			"DTSTART;VALUE=DATE:20240304",
+			"SUMMARY:holiday",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:zoned@example.com",
+			`DTSTART;TZID="Europe/Berlin":20240304T100000`,
+			"DURATION:PT45M",
+			"SUMMARY:long ",
+			" title",
+			"EXDATE;TZID=Europe/Berlin:20240311T100000,20240318T100000",
+			"RRULE:FREQ=WEEKLY",
+			"BEGIN:VALARM",
+			"TRIGGER;RELATED=END:PT0S",
+			"END:VALARM",
+			"BEGIN:VALARM",
+			"TRIGGER:-P1D",
+			"END:VALARM",
+			"BEGIN:VALARM",
+			"ACTION:EMAIL",
+			"TRIGGER:-PT2H",
+			"END:VALARM",
+			"BEGIN:VALARM",
+			"TRIGGER:-PT1H",
+			"END:VALARM",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\n")))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		require.NoError(t, entries[0].Err)
+		require.Equal(t, baseTime.Add(-10*time.Hour), entries[0].Event.StartAt)
+		require.Equal(t, baseTime.Add(14*time.Hour), entries[0].Event.EndAt)
+
+		require.NoError(t, entries[1].Err)
+		e := entries[1].Event
+		require.Equal(t, 11, entries[1].Line)
+		require.Equal(t, "long title", e.Title)
+		require.Equal(t, "Europe/Berlin", e.TimeZone)
+		require.True(t, baseTime.Add(-time.Hour).Equal(e.StartAt))
+		require.Equal(t, 45*time.Minute, e.EndAt.Sub(e.StartAt))
+		require.Len(t, e.ExDates, 2)
+		require.True(t, baseTime.AddDate(0, 0, 7).Add(-time.Hour).Equal(e.ExDates[0]))
+		require.Equal(t, 24*time.Hour, e.NotifyBefore)
+		require.Equal(t, []storage.Reminder{{Before: 2 * time.Hour, Channel: storage.ChannelEmail}}, e.Reminders)
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		entries, err := Decode(strings.NewReader(strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"BEGIN:VEVENT",
+			"SUMMARY:no uid",
+			"DTSTART:20240304T100000Z",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:bad-start",
+			"DTSTART:tomorrow",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:bad-zone",
+			"DTSTART;TZID=Mars/Olympus:20240304T100000",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:bad-line",
+			"broken line",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n")))
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+		for _, entry := range entries {
+			require.ErrorIs(t, entry.Err, storage.ErrInvalidEvent)
+		}
+		require.Equal(t, "bad-zone", entries[2].Event.UID)
+		require.ErrorContains(t, entries[3].Err, "line 16")
+	})
+
+	t.Run("invalid calendar", func(t *testing.T) {
+		for name, body := range map[string]string{
+			"empty":        "",
+			"not calendar": "BEGIN:VEVENT\nEND:VEVENT",
+			"not closed":   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VEVENT",
+			"mismatched":   "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR",
+			"garbage":      "hello",
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := Decode(strings.NewReader(body))
+				require.ErrorIs(t, err, ErrInvalidCalendar)
+			})
+		}
+	})
+}
+
+func TestDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d time.Duration
+		s string
+	}{
+		{0, "PT0S"},
+		{-15 * time.Minute, "-PT15M"},
+		{26*time.Hour + 5*time.Second, "P1DT2H5S"},
+		{7 * 24 * time.Hour, "P7D"},
+	} {
+		require.Equal(t, tc.s, formatDuration(tc.d))
+		d, err := parseDuration(tc.s)
+		require.NoError(t, err)
+		require.Equal(t, tc.d, d)
+	}
+
+	d, err := parseDuration("+P1W")
+	require.NoError(t, err)
+	require.Equal(t, 7*24*time.Hour, d)
+	for _, s := range []string{"", "P", "-P", "PT", "P1H", "15M"} {
+		_, err := parseDuration(s)
+		require.Error(t, err, s)
+	}
+}
@@ -0,0 +1,153 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	utcLayout      = "20060102T150405Z"
+	floatingLayout = "20060102T150405"
+	dateLayout     = "20060102"
+)
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(utcLayout)
+}
+
+// parseTime parses a DATE-TIME in UTC, in the zone of the TZID parameter or floating, taken as UTC,
+// or a DATE, taken as the midnight in UTC.
+func parseTime(p property) (time.Time, error) {
+	if p.param("VALUE") == "DATE" || len(p.value) == len(dateLayout) {
+		return parseIn(dateLayout, p.value, time.UTC)
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		return parseIn(utcLayout, p.value, time.UTC)
+	}
+	loc := time.UTC
+	if tzid := p.param("TZID"); tzid != "" {
+		var err error
+		if loc, err = storage.LoadLocation(strings.TrimPrefix(tzid, "/")); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return parseIn(floatingLayout, p.value, loc)
+}
+
+func parseIn(layout, value string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date-time %q", value)
+	}
+	return t, nil
+}
+
+// parseTimes parses a comma-separated list of parseTime values sharing parameters.
+func parseTimes(p property) ([]time.Time, error) {
+	var times []time.Time
+	for _, value := range strings.Split(p.value, ",") {
+		item := p
+		item.value = value
+		t, err := parseTime(item)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// formatDuration formats d as a DURATION value like -PT15M, fractions of a second are dropped.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	seconds := int64(d / time.Second)
+	if seconds == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	b.WriteString(sign + "P")
+	if days := seconds / 86400; days > 0 {
+		b.WriteString(strconv.FormatInt(days, 10) + "D")
+	}
+	if seconds%86400 == 0 {
+		return b.String()
+	}
+	rest := seconds % 86400
+	b.WriteString("T")
+	if h := rest / 3600; h > 0 {
+		b.WriteString(strconv.FormatInt(h, 10) + "H")
+	}
+	if m := rest % 3600 / 60; m > 0 {
+		b.WriteString(strconv.FormatInt(m, 10) + "M")
+	}
+	if s := rest % 60; s > 0 {
+		b.WriteString(strconv.FormatInt(s, 10) + "S")
+	}
+	return b.String()
+}
+
+var durationRe = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+var errInvalidDuration = errors.New("invalid duration")
+
+// parseDuration parses a DURATION value like P1DT2H or -PT15M.
+func parseDuration(s string) (time.Duration, error) {
+	m := durationRe.FindStringSubmatch(s)
+	if m == nil || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("%w %q", errInvalidDuration, s)
+	}
+	var d time.Duration
+	empty := true
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+2] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+2], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("%w %q", errInvalidDuration, s)
+		}
+		d += time.Duration(n) * unit
+		empty = false
+	}
+	if empty {
+		return 0, fmt.Errorf("%w %q", errInvalidDuration, s)
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,93 @@
+//go:build unix
+
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var _ Lock = (*FileLock)(nil)
+
+// FileLock is an exclusive flock(2) of a file, for replicas running on one host.
+// The kernel releases it when the process holding it exits.
+type FileLock struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// TryAcquire locks the file, creating it if needed, and writes the process ID to it.
+func (l *FileLock) TryAcquire(context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		return true, nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return false, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, fmt.Errorf("lock %s: %w", l.path, err)
+	}
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0) //nolint:errcheck // The ID is informational.
+	}
+	l.file = f
+	return true, nil
+}
+
+// Check fails if the locked file was removed or replaced, since another replica may lock the new one.
+func (l *FileLock) Check(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("lock is not held")
+	}
+
+	held, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(held, current) {
+		return fmt.Errorf("lock file %s was replaced", l.path)
+	}
+	return nil
+}
+
+// Release unlocks the file and leaves it in place, removing it would let two replicas lock different files.
+func (l *FileLock) Release(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+
+	f := l.file
+	l.file = nil
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package leader
+
+import (
+	"context"
+	"errors"
+)
+
+var _ Lock = (*FileLock)(nil)
+
+// FileLock relies on flock(2), which is available on Unix systems only.
+type FileLock struct{}
+
+func NewFileLock(string) *FileLock {
+	return &FileLock{}
+}
+
+func (*FileLock) TryAcquire(context.Context) (bool, error) {
+	return false, errors.ErrUnsupported
+}
+
+func (*FileLock) Check(context.Context) error {
+	return errors.ErrUnsupported
+}
+
+func (*FileLock) Release(context.Context) error {
+	return nil
+}
@@ -0,0 +1,39 @@
+//go:build unix
+
+package leader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "scheduler.lock")
+	first, second := NewFileLock(path), NewFileLock(path)
+
+	acquired, err := first.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	acquired, err = second.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.False(t, acquired)
+	require.NoError(t, first.Check(ctx))
+
+	require.NoError(t, first.Release(ctx))
+	acquired, err = second.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	require.NoError(t, os.Remove(path))
+	require.ErrorContains(t, second.Check(ctx), "no such file")
+	acquired, err = first.TryAcquire(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired, "the new file is not locked")
+	require.NoError(t, second.Release(ctx))
+	require.NoError(t, first.Release(ctx))
+}
@@ -0,0 +1,128 @@
+// Package leader elects one of the replicas of a service to do the work only one of them may do.
+package leader
+
+import (
+	"context"
+	"time"
+)
+
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Lock is held by one replica at a time.
+type Lock interface {
+	// TryAcquire reports whether the lock was acquired, false when another replica holds it.
+	TryAcquire(ctx context.Context) (bool, error)
+	// Check fails unless the lock is still held.
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// Metrics tracks whether the replica is the leader.
+type Metrics interface {
+	SetLeading(leading bool)
+}
+
+// Elector runs the work of the leader while it holds the lock. The lease bounds how long
+// the lock is held unconfirmed: the leader checks it every RenewInterval and steps down once
+// a check fails, standby replicas try to acquire it as often.
+type Elector struct {
+	logger  Logger
+	lock    Lock
+	metrics Metrics
+	lease   time.Duration
+}
+
+func New(logger Logger, lock Lock, metrics Metrics, lease time.Duration) *Elector {
+	return &Elector{logger: logger, lock: lock, metrics: metrics, lease: lease}
+}
+
+// RenewInterval is how often the lock is checked by the leader and tried by standby replicas
+// given the lease. Locks released by the server to a lost leader must keep it for longer.
+func RenewInterval(lease time.Duration) time.Duration {
+	return lease / 3
+}
+
+// Run campaigns until ctx is done. While the lock is held, lead runs with a context
+// canceled once the leadership is lost; the lock is released after lead returns.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	interval := RenewInterval(e.lease)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	e.metrics.SetLeading(false)
+	for {
+		if e.tryAcquire(ctx, interval) {
+			e.leadWhileHeld(ctx, ticker.C, interval, lead)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) tryAcquire(ctx context.Context, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	acquired, err := e.lock.TryAcquire(ctx)
+	if err != nil {
+		e.logger.Warn("failed to acquire leader lock", "err", err)
+		return false
+	}
+	if !acquired {
+		e.logger.Debug("leader lock is held by another replica")
+	}
+	return acquired
+}
+
+func (e *Elector) leadWhileHeld(
+	ctx context.Context, tick <-chan time.Time, timeout time.Duration, lead func(ctx context.Context),
+) {
+	e.logger.Info("became leader")
+	e.metrics.SetLeading(true)
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lead(leadCtx)
+	}()
+
+	for leading := true; leading; {
+		select {
+		case <-ctx.Done():
+			leading = false
+		case <-done:
+			leading = false
+		case <-tick:
+			if err := e.check(ctx, timeout); err != nil {
+				e.logger.Error("leader lock is lost, stepping down", "err", err)
+				leading = false
+			}
+		}
+	}
+	cancel()
+	<-done
+
+	e.metrics.SetLeading(false)
+	releaseCtx, cancelRelease := context.WithTimeout(context.Background(), timeout)
+	defer cancelRelease()
+	if err := e.lock.Release(releaseCtx); err != nil {
+		e.logger.Error("failed to release leader lock", "err", err)
+	}
+	e.logger.Info("stopped leading")
+}
+
+func (e *Elector) check(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return e.lock.Check(ctx)
+}
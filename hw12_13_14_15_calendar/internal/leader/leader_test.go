@@ -0,0 +1,125 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const lease = 30 * time.Millisecond
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+type nopMetrics struct{}
+
+func (nopMetrics) SetLeading(bool) {}
+
+// memLock is a lock shared by the replicas of a test, holder is the replica holding it.
+type memLock struct {
+	mu     sync.Mutex
+	holder *replicaLock
+}
+
+// replicaLock is the lock as one replica sees it, lost makes its checks fail.
+type replicaLock struct {
+	shared *memLock
+	lost   atomic.Bool
+}
+
+func (l *replicaLock) TryAcquire(context.Context) (bool, error) {
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	if l.shared.holder != nil && l.shared.holder != l {
+		return false, nil
+	}
+	l.shared.holder = l
+	return true, nil
+}
+
+func (l *replicaLock) Check(context.Context) error {
+	if l.lost.Load() {
+		return errors.New("connection lost")
+	}
+	return nil
+}
+
+func (l *replicaLock) Release(context.Context) error {
+	l.shared.mu.Lock()
+	defer l.shared.mu.Unlock()
+	if l.shared.holder == l {
+		l.shared.holder = nil
+	}
+	return nil
+}
+
+// replica runs an elector and counts replicas leading at once.
+type replica struct {
+	lock    *replicaLock
+	leading atomic.Bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func startReplica(t *testing.T, shared *memLock, leaders *atomic.Int32, overlaps *atomic.Int32) *replica {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replica{lock: &replicaLock{shared: shared}, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		New(nopLogger{}, r.lock, nopMetrics{}, lease).Run(ctx, func(ctx context.Context) {
+			if leaders.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			r.leading.Store(true)
+			<-ctx.Done()
+			r.leading.Store(false)
+			leaders.Add(-1)
+		})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-r.done
+	})
+	return r
+}
+
+func TestElector(t *testing.T) {
+	t.Run("one leader and failover", func(t *testing.T) {
+		shared := &memLock{}
+		var leaders, overlaps atomic.Int32
+		first := startReplica(t, shared, &leaders, &overlaps)
+		require.Eventually(t, first.leading.Load, time.Second, time.Millisecond)
+		second := startReplica(t, shared, &leaders, &overlaps)
+
+		time.Sleep(2 * lease)
+		require.False(t, second.leading.Load())
+
+		first.cancel()
+		<-first.done
+		require.Eventually(t, second.leading.Load, lease+RenewInterval(lease), time.Millisecond)
+		require.Zero(t, overlaps.Load())
+	})
+
+	t.Run("steps down when the lock is lost", func(t *testing.T) {
+		shared := &memLock{}
+		var leaders, overlaps atomic.Int32
+		r := startReplica(t, shared, &leaders, &overlaps)
+		require.Eventually(t, r.leading.Load, time.Second, time.Millisecond)
+
+		r.lock.lost.Store(true)
+		require.Eventually(t, func() bool { return !r.leading.Load() }, lease, time.Millisecond)
+
+		r.lock.lost.Store(false)
+		require.Eventually(t, r.leading.Load, lease, time.Millisecond, "campaigns again")
+	})
+}
@@ -1,20 +1,143 @@
 package logger
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
 
-type Logger struct { // TODO
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+var (
+	ErrUnknownLevel  = errors.New("unknown log level")
+	ErrUnknownFormat = errors.New("unknown log format")
+)
+
+var levels = map[string]slog.Level{
+	"DEBUG": slog.LevelDebug,
+	"INFO":  slog.LevelInfo,
+	"WARN":  slog.LevelWarn,
+	"ERROR": slog.LevelError,
+}
+
+// Logger writes leveled records with key-value fields, e.g. Info("started", "port", 8080).
+type Logger struct {
+	logger *slog.Logger
+}
+
+// New creates a logger writing records of the level (DEBUG, INFO, WARN, ERROR) and above
+// to stdout in the format (json or text).
+func New(level, format string) (*Logger, error) {
+	return newLogger(level, format, os.Stdout)
+}
+
+func newLogger(level, format string, out io.Writer) (*Logger, error) {
+	lvl, ok := levels[strings.ToUpper(level)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownLevel, level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case FormatJSON, "":
+		h = slog.NewJSONHandler(out, opts)
+	case FormatText:
+		h = slog.NewTextHandler(out, opts)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	return &Logger{logger: slog.New(contextHandler{h})}, nil
+}
+
+func (l *Logger) Debug(msg string, args ...interface{}) {
+	l.logger.Debug(msg, args...)
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.logger.Info(msg, args...)
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	l.logger.Warn(msg, args...)
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.logger.Error(msg, args...)
 }
 
-func New(level string) *Logger {
-	return &Logger{}
+// DebugContext is like Debug but also writes fields attached to ctx with ContextWith.
+func (l *Logger) DebugContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.DebugContext(ctx, msg, args...)
 }
 
-func (l Logger) Info(msg string) {
-	fmt.Println(msg)
+// InfoContext is like Info but also writes fields attached to ctx with ContextWith.
+func (l *Logger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.InfoContext(ctx, msg, args...)
 }
 
-func (l Logger) Error(msg string) {
-	// TODO
+// WarnContext is like Warn but also writes fields attached to ctx with ContextWith.
+func (l *Logger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.WarnContext(ctx, msg, args...)
 }
 
-// TODO
+// ErrorContext is like Error but also writes fields attached to ctx with ContextWith.
+func (l *Logger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.logger.ErrorContext(ctx, msg, args...)
+}
+
+// With returns a logger that writes the fields with every record.
+func (l *Logger) With(args ...interface{}) *Logger {
+	return &Logger{logger: l.logger.With(args...)}
+}
+
+// ContextWith returns a copy of ctx carrying the fields, e.g. a request id, in addition
+// to ones already attached. The *Context methods write them with every record.
+func (l *Logger) ContextWith(ctx context.Context, args ...interface{}) context.Context {
+	attrs := append(fieldsFrom(ctx), argsToAttrs(args)...)
+	return context.WithValue(ctx, fieldsKey{}, attrs)
+}
+
+type fieldsKey struct{}
+
+func fieldsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(fieldsKey{}).([]slog.Attr)
+	return attrs[:len(attrs):len(attrs)]
+}
+
+func argsToAttrs(args []interface{}) []slog.Attr {
+	var attrs []slog.Attr
+	r := slog.Record{}
+	r.Add(args...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// contextHandler adds fields attached with ContextWith to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(fieldsFrom(ctx)...)
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
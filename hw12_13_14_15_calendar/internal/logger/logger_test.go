@@ -1,7 +1,112 @@
 package logger
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &rec))
+		records = append(records, rec)
+	}
+	return records
+}
 
 func TestLogger(t *testing.T) {
-	// TODO
+	t.Run("level filtering", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l, err := newLogger("warn", FormatJSON, buf)
+		require.NoError(t, err)
+
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+		l.Error("error")
+
+		records := decodeRecords(t, buf)
+		require.Len(t, records, 2)
+		require.Equal(t, "WARN", records[0]["level"])
+		require.Equal(t, "warn", records[0]["msg"])
+		require.Equal(t, "ERROR", records[1]["level"])
+	})
+
+	t.Run("key-value fields", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l, err := newLogger("DEBUG", FormatJSON, buf)
+		require.NoError(t, err)
+
+		l.Debug("request", "method", "GET", "status", 200, "err", errors.New("boom"))
+
+		records := decodeRecords(t, buf)
+		require.Len(t, records, 1)
+		require.Equal(t, "GET", records[0]["method"])
+		require.EqualValues(t, 200, records[0]["status"])
+		require.Equal(t, "boom", records[0]["err"])
+	})
+
+	t.Run("text format", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l, err := newLogger("INFO", FormatText, buf)
+		require.NoError(t, err)
+
+		l.Info("hello", "user", "u1")
+
+		require.Contains(t, buf.String(), "level=INFO")
+		require.Contains(t, buf.String(), "msg=hello")
+		require.Contains(t, buf.String(), "user=u1")
+	})
+
+	t.Run("with fields", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l, err := newLogger("INFO", FormatJSON, buf)
+		require.NoError(t, err)
+
+		l.With("component", "scheduler").Info("tick")
+		l.Info("plain")
+
+		records := decodeRecords(t, buf)
+		require.Equal(t, "scheduler", records[0]["component"])
+		require.NotContains(t, records[1], "component")
+	})
+
+	t.Run("context fields", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		l, err := newLogger("INFO", FormatJSON, buf)
+		require.NoError(t, err)
+
+		ctx := l.ContextWith(context.Background(), "request_id", "r1")
+		childCtx := l.ContextWith(ctx, "user_id", "u1")
+		l.InfoContext(childCtx, "child")
+		l.ErrorContext(ctx, "parent")
+		l.Info("no context")
+
+		records := decodeRecords(t, buf)
+		require.Len(t, records, 3)
+		require.Equal(t, "r1", records[0]["request_id"])
+		require.Equal(t, "u1", records[0]["user_id"])
+		require.Equal(t, "r1", records[1]["request_id"])
+		require.NotContains(t, records[1], "user_id")
+		require.NotContains(t, records[2], "request_id")
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		_, err := newLogger("LOUD", FormatJSON, &bytes.Buffer{})
+		require.ErrorIs(t, err, ErrUnknownLevel)
+
+		_, err = newLogger("INFO", "xml", &bytes.Buffer{})
+		require.ErrorIs(t, err, ErrUnknownFormat)
+	})
 }
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GRPC collects statistics of gRPC calls by method and status code.
+type GRPC struct {
+	calls   *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+	limited *prometheus.CounterVec
+}
+
+func NewGRPC(reg prometheus.Registerer) *GRPC {
+	m := &GRPC{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "calls_total",
+			Help:      "Number of gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "call_duration_seconds",
+			Help:      "Latency of gRPC calls by method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "grpc",
+			Name:      "rate_limited_total",
+			Help:      "Number of gRPC calls rejected by the rate limiter by method and call class.",
+		}, []string{"method", "class"}),
+	}
+	reg.MustRegister(m.calls, m.latency, m.limited)
+	return m
+}
+
+// ObserveCall records a call of the full method name, e.g. /event.EventService/CreateEvent.
+func (m *GRPC) ObserveCall(method, code string, latency time.Duration) {
+	m.calls.WithLabelValues(method, code).Inc()
+	m.latency.WithLabelValues(method, code).Observe(latency.Seconds())
+}
+
+// ObserveRateLimited records a call of the method of the class ("read" or "write") rejected
+// by the rate limiter.
+func (m *GRPC) ObserveRateLimited(method, class string) {
+	m.limited.WithLabelValues(method, class).Inc()
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP collects statistics of HTTP requests by route and response status.
+type HTTP struct {
+	gatherer prometheus.Gatherer
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	limited  *prometheus.CounterVec
+}
+
+func NewHTTP(reg *prometheus.Registry) *HTTP {
+	m := &HTTP{
+		gatherer: reg,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of HTTP requests by route and response status.",
+		}, []string{"route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of HTTP requests by route and response status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "status"}),
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "rate_limited_total",
+			Help:      "Number of HTTP requests rejected by the rate limiter by route and request class.",
+		}, []string{"route", "class"}),
+	}
+	reg.MustRegister(m.requests, m.latency, m.limited)
+	return m
+}
+
+// ObserveRequest records a request served by route, which is a ServeMux pattern such as
+// "GET /events/{id}" or empty for requests that matched no route.
+func (m *HTTP) ObserveRequest(route string, status int, latency time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(route, code).Inc()
+	m.latency.WithLabelValues(route, code).Observe(latency.Seconds())
+}
+
+// ObserveRateLimited records a request to route of the class ("read" or "write") rejected
+// by the rate limiter.
+func (m *HTTP) ObserveRateLimited(route, class string) {
+	m.limited.WithLabelValues(route, class).Inc()
+}
+
+// Handler exposes all metrics of the registry the collector is registered with.
+func (m *HTTP) Handler() http.Handler {
+	return Handler(m.gatherer)
+}
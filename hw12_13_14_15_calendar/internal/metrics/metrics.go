@@ -0,0 +1,63 @@
+// Package metrics collects Prometheus metrics of the calendar services.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes names of all metrics, e.g. calendar_http_requests_total.
+const namespace = "calendar"
+
+// Path is where metrics are exposed.
+const Path = "/metrics"
+
+// NewRegistry returns a registry with Go runtime and process metrics.
+func NewRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// Handler exposes metrics gathered from g in the Prometheus text format.
+func Handler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
+
+// Server exposes metrics at Path and serves other requests with probes, for services without an HTTP API.
+type Server struct {
+	server *http.Server
+}
+
+func NewServer(addr string, g prometheus.Gatherer, probes http.Handler) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+Path, Handler(g))
+	mux.Handle("/", probes)
+	return &Server{server: &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}}
+}
+
+// Start serves requests until Stop is called.
+func (s *Server) Start() error {
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Stop gracefully shuts the server down waiting for active requests until ctx is done.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
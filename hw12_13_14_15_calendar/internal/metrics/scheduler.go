@@ -0,0 +1,62 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Scheduler counts the work done by the scheduler and tells whether the replica is the leader.
+type Scheduler struct {
+	published    prometheus.Counter
+	deduplicated prometheus.Counter
+	purged       prometheus.Counter
+	leader       prometheus.Gauge
+}
+
+func NewScheduler(reg prometheus.Registerer) *Scheduler {
+	m := &Scheduler{
+		published: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "notifications_published_total",
+			Help:      "Number of notifications published to the queue.",
+		}),
+		deduplicated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "notifications_deduplicated_total",
+			Help:      "Number of due notifications skipped as already in the outbox.",
+		}),
+		purged: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "events_purged_total",
+			Help:      "Number of old events deleted.",
+		}),
+		leader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "scheduler",
+			Name:      "leader",
+			Help:      "1 while the replica is the leader, 0 while it is a standby.",
+		}),
+	}
+	reg.MustRegister(m.published, m.deduplicated, m.purged, m.leader)
+	return m
+}
+
+func (m *Scheduler) NotificationPublished() {
+	m.published.Inc()
+}
+
+func (m *Scheduler) NotificationsDeduplicated(n int) {
+	m.deduplicated.Add(float64(n))
+}
+
+func (m *Scheduler) EventsPurged(n int64) {
+	m.purged.Add(float64(n))
+}
+
+func (m *Scheduler) SetLeading(leading bool) {
+	if leading {
+		m.leader.Set(1)
+	} else {
+		m.leader.Set(0)
+	}
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Sender counts notification deliveries by sink and result.
+type Sender struct {
+	deliveries *prometheus.CounterVec
+}
+
+func NewSender(reg prometheus.Registerer) *Sender {
+	m := &Sender{
+		deliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sender",
+			Name:      "deliveries_total",
+			Help:      "Number of notification deliveries by sink and result, retries are not counted separately.",
+		}, []string{"sink", "result"}),
+	}
+	reg.MustRegister(m.deliveries)
+	return m
+}
+
+func (m *Sender) Delivered(sink string) {
+	m.deliveries.WithLabelValues(sink, "success").Inc()
+}
+
+func (m *Sender) Failed(sink string) {
+	m.deliveries.WithLabelValues(sink, "failure").Inc()
+}
@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// EventStorage is an events storage instrumented by Storage.
+type EventStorage interface {
+	Connect(ctx context.Context) error
+	Close(ctx context.Context) error
+	Ping(ctx context.Context) error
+	CreateEvent(ctx context.Context, e storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, id string, e storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, id string) error
+	GetEvent(ctx context.Context, id string) (storage.Event, error)
+	ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error)
+	ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error)
+	ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error)
+	ExcludeOccurrence(ctx context.Context, id string, start time.Time, version int64) error
+	DetachOccurrence(ctx context.Context, id string, start time.Time, e storage.Event) (storage.Event, error)
+	GetEventByUID(ctx context.Context, userID, uid string, recurrenceID time.Time) (storage.Event, error)
+	ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+	ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error)
+	RespondToEvent(ctx context.Context, id, userID string, status storage.RSVPStatus) (storage.Event, error)
+	ListChanges(ctx context.Context, userID string, seq int64) (storage.Changes, error)
+	SearchEvents(ctx context.Context, q storage.SearchQuery) ([]storage.SearchHit, error)
+	ListDueReminders(ctx context.Context, from, to time.Time) ([]storage.DueReminder, error)
+	EnqueueNotifications(ctx context.Context, ns []storage.Notification, at time.Time) (int, error)
+	ClaimPendingNotifications(
+		ctx context.Context, claimer string, at, staleBefore time.Time, limit int,
+	) ([]storage.Notification, error)
+	MarkNotificationPublished(ctx context.Context, id string, at time.Time) error
+	CompactOutbox(ctx context.Context, before time.Time) (int64, error)
+	DeleteEventsEndedBefore(ctx context.Context, before time.Time) (int64, error)
+	CompactChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Storage measures the latency of every operation of the wrapped storage and counts its failures.
+// Errors the storage reports by design, such as storage.ErrNotFound, are not failures.
+type Storage struct {
+	EventStorage
+	latency *prometheus.HistogramVec
+	errors  *prometheus.CounterVec
+}
+
+func NewStorage(reg prometheus.Registerer, s EventStorage) *Storage {
+	m := &Storage{
+		EventStorage: s,
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of storage operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_errors_total",
+			Help:      "Number of failed storage operations.",
+		}, []string{"operation"}),
+	}
+	reg.MustRegister(m.latency, m.errors)
+	return m
+}
+
+// observe records the operation started at start, it is deferred with a pointer to the returned error.
+func (m *Storage) observe(operation string, start time.Time, err *error) {
+	m.latency.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if *err != nil && !expected(*err) {
+		m.errors.WithLabelValues(operation).Inc()
+	}
+}
+
+func expected(err error) bool {
+	return errors.Is(err, storage.ErrNotFound) ||
+		errors.Is(err, storage.ErrDateBusy) ||
+		errors.Is(err, storage.ErrInvalidEvent) ||
+		errors.Is(err, storage.ErrUIDTaken) ||
+		errors.Is(err, storage.ErrVersionMismatch) ||
+		errors.Is(err, storage.ErrChangesCompacted)
+}
+
+func (m *Storage) CreateEvent(ctx context.Context, e storage.Event) (_ storage.Event, err error) {
+	defer m.observe("create_event", time.Now(), &err)
+	return m.EventStorage.CreateEvent(ctx, e)
+}
+
+func (m *Storage) UpdateEvent(ctx context.Context, id string, e storage.Event) (_ storage.Event, err error) {
+	defer m.observe("update_event", time.Now(), &err)
+	return m.EventStorage.UpdateEvent(ctx, id, e)
+}
+
+func (m *Storage) DeleteEvent(ctx context.Context, id string) (err error) {
+	defer m.observe("delete_event", time.Now(), &err)
+	return m.EventStorage.DeleteEvent(ctx, id)
+}
+
+func (m *Storage) GetEvent(ctx context.Context, id string) (_ storage.Event, err error) {
+	defer m.observe("get_event", time.Now(), &err)
+	return m.EventStorage.GetEvent(ctx, id)
+}
+
+func (m *Storage) ListDayEvents(ctx context.Context, userID string, date time.Time) (_ []storage.Event, err error) {
+	defer m.observe("list_day_events", time.Now(), &err)
+	return m.EventStorage.ListDayEvents(ctx, userID, date)
+}
+
+func (m *Storage) ListWeekEvents(
+	ctx context.Context, userID string, weekStart time.Time,
+) (_ []storage.Event, err error) {
+	defer m.observe("list_week_events", time.Now(), &err)
+	return m.EventStorage.ListWeekEvents(ctx, userID, weekStart)
+}
+
+func (m *Storage) ListMonthEvents(
+	ctx context.Context, userID string, monthStart time.Time,
+) (_ []storage.Event, err error) {
+	defer m.observe("list_month_events", time.Now(), &err)
+	return m.EventStorage.ListMonthEvents(ctx, userID, monthStart)
+}
+
+func (m *Storage) ExcludeOccurrence(ctx context.Context, id string, start time.Time, version int64) (err error) {
+	defer m.observe("exclude_occurrence", time.Now(), &err)
+	return m.EventStorage.ExcludeOccurrence(ctx, id, start, version)
+}
+
+func (m *Storage) DetachOccurrence(
+	ctx context.Context, id string, start time.Time, e storage.Event,
+) (_ storage.Event, err error) {
+	defer m.observe("detach_occurrence", time.Now(), &err)
+	return m.EventStorage.DetachOccurrence(ctx, id, start, e)
+}
+
+func (m *Storage) GetEventByUID(
+	ctx context.Context, userID, uid string, recurrenceID time.Time,
+) (_ storage.Event, err error) {
+	defer m.observe("get_event_by_uid", time.Now(), &err)
+	return m.EventStorage.GetEventByUID(ctx, userID, uid, recurrenceID)
+}
+
+func (m *Storage) ListEvents(ctx context.Context, userID string, from, to time.Time) (_ []storage.Event, err error) {
+	defer m.observe("list_events", time.Now(), &err)
+	return m.EventStorage.ListEvents(ctx, userID, from, to)
+}
+
+func (m *Storage) ListConflicts(ctx context.Context, e storage.Event) (_ []storage.Event, err error) {
+	defer m.observe("list_conflicts", time.Now(), &err)
+	return m.EventStorage.ListConflicts(ctx, e)
+}
+
+func (m *Storage) RespondToEvent(
+	ctx context.Context, id, userID string, status storage.RSVPStatus,
+) (_ storage.Event, err error) {
+	defer m.observe("respond_to_event", time.Now(), &err)
+	return m.EventStorage.RespondToEvent(ctx, id, userID, status)
+}
+
+func (m *Storage) ListChanges(ctx context.Context, userID string, seq int64) (_ storage.Changes, err error) {
+	defer m.observe("list_changes", time.Now(), &err)
+	return m.EventStorage.ListChanges(ctx, userID, seq)
+}
+
+func (m *Storage) SearchEvents(ctx context.Context, q storage.SearchQuery) (_ []storage.SearchHit, err error) {
+	defer m.observe("search_events", time.Now(), &err)
+	return m.EventStorage.SearchEvents(ctx, q)
+}
+
+func (m *Storage) ListDueReminders(ctx context.Context, from, to time.Time) (_ []storage.DueReminder, err error) {
+	defer m.observe("list_due_reminders", time.Now(), &err)
+	return m.EventStorage.ListDueReminders(ctx, from, to)
+}
+
+func (m *Storage) EnqueueNotifications(
+	ctx context.Context, ns []storage.Notification, at time.Time,
+) (_ int, err error) {
+	defer m.observe("enqueue_notifications", time.Now(), &err)
+	return m.EventStorage.EnqueueNotifications(ctx, ns, at)
+}
+
+func (m *Storage) ClaimPendingNotifications(
+	ctx context.Context, claimer string, at, staleBefore time.Time, limit int,
+) (_ []storage.Notification, err error) {
+	defer m.observe("claim_pending_notifications", time.Now(), &err)
+	return m.EventStorage.ClaimPendingNotifications(ctx, claimer, at, staleBefore, limit)
+}
+
+func (m *Storage) MarkNotificationPublished(ctx context.Context, id string, at time.Time) (err error) {
+	defer m.observe("mark_notification_published", time.Now(), &err)
+	return m.EventStorage.MarkNotificationPublished(ctx, id, at)
+}
+
+func (m *Storage) CompactOutbox(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer m.observe("compact_outbox", time.Now(), &err)
+	return m.EventStorage.CompactOutbox(ctx, before)
+}
+
+func (m *Storage) DeleteEventsEndedBefore(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer m.observe("delete_events_ended_before", time.Now(), &err)
+	return m.EventStorage.DeleteEventsEndedBefore(ctx, before)
+}
+
+func (m *Storage) CompactChanges(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer m.observe("compact_changes", time.Now(), &err)
+	return m.EventStorage.CompactChanges(ctx, before)
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenStorage fails to delete events as if the database were down.
+type brokenStorage struct {
+	*memorystorage.Storage
+}
+
+func (brokenStorage) DeleteEvent(context.Context, string) error {
+	return errors.New("connection refused")
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage(prometheus.NewRegistry(), brokenStorage{memorystorage.New()})
+
+	start := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	e, err := s.CreateEvent(ctx, storage.Event{
+		Title: "meeting", StartAt: start, EndAt: start.Add(time.Hour), UserID: "u1",
+	})
+	require.NoError(t, err)
+	_, err = s.GetEvent(ctx, e.ID)
+	require.NoError(t, err)
+	_, err = s.GetEvent(ctx, "8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	require.Error(t, s.DeleteEvent(ctx, e.ID))
+
+	require.Equal(t, 3, testutil.CollectAndCount(s.latency), "create_event, get_event and delete_event")
+	require.Equal(t, 1, testutil.CollectAndCount(s.errors), "not found is not a failure")
+	require.InDelta(t, 1, testutil.ToFloat64(s.errors.WithLabelValues("delete_event")), 0)
+}
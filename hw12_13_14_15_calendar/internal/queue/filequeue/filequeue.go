@@ -0,0 +1,254 @@
+// Package filequeue is an embedded durable queue keeping every message in its own file.
+// It follows the semantics of the rabbitmq package, so that processes and tests can
+// exchange messages with no broker.
+//
+// A queue is a directory with three subdirectories: messages are written to tmp,
+// moved to ready once synced, and to inflight while being handled. Every move is an atomic
+// rename, so any number of processes may publish to a queue. A queue must have a single
+// consumer process: when it starts consuming it returns messages left in inflight by a crash
+// to ready.
+package filequeue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/google/uuid"
+)
+
+const (
+	tmpDir      = "tmp"
+	readyDir    = "ready"
+	inflightDir = "inflight"
+)
+
+var (
+	_ queue.Publisher = (*Queue)(nil)
+	_ queue.Consumer  = (*Queue)(nil)
+)
+
+type Queue struct {
+	root          string
+	name          string
+	maxDeliveries int
+	pollInterval  time.Duration
+
+	mu      sync.Mutex
+	lastSeq int64
+}
+
+// New returns the queue stored in root/name. Its dead letters are kept in the sibling queue named
+// queue.DeadLetterQueue(name). Consume checks for new messages every pollInterval.
+func New(root, name string, maxDeliveries int, pollInterval time.Duration) *Queue {
+	return &Queue{root: root, name: name, maxDeliveries: maxDeliveries, pollInterval: pollInterval}
+}
+
+// Connect creates the queue directories. Publishers connect too, so it leaves messages
+// in inflight alone: they may be handled by the consumer right now.
+func (q *Queue) Connect(ctx context.Context) error {
+	for _, name := range []string{q.name, queue.DeadLetterQueue(q.name)} {
+		for _, sub := range []string{tmpDir, readyDir, inflightDir} {
+			if err := os.MkdirAll(filepath.Join(q.root, name, sub), 0o755); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close is a no-op, it makes the file queue interchangeable with the rabbitmq one.
+func (q *Queue) Close(ctx context.Context) error {
+	return nil
+}
+
+// Ping checks that the queue directory is accessible, e.g. its volume is still mounted.
+func (q *Queue) Ping(ctx context.Context) error {
+	_, err := os.Stat(filepath.Join(q.root, q.name, readyDir))
+	return err
+}
+
+// Publish returns once the message is synced to disk.
+func (q *Queue) Publish(ctx context.Context, body []byte) error {
+	file := messageName(q.nextSeq(), uuid.NewString()[:8], 0)
+	tmp := filepath.Join(q.root, q.name, tmpDir, file)
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return q.move(tmpDir, file, q.name, readyDir, file)
+}
+
+// Consume handles messages in publishing order until ctx is done. It first returns messages
+// of a crashed consumer to ready.
+func (q *Queue) Consume(ctx context.Context, handle queue.Handler) error {
+	if err := q.recover(); err != nil {
+		return fmt.Errorf("recover inflight messages: %w", err)
+	}
+	for ctx.Err() == nil {
+		file, body, err := q.claim()
+		if err != nil {
+			return err
+		}
+		if file == "" {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(q.pollInterval):
+				continue
+			}
+		}
+
+		if err := q.settle(ctx, file, handle(ctx, body)); err != nil {
+			return fmt.Errorf("acknowledge message: %w", err)
+		}
+	}
+	return nil
+}
+
+// recover moves messages left in inflight by a crashed consumer back to ready.
+func (q *Queue) recover() error {
+	inflight, err := q.list(inflightDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range inflight {
+		if err := q.move(inflightDir, file, q.name, readyDir, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claim moves the oldest ready message to inflight and returns it. Empty file means no messages.
+func (q *Queue) claim() (file string, body []byte, err error) {
+	ready, err := q.list(readyDir)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, file := range ready {
+		err := q.move(readyDir, file, q.name, inflightDir, file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		body, err := os.ReadFile(filepath.Join(q.root, q.name, inflightDir, file))
+		return file, body, err
+	}
+	return "", nil, nil
+}
+
+// settle acknowledges the inflight message according to the result of its handling.
+func (q *Queue) settle(ctx context.Context, file string, handleErr error) error {
+	if handleErr == nil {
+		return os.Remove(filepath.Join(q.root, q.name, inflightDir, file))
+	}
+	if ctx.Err() != nil {
+		// Shutdown is not a failure of the message, it is redelivered as is.
+		return q.move(inflightDir, file, q.name, readyDir, file)
+	}
+
+	seq, id, deliveries, err := parseMessageName(file)
+	if err != nil {
+		return err
+	}
+	deliveries++
+	if errors.Is(handleErr, queue.ErrRejected) || deliveries >= q.maxDeliveries {
+		return q.move(inflightDir, file, queue.DeadLetterQueue(q.name), readyDir, messageName(seq, id, deliveries))
+	}
+	return q.move(inflightDir, file, q.name, readyDir, messageName(seq, id, deliveries))
+}
+
+// list returns message files of the subdirectory in publishing order.
+func (q *Queue) list(sub string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(q.root, q.name, sub))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// move renames a message file of the queue into a subdirectory of the target queue and syncs the target.
+func (q *Queue) move(fromSub, fromFile, toQueue, toSub, toFile string) error {
+	toDir := filepath.Join(q.root, toQueue, toSub)
+	if err := os.Rename(filepath.Join(q.root, q.name, fromSub, fromFile), filepath.Join(toDir, toFile)); err != nil {
+		return err
+	}
+	return syncDir(toDir)
+}
+
+// nextSeq returns a publishing timestamp that is unique within the process.
+func (q *Queue) nextSeq() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastSeq = max(q.lastSeq+1, time.Now().UnixNano())
+	return q.lastSeq
+}
+
+// messageName orders messages by seq; id makes it unique across processes.
+func messageName(seq int64, id string, deliveries int) string {
+	return fmt.Sprintf("%020d-%s.%d", seq, id, deliveries)
+}
+
+func parseMessageName(name string) (seq int64, id string, deliveries int, err error) {
+	base, count, ok := strings.Cut(name, ".")
+	if !ok {
+		return 0, "", 0, fmt.Errorf("malformed message file name %q", name)
+	}
+	rawSeq, id, ok := strings.Cut(base, "-")
+	if !ok {
+		return 0, "", 0, fmt.Errorf("malformed message file name %q", name)
+	}
+	if seq, err = strconv.ParseInt(rawSeq, 10, 64); err != nil {
+		return 0, "", 0, fmt.Errorf("malformed message file name %q: %w", name, err)
+	}
+	if deliveries, err = strconv.Atoi(count); err != nil {
+		return 0, "", 0, fmt.Errorf("malformed message file name %q: %w", name, err)
+	}
+	return seq, id, deliveries, nil
+}
+
+// syncDir makes a rename into dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
@@ -0,0 +1,174 @@
+package filequeue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/stretchr/testify/require"
+)
+
+const name = "notifications"
+
+func newQueue(t *testing.T, root string) *Queue {
+	t.Helper()
+	q := New(root, name, 3, time.Millisecond)
+	require.NoError(t, q.Connect(context.Background()))
+	return q
+}
+
+// consume runs the consumer until it has settled n messages and returns their bodies.
+func consume(t *testing.T, q *Queue, n int, handle queue.Handler) []string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+	)
+	done := make(chan error)
+	go func() {
+		done <- q.Consume(ctx, func(ctx context.Context, body []byte) error {
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			mu.Unlock()
+			return handle(ctx, body)
+		})
+	}()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		inflight, err := q.list(inflightDir)
+		return err == nil && len(inflight) == 0 && len(bodies) == n
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	return bodies
+}
+
+func ack(context.Context, []byte) error {
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivers in publishing order and removes acknowledged", func(t *testing.T) {
+		root := t.TempDir()
+		q := newQueue(t, root)
+		for _, body := range []string{"first", "second", "third"} {
+			require.NoError(t, q.Publish(ctx, []byte(body)))
+		}
+
+		require.Equal(t, []string{"first", "second", "third"}, consume(t, q, 3, ack))
+
+		ready, err := q.list(readyDir)
+		require.NoError(t, err)
+		require.Empty(t, ready)
+		inflight, err := q.list(inflightDir)
+		require.NoError(t, err)
+		require.Empty(t, inflight)
+	})
+
+	t.Run("redelivers failed message and dead letters it after max deliveries", func(t *testing.T) {
+		root := t.TempDir()
+		q := newQueue(t, root)
+		require.NoError(t, q.Publish(ctx, []byte("poison")))
+		require.NoError(t, q.Publish(ctx, []byte("fine")))
+
+		fail := func(_ context.Context, body []byte) error {
+			if string(body) == "poison" {
+				return errors.New("cannot handle")
+			}
+			return nil
+		}
+		require.Equal(t, []string{"poison", "poison", "poison", "fine"}, consume(t, q, 4, fail))
+
+		dlq := New(root, queue.DeadLetterQueue(name), 3, time.Millisecond)
+		require.NoError(t, dlq.Connect(ctx))
+		require.Equal(t, []string{"poison"}, consume(t, dlq, 1, ack))
+	})
+
+	t.Run("dead letters rejected message at once", func(t *testing.T) {
+		root := t.TempDir()
+		q := newQueue(t, root)
+		require.NoError(t, q.Publish(ctx, []byte("malformed")))
+
+		reject := func(context.Context, []byte) error {
+			return queue.Reject(errors.New("malformed"))
+		}
+		require.Equal(t, []string{"malformed"}, consume(t, q, 1, reject))
+
+		dead, err := os.ReadDir(filepath.Join(root, queue.DeadLetterQueue(name), readyDir))
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+	})
+
+	t.Run("shutdown does not count as delivery", func(t *testing.T) {
+		root := t.TempDir()
+		q := newQueue(t, root)
+		require.NoError(t, q.Publish(ctx, []byte("interrupted")))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		err := q.Consume(cancelled, func(ctx context.Context, _ []byte) error {
+			cancel()
+			return ctx.Err()
+		})
+		require.NoError(t, err)
+
+		ready, err := q.list(readyDir)
+		require.NoError(t, err)
+		require.Len(t, ready, 1)
+		_, _, deliveries, err := parseMessageName(ready[0])
+		require.NoError(t, err)
+		require.Zero(t, deliveries)
+	})
+
+	t.Run("messages survive restart", func(t *testing.T) {
+		root := t.TempDir()
+		q := newQueue(t, root)
+		require.NoError(t, q.Publish(ctx, []byte("pending")))
+		require.NoError(t, q.Publish(ctx, []byte("crashed")))
+
+		// A consumer crashed while handling the second message.
+		file, _, err := q.claim()
+		require.NoError(t, err)
+		require.NoError(t, q.move(inflightDir, file, name, readyDir, file))
+		_, _, err = q.claim()
+		require.NoError(t, err)
+
+		restarted := newQueue(t, root)
+		require.Equal(t, []string{"pending", "crashed"}, consume(t, restarted, 2, ack))
+	})
+
+	t.Run("publisher connecting does not take inflight message", func(t *testing.T) {
+		root := t.TempDir()
+		q := newQueue(t, root)
+		require.NoError(t, q.Publish(ctx, []byte("handled")))
+
+		// A scheduler starts while the sender handles the message.
+		connect := func(ctx context.Context, _ []byte) error {
+			return New(root, name, 0, 0).Connect(ctx)
+		}
+		require.Equal(t, []string{"handled"}, consume(t, q, 1, connect))
+
+		ready, err := q.list(readyDir)
+		require.NoError(t, err)
+		require.Empty(t, ready, "acknowledged")
+	})
+
+	t.Run("consume stops with context", func(t *testing.T) {
+		q := newQueue(t, t.TempDir())
+		cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		require.NoError(t, q.Consume(cancelled, ack))
+	})
+}
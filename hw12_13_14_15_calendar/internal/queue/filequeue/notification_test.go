@@ -0,0 +1,117 @@
+package filequeue_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue/filequeue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/scheduler"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/sender"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+type failingSink struct{}
+
+func (failingSink) Name() string {
+	return "failing"
+}
+
+func (failingSink) Send(context.Context, storage.Notification) error {
+	return errors.New("unavailable")
+}
+
+// startPath runs the scheduler and the sender in separate goroutines connected by a file queue,
+// the way their processes are connected in deployment.
+func startPath(t *testing.T, events *memorystorage.Storage, root string, sink sender.Sink) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	publisher := filequeue.New(root, "notifications", 0, 0)
+	require.NoError(t, publisher.Connect(ctx))
+	consumer := filequeue.New(root, "notifications", 2, time.Millisecond)
+	require.NoError(t, consumer.Connect(ctx))
+
+	registry := prometheus.NewRegistry()
+	interval := 10 * time.Millisecond
+	go scheduler.New(nopLogger{}, events, publisher, metrics.NewScheduler(registry), interval, interval).Run(ctx)
+	retry := sender.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	s := sender.New(nopLogger{}, consumer, []sender.Sink{sink}, metrics.NewSender(registry), retry, time.Hour)
+	go s.Run(ctx) //nolint:errcheck
+}
+
+func createDueEvent(t *testing.T, events *memorystorage.Storage) storage.Event {
+	t.Helper()
+	start := time.Now().Add(time.Hour)
+	e, err := events.CreateEvent(context.Background(), storage.Event{
+		Title:        "meeting",
+		StartAt:      start,
+		EndAt:        start.Add(time.Hour),
+		UserID:       "u1",
+		NotifyBefore: time.Hour - 50*time.Millisecond,
+	})
+	require.NoError(t, err)
+	return e
+}
+
+func TestNotificationPath(t *testing.T) {
+	t.Run("notification reaches sink", func(t *testing.T) {
+		root := t.TempDir()
+		out := filepath.Join(root, "notifications.jsonl")
+		events := memorystorage.New()
+		e := createDueEvent(t, events)
+
+		startPath(t, events, root, sender.NewFileSink(out))
+
+		require.Eventually(t, func() bool {
+			data, err := os.ReadFile(out)
+			return err == nil && len(data) > 0
+		}, 2*time.Second, 10*time.Millisecond)
+
+		data, err := os.ReadFile(out)
+		require.NoError(t, err)
+		var got storage.Notification
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(string(data))), &got))
+		due := storage.DueReminder{Event: e, Reminder: storage.Reminder{Before: e.NotifyBefore}}
+		require.Equal(t, storage.NewNotification(due, e.UserID), got)
+	})
+
+	t.Run("undeliverable notification is dead lettered", func(t *testing.T) {
+		root := t.TempDir()
+		events := memorystorage.New()
+		e := createDueEvent(t, events)
+
+		startPath(t, events, root, failingSink{})
+
+		dlq := filequeue.New(root, queue.DeadLetterQueue("notifications"), 1, time.Millisecond)
+		require.NoError(t, dlq.Connect(context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		var got storage.Notification
+		err := dlq.Consume(ctx, func(_ context.Context, body []byte) error {
+			defer cancel()
+			return json.Unmarshal(body, &got)
+		})
+		require.NoError(t, err)
+		require.Equal(t, e.ID, got.EventID)
+	})
+}
@@ -0,0 +1,38 @@
+// Package queue defines how calendar processes pass messages to each other.
+// Implementations live in subpackages and share the same delivery semantics:
+// a message is acknowledged when its handler succeeds, redelivered when it fails,
+// and moved to the dead letter queue once it is rejected or runs out of deliveries.
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrRejected marks handler errors that redelivery cannot fix.
+var ErrRejected = errors.New("message is rejected")
+
+type Publisher interface {
+	// Publish returns once the message is stored durably.
+	Publish(ctx context.Context, body []byte) error
+}
+
+// Handler processes a message. A nil error acknowledges the message, any other one negatively
+// acknowledges it. A failure caused by cancellation of ctx does not count as a delivery.
+type Handler = func(ctx context.Context, body []byte) error
+
+type Consumer interface {
+	// Consume passes messages to handle one at a time until ctx is done.
+	Consume(ctx context.Context, handle Handler) error
+}
+
+// Reject wraps err so that the message is dead lettered without redelivery.
+func Reject(err error) error {
+	return fmt.Errorf("%w: %w", ErrRejected, err)
+}
+
+// DeadLetterQueue returns the name of the queue receiving dead letters of the named one.
+func DeadLetterQueue(name string) string {
+	return name + ".dlq"
+}
@@ -0,0 +1,113 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// deliveriesHeader counts failed deliveries of a message.
+const deliveriesHeader = "x-deliveries"
+
+var ErrDeliveriesClosed = errors.New("deliveries channel is closed by broker")
+
+var _ queue.Consumer = (*Consumer)(nil)
+
+// Consumer reads messages from a queue one at a time with manual acknowledgements.
+// A failed message is published again to the tail of the queue with its delivery counter
+// increased, and to the dead letter queue once the counter reaches maxDeliveries.
+type Consumer struct {
+	uri           string
+	queue         string
+	maxDeliveries int
+	conn          *amqp.Connection
+	ch            *amqp.Channel
+}
+
+func NewConsumer(uri, queue string, maxDeliveries int) *Consumer {
+	return &Consumer{uri: uri, queue: queue, maxDeliveries: maxDeliveries}
+}
+
+func (c *Consumer) Connect(ctx context.Context) error {
+	conn, ch, err := dial(c.uri, c.queue, queue.DeadLetterQueue(c.queue))
+	if err != nil {
+		return err
+	}
+	// The broker keeps the rest of the queue until the current message is handled.
+	if err := ch.Qos(1, 0, false); err != nil {
+		conn.Close()
+		return fmt.Errorf("set prefetch: %w", err)
+	}
+	c.conn, c.ch = conn, ch
+	return nil
+}
+
+func (c *Consumer) Close(ctx context.Context) error {
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+// Ping checks that the connection to the broker is open.
+func (c *Consumer) Ping(ctx context.Context) error {
+	return ping(c.conn, c.ch)
+}
+
+func (c *Consumer) Consume(ctx context.Context, handle queue.Handler) error {
+	deliveries, err := c.ch.ConsumeWithContext(ctx, c.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("consume queue %q: %w", c.queue, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return ErrDeliveriesClosed
+			}
+			if err := c.settle(ctx, d, handle(ctx, d.Body)); err != nil {
+				return fmt.Errorf("acknowledge message: %w", err)
+			}
+		}
+	}
+}
+
+// settle acknowledges the delivery according to the result of its handling.
+func (c *Consumer) settle(ctx context.Context, d amqp.Delivery, handleErr error) error {
+	if handleErr == nil {
+		return d.Ack(false)
+	}
+	if ctx.Err() != nil {
+		// Shutdown is not a failure of the message, the broker redelivers it as is.
+		return d.Nack(false, true)
+	}
+
+	deliveries := 1
+	if n, ok := d.Headers[deliveriesHeader].(int32); ok {
+		deliveries += int(n)
+	}
+	target := c.queue
+	if errors.Is(handleErr, queue.ErrRejected) || deliveries >= c.maxDeliveries {
+		target = queue.DeadLetterQueue(c.queue)
+	}
+
+	// The copy is confirmed before the original is acknowledged, so the message may be
+	// duplicated by a crash in between but is never lost.
+	err := publish(ctx, c.ch, target, amqp.Publishing{
+		Headers: amqp.Table{deliveriesHeader: int32(deliveries)},
+		Body:    d.Body,
+	})
+	if err != nil {
+		return d.Nack(false, true)
+	}
+	return d.Ack(false)
+}
@@ -0,0 +1,68 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var ErrNotConfirmed = errors.New("message is not confirmed by broker")
+
+var _ queue.Publisher = (*Publisher)(nil)
+
+// Publisher sends persistent messages to a queue and waits for the broker to confirm each of them.
+type Publisher struct {
+	uri   string
+	queue string
+	conn  *amqp.Connection
+	ch    *amqp.Channel
+}
+
+func NewPublisher(uri, queue string) *Publisher {
+	return &Publisher{uri: uri, queue: queue}
+}
+
+func (p *Publisher) Connect(ctx context.Context) error {
+	conn, ch, err := dial(p.uri, p.queue)
+	if err != nil {
+		return err
+	}
+	p.conn, p.ch = conn, ch
+	return nil
+}
+
+func (p *Publisher) Close(ctx context.Context) error {
+	if p.conn == nil {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+// Ping checks that the connection to the broker is open.
+func (p *Publisher) Ping(ctx context.Context) error {
+	return ping(p.conn, p.ch)
+}
+
+// Publish returns once the broker has taken responsibility for the message.
+func (p *Publisher) Publish(ctx context.Context, body []byte) error {
+	return publish(ctx, p.ch, p.queue, amqp.Publishing{Body: body})
+}
+
+func publish(ctx context.Context, ch *amqp.Channel, queue string, msg amqp.Publishing) error {
+	msg.ContentType = "application/json"
+	msg.DeliveryMode = amqp.Persistent
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, msg)
+	if err != nil {
+		return err
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNotConfirmed
+	}
+	return nil
+}
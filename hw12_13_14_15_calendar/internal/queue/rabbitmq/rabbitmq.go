@@ -0,0 +1,43 @@
+// Package rabbitmq implements queue.Publisher and queue.Consumer on top of RabbitMQ.
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var ErrNotConnected = errors.New("not connected to rabbitmq")
+
+// dial opens a channel in confirm mode and declares the durable queues on it, so that either side may start first.
+func dial(uri string, queues ...string) (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(uri)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect to rabbitmq: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("open channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("enable publisher confirms: %w", err)
+	}
+	for _, queue := range queues {
+		if _, err := ch.QueueDeclare(queue, true, false, false, false, nil); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("declare queue %q: %w", queue, err)
+		}
+	}
+	return conn, ch, nil
+}
+
+// ping reports whether the connection and the channel opened by dial are still open.
+func ping(conn *amqp.Connection, ch *amqp.Channel) error {
+	if conn == nil || conn.IsClosed() || ch.IsClosed() {
+		return ErrNotConnected
+	}
+	return nil
+}
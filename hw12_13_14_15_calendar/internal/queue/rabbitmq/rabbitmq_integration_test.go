@@ -0,0 +1,67 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// testURIEnv points to a disposable RabbitMQ broker, e.g. one started with
+// docker run --rm -p 5672:5672 rabbitmq:3.
+const testURIEnv = "CALENDAR_TEST_AMQP_URI"
+
+func TestRabbitMQ(t *testing.T) {
+	uri := os.Getenv(testURIEnv)
+	if uri == "" {
+		t.Skipf("%s is not set", testURIEnv)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	name := "test-" + uuid.NewString()
+	publisher := NewPublisher(uri, name)
+	require.NoError(t, publisher.Connect(ctx))
+	defer publisher.Close(ctx)
+	consumer := NewConsumer(uri, name, 2)
+	require.NoError(t, consumer.Connect(ctx))
+	defer consumer.Close(ctx)
+	dlq := NewConsumer(uri, queue.DeadLetterQueue(name), 1)
+	require.NoError(t, dlq.Connect(ctx))
+	defer dlq.Close(ctx)
+
+	require.NoError(t, publisher.Publish(ctx, []byte("poison")))
+	require.NoError(t, publisher.Publish(ctx, []byte("fine")))
+
+	var handled []string
+	consumeCtx, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() {
+		done <- consumer.Consume(consumeCtx, func(_ context.Context, body []byte) error {
+			handled = append(handled, string(body))
+			if string(body) == "poison" {
+				return errors.New("cannot handle")
+			}
+			return nil
+		})
+	}()
+
+	var dead string
+	dlqCtx, stopDLQ := context.WithCancel(ctx)
+	err := dlq.Consume(dlqCtx, func(_ context.Context, body []byte) error {
+		dead = string(body)
+		stopDLQ()
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "poison", dead)
+
+	stop()
+	require.NoError(t, <-done)
+	require.Equal(t, []string{"poison", "fine", "poison"}, handled)
+}
@@ -0,0 +1,103 @@
+// Package ratelimit limits the rate of requests of every user with token buckets.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Class groups requests limited together, every user has a bucket per class.
+type Class string
+
+const (
+	ClassRead  Class = "read"
+	ClassWrite Class = "write"
+)
+
+// sweepInterval is how often buckets refilled to the burst are dropped to bound memory.
+const sweepInterval = time.Minute
+
+// Limit lets Rate requests per second on average and up to Burst requests at once.
+// A zero Rate means no limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type bucketKey struct {
+	userID string
+	class  Class
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	limits    map[Class]Limit
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New returns a limiter of the classes by their limits, classes without a limit are not limited.
+func New(limits map[Class]Limit) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		buckets: make(map[bucketKey]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the user and class. If the bucket is empty, it returns false
+// and how long to wait for the next token.
+func (l *Limiter) Allow(userID string, class Class) (bool, time.Duration) {
+	limit, ok := l.limits[class]
+	if !ok || limit.Rate <= 0 {
+		return true, 0
+	}
+	burst := math.Max(float64(limit.Burst), 1)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	key := bucketKey{userID: userID, class: class}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have been refilled to the burst, since new ones start full anyway.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		limit := l.limits[key.class]
+		if b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= math.Max(float64(limit.Burst), 1) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RetryAfterSeconds rounds the wait returned by Allow up to whole seconds, at least one,
+// as Retry-After headers require.
+func RetryAfterSeconds(wait time.Duration) int {
+	return int(math.Max(1, math.Ceil(wait.Seconds())))
+}
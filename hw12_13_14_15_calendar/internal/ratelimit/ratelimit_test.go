@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	l := New(map[Class]Limit{ClassWrite: {Rate: 2, Burst: 3}})
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLimiter(t *testing.T) {
+	start := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+
+	t.Run("burst and refill", func(t *testing.T) {
+		now := start
+		l := newTestLimiter(&now)
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("u1", ClassWrite)
+			require.True(t, ok, i)
+		}
+		ok, wait := l.Allow("u1", ClassWrite)
+		require.False(t, ok)
+		require.Equal(t, 500*time.Millisecond, wait)
+
+		now = now.Add(250 * time.Millisecond)
+		ok, wait = l.Allow("u1", ClassWrite)
+		require.False(t, ok)
+		require.Equal(t, 250*time.Millisecond, wait)
+
+		now = now.Add(250 * time.Millisecond)
+		ok, _ = l.Allow("u1", ClassWrite)
+		require.True(t, ok)
+	})
+
+	t.Run("buckets per user and class", func(t *testing.T) {
+		now := start
+		l := newTestLimiter(&now)
+		for i := 0; i < 3; i++ {
+			l.Allow("u1", ClassWrite)
+		}
+		ok, _ := l.Allow("u1", ClassWrite)
+		require.False(t, ok)
+
+		ok, _ = l.Allow("u2", ClassWrite)
+		require.True(t, ok)
+		for i := 0; i < 10; i++ {
+			ok, _ = l.Allow("u1", ClassRead)
+			require.True(t, ok, "unlimited class")
+		}
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		now := start
+		l := newTestLimiter(&now)
+		l.Allow("u1", ClassWrite)
+		l.Allow("u2", ClassWrite)
+		l.Allow("u2", ClassWrite)
+		l.Allow("u2", ClassWrite)
+
+		now = now.Add(sweepInterval)
+		l.Allow("u3", ClassWrite)
+		require.Len(t, l.buckets, 1)
+	})
+
+	t.Run("retry after seconds", func(t *testing.T) {
+		require.Equal(t, 1, RetryAfterSeconds(0))
+		require.Equal(t, 1, RetryAfterSeconds(300*time.Millisecond))
+		require.Equal(t, 2, RetryAfterSeconds(1100*time.Millisecond))
+	})
+}
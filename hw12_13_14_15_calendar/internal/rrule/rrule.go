@@ -0,0 +1,390 @@
+// Package rrule implements a subset of RFC 5545 recurrence rules: FREQ of DAILY, WEEKLY, MONTHLY
+// and YEARLY with INTERVAL, COUNT, UNTIL and BYDAY. BYDAY filters days of DAILY rules, lists days
+// of the week of WEEKLY rules and, optionally with an ordinal like 2MO or -1FR, days of the month
+// of MONTHLY rules. Weeks start on Monday.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+const (
+	untilLayout     = "20060102T150405Z"
+	untilDateLayout = "20060102"
+)
+
+// maxEmptyPeriods stops iteration of rules that produce no more occurrences, like the 5th Monday
+// of a month repeated every 12 months starting from a month without it.
+const maxEmptyPeriods = 1000
+
+// maxCount limits COUNT, as occurrences of such rules are counted from the first one.
+const maxCount = 10000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Weekday is a BYDAY entry. N is the ordinal of the day within a month, negative counting
+// from the month end; zero means every such day.
+type Weekday struct {
+	Day time.Weekday
+	N   int
+}
+
+func (w Weekday) String() string {
+	day := strings.ToUpper(w.Day.String()[:2])
+	if w.N == 0 {
+		return day
+	}
+	return strconv.Itoa(w.N) + day
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []Weekday
+}
+
+// Parse parses a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10", optionally prefixed with "RRULE:".
+func Parse(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(s, "RRULE:"), ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.New("must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && (r.Count < 1 || r.Count > maxCount) {
+				err = fmt.Errorf("must be from 1 to %d", maxCount)
+			}
+		case "UNTIL":
+			r.Until, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "WKST":
+			if !strings.EqualFold(value, "MO") {
+				err = errors.New("only MO is supported")
+			}
+		default:
+			err = errors.New("is not supported")
+		}
+		if err != nil {
+			return Rule{}, fmt.Errorf("%w: %s=%s: %w", ErrInvalidRule, name, value, err)
+		}
+	}
+	return r, r.validate()
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(untilLayout, value); err == nil {
+		return t, nil
+	}
+	// A date includes the whole day.
+	t, err := time.Parse(untilDateLayout, value)
+	if err != nil {
+		return time.Time{}, errors.New("must be a UTC date-time like 20240301T100000Z or a date like 20240301")
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var days []Weekday
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(item)
+		if len(item) < 2 {
+			return nil, fmt.Errorf("unknown day %q", item)
+		}
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", item)
+		}
+		w := Weekday{Day: day}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid ordinal of %q", item)
+			}
+			w.N = n
+		}
+		days = append(days, w)
+	}
+	return days, nil
+}
+
+func (r Rule) validate() error {
+	switch r.Freq {
+	case Daily, Weekly, Monthly, Yearly:
+	case "":
+		return fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	default:
+		return fmt.Errorf("%w: FREQ=%s is not supported", ErrInvalidRule, r.Freq)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	for _, w := range r.ByDay {
+		if w.N != 0 && r.Freq != Monthly {
+			return fmt.Errorf("%w: BYDAY ordinals are supported with FREQ=MONTHLY only", ErrInvalidRule)
+		}
+	}
+	if len(r.ByDay) > 0 && r.Freq == Yearly {
+		return fmt.Errorf("%w: BYDAY is not supported with FREQ=YEARLY", ErrInvalidRule)
+	}
+	return nil
+}
+
+// String formats the rule in canonical form.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, w := range r.ByDay {
+			days = append(days, w.String())
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Finite reports whether the rule has a last occurrence.
+func (r Rule) Finite() bool {
+	return r.Count > 0 || !r.Until.IsZero()
+}
+
+// All returns occurrences of the rule starting at dtstart in chronological order. dtstart itself
+// is always the first occurrence. Occurrences keep the wall clock time of dtstart in its location.
+// The sequence is infinite unless the rule is Finite.
+func (r Rule) All(dtstart time.Time) iter.Seq[time.Time] {
+	return r.Since(dtstart, dtstart)
+}
+
+// Since returns occurrences of All(dtstart) starting at from or later. Unless the rule has COUNT,
+// which needs every occurrence counted, periods before from are skipped without iterating them.
+func (r Rule) Since(dtstart, from time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		n := 0
+		emit := func(t time.Time) bool {
+			if (r.Count > 0 && n >= r.Count) || (!r.Until.IsZero() && t.After(r.Until)) {
+				return false
+			}
+			n++
+			return t.Before(from) || yield(t)
+		}
+
+		first := 0
+		if r.Count == 0 {
+			first = r.periodsBefore(dtstart, from)
+		}
+		if first == 0 && !emit(dtstart) {
+			return
+		}
+		empty := 0
+		for period := first; empty < maxEmptyPeriods; period++ {
+			candidates := r.period(dtstart, period)
+			if len(candidates) == 0 {
+				empty++
+				continue
+			}
+			empty = 0
+			for _, t := range candidates {
+				if t.After(dtstart) && !emit(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Last returns the last occurrence of a Finite rule. Rules with UNTIL are searched back from it
+// in growing windows, so the iteration does not depend on how far dtstart is.
+func (r Rule) Last(dtstart time.Time) time.Time {
+	last := dtstart
+	if r.Until.IsZero() {
+		for t := range r.All(dtstart) {
+			last = t
+		}
+		return last
+	}
+	for periods := 1; ; periods *= 2 {
+		from := r.before(r.Until.In(dtstart.Location()), periods)
+		for t := range r.Since(dtstart, from) {
+			last = t
+		}
+		if last.After(dtstart) || !from.After(dtstart) {
+			return last
+		}
+	}
+}
+
+// periodsBefore returns how many first periods since dtstart surely end before from.
+func (r Rule) periodsBefore(dtstart, from time.Time) int {
+	from = from.In(dtstart.Location())
+	if !from.After(dtstart) {
+		return 0
+	}
+	var elapsed int
+	switch r.Freq {
+	case Daily:
+		elapsed = daysBetween(dtstart, from)
+	case Weekly:
+		elapsed = daysBetween(dtstart, from) / 7
+	case Monthly:
+		elapsed = (from.Year()-dtstart.Year())*12 + int(from.Month()-dtstart.Month())
+	case Yearly:
+		elapsed = from.Year() - dtstart.Year()
+	}
+	return max(0, elapsed/r.Interval-1)
+}
+
+// before returns t moved back by the given number of periods of the rule.
+func (r Rule) before(t time.Time, periods int) time.Time {
+	step := periods * r.Interval
+	switch r.Freq {
+	case Daily:
+		return t.AddDate(0, 0, -step)
+	case Weekly:
+		return t.AddDate(0, 0, -7*step)
+	case Monthly:
+		return t.AddDate(0, -step, 0)
+	default:
+		return t.AddDate(-step, 0, 0)
+	}
+}
+
+// period returns candidate occurrences of the period-th period since dtstart in chronological order.
+func (r Rule) period(dtstart time.Time, period int) []time.Time {
+	y, m, d := dtstart.Date()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, dtstart.Hour(), dtstart.Minute(), dtstart.Second(), dtstart.Nanosecond(),
+			dtstart.Location())
+	}
+	step := period * r.Interval
+
+	switch r.Freq {
+	case Daily:
+		t := at(y, m, d+step)
+		if len(r.ByDay) > 0 && !r.hasDay(t.Weekday()) {
+			return nil
+		}
+		return []time.Time{t}
+	case Weekly:
+		monday := d - (int(dtstart.Weekday())+6)%7 + 7*step
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(y, m, d+7*step)}
+		}
+		result := make([]time.Time, 0, len(r.ByDay))
+		for _, w := range r.ByDay {
+			result = append(result, at(y, m, monday+(int(w.Day)+6)%7))
+		}
+		sortTimes(result)
+		return result
+	case Monthly:
+		first := time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		days := daysIn(first.Year(), first.Month())
+		if len(r.ByDay) == 0 {
+			if d > days {
+				return nil
+			}
+			return []time.Time{at(first.Year(), first.Month(), d)}
+		}
+		var result []time.Time
+		for day := 1; day <= days; day++ {
+			if r.matchesMonthDay(first.AddDate(0, 0, day-1), days) {
+				result = append(result, at(first.Year(), first.Month(), day))
+			}
+		}
+		return result
+	case Yearly:
+		if d > daysIn(y+step, m) {
+			return nil
+		}
+		return []time.Time{at(y+step, m, d)}
+	}
+	return nil
+}
+
+func (r Rule) hasDay(day time.Weekday) bool {
+	for _, w := range r.ByDay {
+		if w.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMonthDay reports whether date, a day of a month with days days, matches BYDAY.
+func (r Rule) matchesMonthDay(date time.Time, days int) bool {
+	for _, w := range r.ByDay {
+		if w.Day != date.Weekday() {
+			continue
+		}
+		switch {
+		case w.N == 0,
+			w.N > 0 && (date.Day()-1)/7+1 == w.N,
+			w.N < 0 && (days-date.Day())/7+1 == -w.N:
+			return true
+		}
+	}
+	return false
+}
+
+// daysBetween returns the number of calendar days from the date of a till the date of b.
+func daysBetween(a, b time.Time) int {
+	date := func(t time.Time) int64 {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix()
+	}
+	return int((date(b) - date(a)) / (24 * 60 * 60))
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+}
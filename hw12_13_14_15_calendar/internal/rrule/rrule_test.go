@@ -0,0 +1,221 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dtstart is Monday, 2024-01-01 10:00 UTC.
+var dtstart = time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+func dates(times ...string) []time.Time {
+	result := make([]time.Time, 0, len(times))
+	for _, s := range times {
+		t, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func first(r Rule, start time.Time, n int) []time.Time {
+	var result []time.Time
+	for t := range r.All(start) {
+		if len(result) == n {
+			break
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		for raw, canonical := range map[string]string{
+			"FREQ=DAILY":                             "FREQ=DAILY",
+			"RRULE:freq=weekly;byday=mo,we":          "FREQ=WEEKLY;BYDAY=MO,WE",
+			"FREQ=MONTHLY;BYDAY=-1FR;INTERVAL=2":     "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR",
+			"FREQ=YEARLY;COUNT=3;INTERVAL=1":         "FREQ=YEARLY;COUNT=3",
+			"FREQ=DAILY;UNTIL=20240105T100000Z":      "FREQ=DAILY;UNTIL=20240105T100000Z",
+			"FREQ=WEEKLY;WKST=MO;UNTIL=20240105":     "FREQ=WEEKLY;UNTIL=20240105T235959Z",
+			"FREQ=MONTHLY;BYDAY=2TU,+3TH,SA;WKST=MO": "FREQ=MONTHLY;BYDAY=2TU,3TH,SA",
+		} {
+			r, err := Parse(raw)
+			require.NoError(t, err, raw)
+			require.Equal(t, canonical, r.String(), raw)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, raw := range []string{
+			"",
+			"INTERVAL=2",
+			"FREQ=HOURLY",
+			"FREQ=DAILY;INTERVAL=0",
+			"FREQ=DAILY;COUNT=-1",
+			"FREQ=DAILY;COUNT=10001",
+			"FREQ=DAILY;COUNT=2;UNTIL=20240105",
+			"FREQ=DAILY;UNTIL=tomorrow",
+			"FREQ=WEEKLY;BYDAY=XX",
+			"FREQ=WEEKLY;BYDAY=2MO",
+			"FREQ=MONTHLY;BYDAY=6MO",
+			"FREQ=YEARLY;BYDAY=MO",
+			"FREQ=DAILY;WKST=SU",
+			"FREQ=DAILY;BYMONTH=1",
+			"FREQ",
+		} {
+			_, err := Parse(raw)
+			require.ErrorIs(t, err, ErrInvalidRule, raw)
+		}
+	})
+}
+
+func TestAll(t *testing.T) {
+	for _, tc := range []struct {
+		rule  string
+		start time.Time
+		want  []time.Time
+	}{
+		{
+			rule: "FREQ=DAILY;INTERVAL=2",
+			want: dates("2024-01-01 10:00", "2024-01-03 10:00", "2024-01-05 10:00", "2024-01-07 10:00"),
+		},
+		{
+			rule: "FREQ=DAILY;BYDAY=SA,SU",
+			want: dates("2024-01-01 10:00", "2024-01-06 10:00", "2024-01-07 10:00", "2024-01-13 10:00"),
+		},
+		{
+			rule: "FREQ=WEEKLY;BYDAY=FR,WE",
+			want: dates("2024-01-01 10:00", "2024-01-03 10:00", "2024-01-05 10:00", "2024-01-10 10:00"),
+		},
+		{
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TU",
+			want: dates("2024-01-01 10:00", "2024-01-02 10:00", "2024-01-15 10:00", "2024-01-16 10:00"),
+		},
+		{
+			rule:  "FREQ=WEEKLY",
+			start: time.Date(2024, time.January, 3, 10, 0, 0, 0, time.UTC),
+			want:  dates("2024-01-03 10:00", "2024-01-10 10:00", "2024-01-17 10:00", "2024-01-24 10:00"),
+		},
+		{
+			// Months without the 31st are skipped.
+			rule:  "FREQ=MONTHLY",
+			start: time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC),
+			want:  dates("2024-01-31 10:00", "2024-03-31 10:00", "2024-05-31 10:00", "2024-07-31 10:00"),
+		},
+		{
+			rule: "FREQ=MONTHLY;BYDAY=-1FR,1MO",
+			want: dates("2024-01-01 10:00", "2024-01-26 10:00", "2024-02-05 10:00", "2024-02-23 10:00"),
+		},
+		{
+			rule: "FREQ=MONTHLY;INTERVAL=3;BYDAY=2TU",
+			want: dates("2024-01-01 10:00", "2024-01-09 10:00", "2024-04-09 10:00", "2024-07-09 10:00"),
+		},
+		{
+			// Only leap years have the 29th of February.
+			rule:  "FREQ=YEARLY",
+			start: time.Date(2024, time.February, 29, 10, 0, 0, 0, time.UTC),
+			want:  dates("2024-02-29 10:00", "2028-02-29 10:00", "2032-02-29 10:00", "2036-02-29 10:00"),
+		},
+		{
+			rule: "FREQ=DAILY;COUNT=2",
+			want: dates("2024-01-01 10:00", "2024-01-02 10:00"),
+		},
+		{
+			rule: "FREQ=WEEKLY;UNTIL=20240115T100000Z",
+			want: dates("2024-01-01 10:00", "2024-01-08 10:00", "2024-01-15 10:00"),
+		},
+		{
+			// Every 7th day is a Tuesday, so the rule has no occurrences but dtstart.
+			rule:  "FREQ=DAILY;INTERVAL=7;BYDAY=MO",
+			start: time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC),
+			want:  dates("2024-01-02 10:00"),
+		},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			r, err := Parse(tc.rule)
+			require.NoError(t, err)
+			start := tc.start
+			if start.IsZero() {
+				start = dtstart
+			}
+			require.Equal(t, tc.want, first(r, start, 4))
+		})
+	}
+
+	t.Run("keeps wall clock across DST", func(t *testing.T) {
+		berlin, err := time.LoadLocation("Europe/Berlin")
+		require.NoError(t, err)
+		r, err := Parse("FREQ=DAILY;COUNT=2")
+		require.NoError(t, err)
+
+		got := first(r, time.Date(2024, time.March, 30, 9, 0, 0, 0, berlin), 2)
+		require.Equal(t, 9, got[1].Hour())
+		require.Equal(t, 23*time.Hour, got[1].Sub(got[0]))
+	})
+}
+
+func TestSince(t *testing.T) {
+	rules := []string{
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=DAILY;BYDAY=SA,SU",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,TU",
+		"FREQ=MONTHLY",
+		"FREQ=MONTHLY;INTERVAL=5;BYDAY=-1FR",
+		"FREQ=YEARLY;INTERVAL=3",
+		"FREQ=DAILY;COUNT=40",
+		"FREQ=WEEKLY;UNTIL=20250301",
+	}
+	start := time.Date(2024, time.January, 31, 10, 0, 0, 0, time.UTC)
+	for _, raw := range rules {
+		r, err := Parse(raw)
+		require.NoError(t, err)
+		for _, from := range []time.Time{start.AddDate(0, 0, -1), start, start.AddDate(0, 0, 17), start.AddDate(1, 2, 3)} {
+			var want, got []time.Time
+			for t := range r.All(start) {
+				if len(want) == 4 || t.After(from.AddDate(10, 0, 0)) {
+					break
+				}
+				if !t.Before(from) {
+					want = append(want, t)
+				}
+			}
+			for t := range r.Since(start, from) {
+				if len(got) == len(want) {
+					break
+				}
+				got = append(got, t)
+			}
+			require.Equal(t, want, got, "%s since %s", raw, from)
+		}
+	}
+}
+
+func TestLast(t *testing.T) {
+	for _, raw := range []string{
+		"FREQ=DAILY;COUNT=3",
+		"FREQ=DAILY;UNTIL=20240105",
+		"FREQ=WEEKLY;BYDAY=MO,FR;UNTIL=20250301",
+		"FREQ=MONTHLY;INTERVAL=7;BYDAY=5MO;UNTIL=20300101",
+		"FREQ=YEARLY;UNTIL=20231231",
+	} {
+		r, err := Parse(raw)
+		require.NoError(t, err)
+		want := dtstart
+		for t := range r.All(dtstart) {
+			want = t
+		}
+		require.Equal(t, want, r.Last(dtstart), raw)
+	}
+
+	t.Run("ancient start", func(t *testing.T) {
+		r, err := Parse("FREQ=DAILY;UNTIL=20240105")
+		require.NoError(t, err)
+		ancient := time.Date(1, time.January, 1, 10, 0, 0, 0, time.UTC)
+		require.Equal(t, dates("2024-01-05 10:00")[0], r.Last(ancient))
+	})
+}
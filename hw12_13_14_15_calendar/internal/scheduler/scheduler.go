@@ -0,0 +1,233 @@
+// Package scheduler periodically publishes notifications of upcoming events through an outbox,
+// purges old events and compacts the change log and the outbox.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
+
+// retentionYears is how long events are kept after they end.
+const retentionYears = 1
+
+// changeRetention is how long changes are kept for incremental sync; clients that have not synced
+// for longer need a full sync.
+const changeRetention = 30 * 24 * time.Hour
+
+// MaxLookback limits how far back a starting scheduler looks for notifications it missed.
+const MaxLookback = 24 * time.Hour
+
+// outboxRetention is how long published notifications are kept in the outbox. Ones older than
+// MaxLookback are not needed to drop repeats, since they fell out of every scan window.
+const outboxRetention = 2 * MaxLookback
+
+// outboxBatch is the number of pending notifications claimed from the outbox at once.
+const outboxBatch = 100
+
+// claimTimeout is how long notifications claimed by a scheduler are left to it. A scheduler that
+// lost leadership stops publishing well before that, so its claims are taken over only if it failed.
+const claimTimeout = 5 * time.Minute
+
+type Scheduler struct {
+	// id identifies the claims of the scheduler in the outbox.
+	id        string
+	logger    Logger
+	storage   Storage
+	publisher Publisher
+	metrics   Metrics
+	interval  time.Duration
+	lookback  time.Duration
+
+	// notifiedUntil is the end of the last notification window that was published completely.
+	notifiedUntil time.Time
+}
+
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type Storage interface {
+	ListDueReminders(ctx context.Context, from, to time.Time) ([]storage.DueReminder, error)
+	// EnqueueNotifications adds notifications to the outbox unless they were added before
+	// and returns the number of added ones.
+	EnqueueNotifications(ctx context.Context, ns []storage.Notification, at time.Time) (int, error)
+	// ClaimPendingNotifications claims up to limit pending notifications for the claimer, skipping
+	// ones claimed by others since staleBefore, and returns them in the order they were added.
+	ClaimPendingNotifications(
+		ctx context.Context, claimer string, at, staleBefore time.Time, limit int,
+	) ([]storage.Notification, error)
+	MarkNotificationPublished(ctx context.Context, id string, at time.Time) error
+	CompactOutbox(ctx context.Context, before time.Time) (int64, error)
+	DeleteEventsEndedBefore(ctx context.Context, before time.Time) (int64, error)
+	CompactChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Publisher puts a message into the queue read by the sender.
+type Publisher interface {
+	Publish(ctx context.Context, body []byte) error
+}
+
+// Metrics counts the work done by the scheduler.
+type Metrics interface {
+	NotificationPublished()
+	NotificationsDeduplicated(n int)
+	EventsPurged(n int64)
+}
+
+// New returns a scheduler scanning the storage every interval. A starting scheduler publishes
+// notifications due within lookback, which must not exceed MaxLookback.
+func New(
+	logger Logger, storage Storage, publisher Publisher, metrics Metrics, interval, lookback time.Duration,
+) *Scheduler {
+	return &Scheduler{
+		id:     uuid.NewString(),
+		logger: logger, storage: storage, publisher: publisher, metrics: metrics,
+		interval: interval, lookback: lookback,
+	}
+}
+
+// Run scans the storage every interval until ctx is done. The first scan happens immediately
+// and also covers the preceding lookback, so a restart or a new leader does not lose notifications.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	now := time.Now()
+	s.notifiedUntil = now.Add(-s.lookback)
+	for {
+		s.runOnce(ctx, now)
+
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+// runOnce is a single scan. Failures are logged and retried on the next scan.
+func (s *Scheduler) runOnce(ctx context.Context, now time.Time) {
+	if err := s.enqueue(ctx, now); err != nil {
+		s.logger.Error("failed to enqueue notifications", "err", err)
+	}
+	if err := s.relay(ctx, now); err != nil {
+		s.logger.Error("failed to publish notifications", "err", err)
+	}
+	if err := s.purge(ctx, now); err != nil {
+		s.logger.Error("failed to delete old events", "err", err)
+	}
+	if err := s.compact(ctx, now); err != nil {
+		s.logger.Error("failed to compact changes", "err", err)
+	}
+	if err := s.compactOutbox(ctx, now); err != nil {
+		s.logger.Error("failed to compact notification outbox", "err", err)
+	}
+}
+
+// enqueue adds notifications of reminders firing in [notifiedUntil, now) to owners and accepted
+// attendees of their events to the outbox. The window is moved forward only when all of them are
+// added. The outbox drops notifications added before, so that each is published once even when
+// the window is scanned again after a restart or by a new leader.
+func (s *Scheduler) enqueue(ctx context.Context, now time.Time) error {
+	reminders, err := s.storage.ListDueReminders(ctx, s.notifiedUntil, now)
+	if err != nil {
+		return err
+	}
+	var ns []storage.Notification
+	for _, d := range reminders {
+		for _, userID := range d.Event.Recipients() {
+			ns = append(ns, storage.NewNotification(d, userID))
+		}
+	}
+	if len(ns) > 0 {
+		added, err := s.storage.EnqueueNotifications(ctx, ns, now)
+		if err != nil {
+			return err
+		}
+		if repeated := len(ns) - added; repeated > 0 {
+			s.logger.Debug("notifications already enqueued", "count", repeated)
+			s.metrics.NotificationsDeduplicated(repeated)
+		}
+	}
+	s.notifiedUntil = now
+	return nil
+}
+
+// relay claims pending notifications of the outbox, publishes them in the order they were added
+// and marks them published, stopping at the first failure. Claims keep a former leader still
+// relaying and the new one from publishing the same notifications. A notification is published
+// twice only if a scheduler fails between publishing and marking it; the sender drops the repeat by ID.
+func (s *Scheduler) relay(ctx context.Context, now time.Time) error {
+	published := 0
+	defer func() {
+		if published > 0 {
+			s.logger.Info("notifications published", "count", published)
+		}
+	}()
+
+	for {
+		pending, err := s.storage.ClaimPendingNotifications(ctx, s.id, now, now.Add(-claimTimeout), outboxBatch)
+		if err != nil {
+			return err
+		}
+		for _, n := range pending {
+			body, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			if err := s.publisher.Publish(ctx, body); err != nil {
+				return fmt.Errorf("publish notification %s: %w", n.ID, err)
+			}
+			if err := s.storage.MarkNotificationPublished(ctx, n.ID, now); err != nil {
+				return fmt.Errorf("mark notification %s published: %w", n.ID, err)
+			}
+			s.logger.Debug("notification published", "id", n.ID, "event_id", n.EventID, "user_id", n.UserID)
+			s.metrics.NotificationPublished()
+			published++
+		}
+		if len(pending) < outboxBatch {
+			return nil
+		}
+	}
+}
+
+func (s *Scheduler) purge(ctx context.Context, now time.Time) error {
+	deleted, err := s.storage.DeleteEventsEndedBefore(ctx, now.AddDate(-retentionYears, 0, 0))
+	if err != nil {
+		return err
+	}
+	s.metrics.EventsPurged(deleted)
+	if deleted > 0 {
+		s.logger.Info("old events deleted", "count", deleted)
+	}
+	return nil
+}
+
+func (s *Scheduler) compact(ctx context.Context, now time.Time) error {
+	compacted, err := s.storage.CompactChanges(ctx, now.Add(-changeRetention))
+	if err != nil {
+		return err
+	}
+	if compacted > 0 {
+		s.logger.Info("changes compacted", "count", compacted)
+	}
+	return nil
+}
+
+func (s *Scheduler) compactOutbox(ctx context.Context, now time.Time) error {
+	compacted, err := s.storage.CompactOutbox(ctx, now.Add(-outboxRetention))
+	if err != nil {
+		return err
+	}
+	if compacted > 0 {
+		s.logger.Info("notification outbox compacted", "count", compacted)
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+type counters struct {
+	published    int
+	deduplicated int
+	purged       int64
+}
+
+func (c *counters) NotificationPublished()          { c.published++ }
+func (c *counters) NotificationsDeduplicated(n int) { c.deduplicated += n }
+func (c *counters) EventsPurged(n int64)            { c.purged += n }
+
+// queue is an in-process stand-in for the message broker, delay slows down publishing.
+type queue struct {
+	mu       sync.Mutex
+	messages [][]byte
+	err      error
+	delay    time.Duration
+}
+
+func (q *queue) Publish(_ context.Context, body []byte) error {
+	time.Sleep(q.delay)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return q.err
+	}
+	q.messages = append(q.messages, body)
+	return nil
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
+
+func (q *queue) notifications(t *testing.T) []storage.Notification {
+	t.Helper()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make([]storage.Notification, 0, len(q.messages))
+	for _, body := range q.messages {
+		var n storage.Notification
+		require.NoError(t, json.Unmarshal(body, &n))
+		result = append(result, n)
+	}
+	return result
+}
+
+func createEvent(t *testing.T, s *memorystorage.Storage, start time.Time, notifyBefore time.Duration) storage.Event {
+	t.Helper()
+	e, err := s.CreateEvent(context.Background(), storage.Event{
+		Title:        "event at " + start.Format(time.RFC3339),
+		StartAt:      start,
+		EndAt:        start.Add(time.Hour),
+		UserID:       "u1",
+		NotifyBefore: notifyBefore,
+	})
+	require.NoError(t, err)
+	return e
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("publishes due notifications once", func(t *testing.T) {
+		events := memorystorage.New()
+		due := createEvent(t, events, baseTime.Add(time.Hour), time.Hour)
+		later := createEvent(t, events, baseTime.Add(3*time.Hour), time.Hour)
+		createEvent(t, events, baseTime.Add(5*time.Hour), 0)
+
+		q := &queue{}
+		c := &counters{}
+		s := New(nopLogger{}, events, q, c, time.Minute, time.Minute)
+		s.notifiedUntil = baseTime.Add(-time.Minute)
+
+		s.runOnce(ctx, baseTime.Add(time.Second))
+		s.runOnce(ctx, baseTime.Add(time.Minute))
+		require.Equal(t, []storage.Notification{{
+			ID:      due.ID + ":" + strconv.FormatInt(due.StartAt.UnixMicro(), 10) + ":3600000000000::u1",
+			EventID: due.ID,
+			Title:   due.Title,
+			StartAt: due.StartAt,
+			UserID:  "u1",
+		}}, q.notifications(t))
+
+		s.runOnce(ctx, baseTime.Add(2*time.Hour+time.Second))
+		got := q.notifications(t)
+		require.Len(t, got, 2)
+		require.Equal(t, later.ID, got[1].EventID)
+		require.Equal(t, 2, c.published)
+	})
+
+	t.Run("notifies accepted attendees", func(t *testing.T) {
+		events := memorystorage.New()
+		e := storage.Event{
+			Title:        "planning",
+			StartAt:      baseTime.Add(time.Hour),
+			EndAt:        baseTime.Add(2 * time.Hour),
+			UserID:       "u1",
+			NotifyBefore: time.Hour,
+			Attendees: []storage.Attendee{
+				{UserID: "u2", Status: storage.RSVPAccepted},
+				{UserID: "u3", Status: storage.RSVPDeclined},
+				{UserID: "u4", Status: storage.RSVPTentative},
+				{UserID: "u5"},
+				{UserID: "u6", Status: storage.RSVPAccepted},
+			},
+		}
+		_, err := events.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		q := &queue{}
+		s := New(nopLogger{}, events, q, &counters{}, time.Minute, time.Minute)
+		s.notifiedUntil = baseTime.Add(-time.Minute)
+		s.runOnce(ctx, baseTime.Add(time.Second))
+
+		var users []string
+		for _, n := range q.notifications(t) {
+			users = append(users, n.UserID)
+		}
+		require.Equal(t, []string{"u1", "u2", "u6"}, users)
+	})
+
+	t.Run("retries publishing after broker failure", func(t *testing.T) {
+		events := memorystorage.New()
+		due := createEvent(t, events, baseTime.Add(time.Hour), time.Hour)
+
+		q := &queue{err: errors.New("broker is down")}
+		s := New(nopLogger{}, events, q, &counters{}, time.Minute, time.Minute)
+		s.notifiedUntil = baseTime.Add(-time.Minute)
+
+		s.runOnce(ctx, baseTime.Add(time.Second))
+		require.Empty(t, q.notifications(t))
+		pending, err := events.ClaimPendingNotifications(ctx, s.id, baseTime, baseTime, outboxBatch)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+
+		q.err = nil
+		s.runOnce(ctx, baseTime.Add(time.Minute))
+		got := q.notifications(t)
+		require.Len(t, got, 1)
+		require.Equal(t, due.ID, got[0].EventID)
+		pending, err = events.ClaimPendingNotifications(ctx, s.id, baseTime, baseTime, outboxBatch)
+		require.NoError(t, err)
+		require.Empty(t, pending)
+	})
+
+	t.Run("new leader publishes notifications enqueued by the failed one", func(t *testing.T) {
+		events := memorystorage.New()
+		createEvent(t, events, baseTime.Add(time.Hour), time.Hour)
+
+		failed := New(nopLogger{}, events, &queue{err: errors.New("broker is down")}, &counters{},
+			time.Minute, time.Hour)
+		failed.notifiedUntil = baseTime.Add(-time.Minute)
+		failed.runOnce(ctx, baseTime.Add(time.Second))
+
+		// The lookback of the new leader ends before the reminder fires, only the outbox has it.
+		q := &queue{}
+		leader := New(nopLogger{}, events, q, &counters{}, time.Minute, time.Hour)
+		leader.notifiedUntil = baseTime.Add(time.Minute)
+		leader.runOnce(ctx, baseTime.Add(2*time.Minute))
+		require.Zero(t, q.len(), "claimed by the failed leader")
+		leader.runOnce(ctx, baseTime.Add(claimTimeout+time.Minute))
+		require.Equal(t, 1, q.len())
+	})
+
+	t.Run("overlapping leaders publish each notification once", func(t *testing.T) {
+		events := memorystorage.New()
+		for i := 0; i < 2*outboxBatch+1; i++ {
+			createEvent(t, events, baseTime.Add(time.Hour+time.Duration(i)*time.Hour), time.Hour)
+		}
+
+		// A former leader still relaying while the new one starts.
+		q := &queue{delay: 100 * time.Microsecond}
+		var wg sync.WaitGroup
+		for _, s := range []*Scheduler{
+			New(nopLogger{}, events, q, &counters{}, time.Minute, time.Hour),
+			New(nopLogger{}, events, q, &counters{}, time.Minute, time.Hour),
+		} {
+			s.notifiedUntil = baseTime
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.runOnce(ctx, baseTime.Add(time.Duration(2*outboxBatch+1)*time.Hour))
+			}()
+		}
+		wg.Wait()
+
+		published := make(map[string]int)
+		for _, n := range q.notifications(t) {
+			published[n.ID]++
+		}
+		require.Len(t, published, 2*outboxBatch+1)
+		for id, count := range published {
+			require.Equal(t, 1, count, id)
+		}
+	})
+
+	t.Run("publishes the outbox in batches", func(t *testing.T) {
+		events := memorystorage.New()
+		for i := 0; i < outboxBatch+1; i++ {
+			createEvent(t, events, baseTime.Add(time.Hour+time.Duration(i)*time.Hour), time.Hour)
+		}
+
+		q := &queue{}
+		s := New(nopLogger{}, events, q, &counters{}, time.Minute, time.Minute)
+		s.notifiedUntil = baseTime
+		s.runOnce(ctx, baseTime.Add(time.Duration(outboxBatch+1)*time.Hour))
+		require.Equal(t, outboxBatch+1, q.len())
+	})
+
+	t.Run("sends reminders through their channels", func(t *testing.T) {
+		events := memorystorage.New()
+		_, err := events.CreateEvent(ctx, storage.Event{
+			Title:   "review",
+			StartAt: baseTime.Add(time.Hour),
+			EndAt:   baseTime.Add(2 * time.Hour),
+			UserID:  "u1",
+			Reminders: []storage.Reminder{
+				{Before: time.Hour, Channel: storage.ChannelEmail},
+				{Before: time.Hour, Channel: storage.ChannelWebhook},
+				{Before: 10 * time.Minute, Channel: storage.ChannelLog},
+			},
+		})
+		require.NoError(t, err)
+
+		q := &queue{}
+		s := New(nopLogger{}, events, q, &counters{}, time.Minute, time.Minute)
+		s.notifiedUntil = baseTime.Add(-time.Minute)
+		s.runOnce(ctx, baseTime.Add(time.Second))
+
+		var channels []storage.Channel
+		for _, n := range q.notifications(t) {
+			channels = append(channels, n.Channel)
+		}
+		require.Equal(t, []storage.Channel{storage.ChannelEmail, storage.ChannelWebhook}, channels)
+	})
+
+	t.Run("sends once across restarts and leaders", func(t *testing.T) {
+		events := memorystorage.New()
+		createEvent(t, events, baseTime.Add(time.Hour), time.Hour)
+
+		q := &queue{}
+		c := &counters{}
+		first := New(nopLogger{}, events, q, c, time.Minute, time.Hour)
+		first.notifiedUntil = baseTime.Add(-time.Minute)
+		first.runOnce(ctx, baseTime.Add(time.Second))
+
+		// A restarted scheduler or a new leader scans the lookback again.
+		second := New(nopLogger{}, events, q, c, time.Minute, time.Hour)
+		second.notifiedUntil = baseTime.Add(time.Second - time.Hour)
+		second.runOnce(ctx, baseTime.Add(time.Minute))
+
+		require.Equal(t, 1, q.len())
+		require.Equal(t, 1, c.published)
+		require.Equal(t, 1, c.deduplicated)
+	})
+
+	t.Run("compacts the outbox", func(t *testing.T) {
+		events := memorystorage.New()
+		createEvent(t, events, baseTime.Add(time.Hour), time.Hour)
+
+		q := &queue{}
+		s := New(nopLogger{}, events, q, &counters{}, time.Minute, time.Minute)
+		s.notifiedUntil = baseTime.Add(-time.Minute)
+		s.runOnce(ctx, baseTime.Add(time.Second))
+		s.runOnce(ctx, baseTime.Add(outboxRetention+time.Minute))
+
+		added, err := events.EnqueueNotifications(ctx, q.notifications(t), baseTime)
+		require.NoError(t, err)
+		require.Equal(t, 1, added)
+	})
+
+	t.Run("deletes events older than a year", func(t *testing.T) {
+		events := memorystorage.New()
+		old := createEvent(t, events, baseTime.AddDate(-1, 0, -1), 0)
+		recent := createEvent(t, events, baseTime.AddDate(0, -11, 0), 0)
+
+		c := &counters{}
+		s := New(nopLogger{}, events, &queue{}, c, time.Minute, time.Minute)
+		s.runOnce(ctx, baseTime)
+
+		require.Equal(t, int64(1), c.purged)
+		_, err := events.GetEvent(ctx, old.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = events.GetEvent(ctx, recent.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("compacts old changes", func(t *testing.T) {
+		events := memorystorage.New()
+		// Changes are logged at the current time.
+		now := time.Now()
+		createEvent(t, events, now, 0)
+		createEvent(t, events, now.Add(time.Hour), 0)
+
+		s := New(nopLogger{}, events, &queue{}, &counters{}, time.Minute, time.Minute)
+		s.runOnce(ctx, now)
+		_, err := events.ListChanges(ctx, "u1", 1)
+		require.NoError(t, err)
+
+		s.runOnce(ctx, now.Add(changeRetention+time.Minute))
+		_, err = events.ListChanges(ctx, "u1", 1)
+		require.ErrorIs(t, err, storage.ErrChangesCompacted)
+	})
+
+	t.Run("run stops with context", func(t *testing.T) {
+		events := memorystorage.New()
+		createEvent(t, events, time.Now().Add(time.Minute), time.Minute-50*time.Millisecond)
+
+		q := &queue{}
+		s := New(nopLogger{}, events, q, &counters{}, 10*time.Millisecond, 10*time.Millisecond)
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			s.Run(ctx)
+			close(done)
+		}()
+
+		require.Eventually(t, func() bool { return q.len() == 1 }, time.Second, 5*time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("scheduler did not stop")
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package sender
+
+import (
+	"sync"
+	"time"
+)
+
+// delivered remembers IDs of notifications delivered within the window, so that a notification
+// published again by the scheduler is dropped. It is safe for concurrent use.
+type delivered struct {
+	mu        sync.Mutex
+	window    time.Duration
+	ids       map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newDelivered(window time.Duration) *delivered {
+	return &delivered{window: window, ids: make(map[string]time.Time), now: time.Now}
+}
+
+// contains reports whether the notification was delivered within the window.
+// Notifications without an ID and a zero window never match.
+func (d *delivered) contains(id string) bool {
+	if id == "" || d.window <= 0 {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	at, ok := d.ids[id]
+	return ok && d.now().Sub(at) < d.window
+}
+
+func (d *delivered) add(id string) {
+	if id == "" || d.window <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	d.sweep(now)
+	d.ids[id] = now
+}
+
+// sweep drops IDs that fell out of the window to bound memory, at most once a window.
+func (d *delivered) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	for id, at := range d.ids {
+		if now.Sub(at) >= d.window {
+			delete(d.ids, id)
+		}
+	}
+	d.lastSweep = now
+}
@@ -0,0 +1,140 @@
+// Package sender delivers notifications published by the scheduler.
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+type Sender struct {
+	logger   Logger
+	consumer Consumer
+	sinks    []Sink
+	metrics  Metrics
+	retry    RetryPolicy
+	// delivered drops repeats of notifications, see New.
+	delivered *delivered
+}
+
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Consumer reads the queue the scheduler publishes to, see package queue for delivery semantics.
+type Consumer interface {
+	Consume(ctx context.Context, handle func(ctx context.Context, body []byte) error) error
+}
+
+// Sink is a channel notifications are delivered through.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, n storage.Notification) error
+}
+
+// Metrics counts deliveries of notifications by sink.
+type Metrics interface {
+	Delivered(sink string)
+	Failed(sink string)
+}
+
+// RetryPolicy limits delivery attempts of a notification to a sink. The delay between
+// attempts starts at InitialBackoff and doubles up to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// New returns a sender that acknowledges without delivering notifications with the ID of one
+// delivered within dedupeWindow; a zero window disables that.
+func New(
+	logger Logger, consumer Consumer, sinks []Sink, metrics Metrics, retry RetryPolicy, dedupeWindow time.Duration,
+) *Sender {
+	return &Sender{
+		logger: logger, consumer: consumer, sinks: sinks, metrics: metrics, retry: retry,
+		delivered: newDelivered(dedupeWindow),
+	}
+}
+
+// Run delivers notifications until ctx is done.
+func (s *Sender) Run(ctx context.Context) error {
+	return s.consumer.Consume(ctx, s.handle)
+}
+
+// handle delivers a notification to the sink of its channel, or to every sink when it has none.
+// Only failed sinks are retried, when some of them still fail the message is left for redelivery,
+// so a sink may receive a notification twice. Notifications the scheduler published again after
+// they were delivered are dropped by ID.
+func (s *Sender) handle(ctx context.Context, body []byte) error {
+	var n storage.Notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		s.logger.Error("malformed notification is rejected", "err", err, "body", string(body))
+		return queue.Reject(err)
+	}
+
+	if s.delivered.contains(n.ID) {
+		s.logger.Debug("repeated notification is dropped", "id", n.ID, "event_id", n.EventID)
+		return nil
+	}
+
+	sinks := s.sinksOf(n.Channel)
+	if len(sinks) == 0 {
+		err := fmt.Errorf("no sink for channel %q", n.Channel)
+		s.logger.Error("notification is rejected", "event_id", n.EventID, "err", err)
+		return queue.Reject(err)
+	}
+	for _, sink := range sinks {
+		if err := s.sendWithRetry(ctx, sink, n); err != nil {
+			s.metrics.Failed(sink.Name())
+			return fmt.Errorf("deliver notification of event %s via %s: %w", n.EventID, sink.Name(), err)
+		}
+		s.metrics.Delivered(sink.Name())
+		s.logger.Debug("notification delivered", "event_id", n.EventID, "sink", sink.Name())
+	}
+	s.delivered.add(n.ID)
+	return nil
+}
+
+func (s *Sender) sinksOf(channel storage.Channel) []Sink {
+	if channel == "" {
+		return s.sinks
+	}
+	for _, sink := range s.sinks {
+		if sink.Name() == string(channel) {
+			return []Sink{sink}
+		}
+	}
+	return nil
+}
+
+func (s *Sender) sendWithRetry(ctx context.Context, sink Sink, n storage.Notification) error {
+	backoff := s.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := sink.Send(ctx, n)
+		if err == nil {
+			return nil
+		}
+		if attempt >= s.retry.MaxAttempts {
+			return err
+		}
+		s.logger.Warn("notification delivery failed, retrying",
+			"event_id", n.EventID, "sink", sink.Name(), "attempt", attempt, "backoff", backoff, "err", err)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, s.retry.MaxBackoff)
+	}
+}
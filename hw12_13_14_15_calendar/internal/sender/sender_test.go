@@ -0,0 +1,244 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/queue"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var notification = storage.Notification{
+	EventID: "5b0b1f7e-3f57-4a43-9d6b-1b6b7f0c2a11",
+	Title:   "meeting",
+	StartAt: time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC),
+	UserID:  "u1",
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// deliveries counts delivery results of all sinks.
+type deliveries struct {
+	delivered, failed int
+}
+
+func (d *deliveries) Delivered(string) { d.delivered++ }
+func (d *deliveries) Failed(string)    { d.failed++ }
+
+// memQueue is an in-process stand-in for the message broker that records acknowledgements.
+type memQueue struct {
+	messages chan []byte
+	mu       sync.Mutex
+	acked    int
+	nacked   int
+}
+
+func newMemQueue(messages ...[]byte) *memQueue {
+	q := &memQueue{messages: make(chan []byte, len(messages))}
+	for _, m := range messages {
+		q.messages <- m
+	}
+	return q
+}
+
+func (q *memQueue) Consume(ctx context.Context, handle func(ctx context.Context, body []byte) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case body := <-q.messages:
+			err := handle(ctx, body)
+			q.mu.Lock()
+			if err != nil {
+				q.nacked++
+			} else {
+				q.acked++
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+func (q *memQueue) handled() (acked, nacked int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.acked, q.nacked
+}
+
+// flakySink fails the given number of sends before accepting notifications.
+type flakySink struct {
+	name     string
+	mu       sync.Mutex
+	failures int
+	received []storage.Notification
+}
+
+func (s *flakySink) Name() string {
+	if s.name == "" {
+		return "flaky"
+	}
+	return s.name
+}
+
+func (s *flakySink) Send(_ context.Context, n storage.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("temporary failure")
+	}
+	s.received = append(s.received, n)
+	return nil
+}
+
+func marshal(t *testing.T, n storage.Notification) []byte {
+	t.Helper()
+	body, err := json.Marshal(n)
+	require.NoError(t, err)
+	return body
+}
+
+func run(t *testing.T, s *Sender, q *memQueue, messages int) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		acked, nacked := q.handled()
+		return acked+nacked == messages
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestSender(t *testing.T) {
+	t.Run("delivers to every sink and acks", func(t *testing.T) {
+		first, second := &flakySink{}, &flakySink{}
+		q := newMemQueue(marshal(t, notification))
+
+		d := &deliveries{}
+		run(t, New(nopLogger{}, q, []Sink{first, second}, d, fastRetry, time.Hour), q, 1)
+
+		require.Equal(t, []storage.Notification{notification}, first.received)
+		require.Equal(t, []storage.Notification{notification}, second.received)
+		require.Equal(t, 2, d.delivered)
+		acked, nacked := q.handled()
+		require.Equal(t, 1, acked)
+		require.Zero(t, nacked)
+	})
+
+	t.Run("delivers only to the sink of the channel", func(t *testing.T) {
+		log, email := &flakySink{name: "log"}, &flakySink{name: "email"}
+		n := notification
+		n.Channel = storage.ChannelEmail
+		q := newMemQueue(marshal(t, n))
+
+		run(t, New(nopLogger{}, q, []Sink{log, email}, &deliveries{}, fastRetry, time.Hour), q, 1)
+
+		require.Empty(t, log.received)
+		require.Equal(t, []storage.Notification{n}, email.received)
+	})
+
+	t.Run("rejects notification of a channel without sink", func(t *testing.T) {
+		sink := &flakySink{name: "log"}
+		n := notification
+		n.Channel = storage.ChannelWebhook
+		s := New(nopLogger{}, nil, []Sink{sink}, &deliveries{}, fastRetry, time.Hour)
+
+		err := s.handle(context.Background(), marshal(t, n))
+		require.ErrorIs(t, err, queue.ErrRejected)
+		require.Empty(t, sink.received)
+	})
+
+	t.Run("retries failed sink", func(t *testing.T) {
+		sink := &flakySink{failures: 2}
+		q := newMemQueue(marshal(t, notification))
+
+		run(t, New(nopLogger{}, q, []Sink{sink}, &deliveries{}, fastRetry, time.Hour), q, 1)
+
+		require.Len(t, sink.received, 1)
+		acked, _ := q.handled()
+		require.Equal(t, 1, acked)
+	})
+
+	t.Run("leaves message for redelivery when retries are exhausted", func(t *testing.T) {
+		sink := &flakySink{failures: 3}
+		q := newMemQueue(marshal(t, notification))
+
+		d := &deliveries{}
+		run(t, New(nopLogger{}, q, []Sink{sink}, d, fastRetry, time.Hour), q, 1)
+
+		require.Empty(t, sink.received)
+		require.Equal(t, deliveries{failed: 1}, *d)
+		acked, nacked := q.handled()
+		require.Zero(t, acked)
+		require.Equal(t, 1, nacked)
+	})
+
+	t.Run("drops repeated notifications", func(t *testing.T) {
+		sink := &flakySink{}
+		first, second := notification, notification
+		first.ID, second.ID = "n1", "n2"
+		q := newMemQueue(marshal(t, first), marshal(t, first), marshal(t, second))
+
+		run(t, New(nopLogger{}, q, []Sink{sink}, &deliveries{}, fastRetry, time.Hour), q, 3)
+
+		require.Equal(t, []storage.Notification{first, second}, sink.received)
+		acked, _ := q.handled()
+		require.Equal(t, 3, acked)
+	})
+
+	t.Run("rejects malformed message", func(t *testing.T) {
+		sink := &flakySink{}
+		s := New(nopLogger{}, nil, []Sink{sink}, &deliveries{}, fastRetry, time.Hour)
+
+		err := s.handle(context.Background(), []byte("not json"))
+		require.ErrorIs(t, err, queue.ErrRejected)
+		require.Empty(t, sink.received)
+	})
+
+	t.Run("stops retrying on shutdown", func(t *testing.T) {
+		sink := &flakySink{failures: 1}
+		retry := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Hour}
+		s := New(nopLogger{}, nil, []Sink{sink}, &deliveries{}, retry, time.Hour)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := s.handle(ctx, marshal(t, notification))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestDelivered(t *testing.T) {
+	now := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	d := newDelivered(time.Minute)
+	d.now = func() time.Time { return now }
+
+	d.add("n1")
+	d.add("")
+	require.True(t, d.contains("n1"))
+	require.False(t, d.contains("n2"))
+	require.False(t, d.contains(""), "notifications without ID are not dropped")
+
+	now = now.Add(time.Minute)
+	require.False(t, d.contains("n1"), "out of the window")
+	d.add("n2")
+	require.Equal(t, map[string]time.Time{"n2": now}, d.ids, "swept")
+
+	disabled := newDelivered(0)
+	disabled.add("n1")
+	require.False(t, disabled.contains("n1"))
+}
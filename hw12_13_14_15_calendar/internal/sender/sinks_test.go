@@ -0,0 +1,143 @@
+package sender
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("posts notification", func(t *testing.T) {
+		var got storage.Notification
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPost, r.Method)
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer srv.Close()
+
+		require.NoError(t, NewWebhookSink(srv.URL, srv.Client()).Send(ctx, notification))
+		require.Equal(t, notification, got)
+	})
+
+	t.Run("non 2xx status is a failure", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		err := NewWebhookSink(srv.URL, srv.Client()).Send(ctx, notification)
+		require.ErrorContains(t, err, "503")
+	})
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	sink := NewFileSink(path)
+
+	require.NoError(t, sink.Send(context.Background(), notification))
+	require.NoError(t, sink.Send(context.Background(), notification))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var got storage.Notification
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &got))
+	require.Equal(t, notification, got)
+}
+
+// smtpServer accepts a single mail and sends what the client sent after DATA to the channel.
+func smtpServer(t *testing.T) (addr string, mails <-chan string, commands <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	data, cmds := make(chan string, 1), make(chan string, 16)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+		reply := func(line string) {
+			w.WriteString(line + "\r\n")
+			w.Flush()
+		}
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			cmds <- cmd
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case cmd == "DATA":
+				reply("354 end with .")
+				var mail strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					mail.WriteString(line)
+				}
+				data <- mail.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), data, cmds
+}
+
+func TestEmailSink(t *testing.T) {
+	addr, mails, commands := smtpServer(t)
+	n := notification
+	n.Title = "meeting\r\nBcc: victim@example.com"
+
+	err := NewEmailSink(addr, "calendar@example.com", "example.com", time.Second).Send(context.Background(), n)
+	require.NoError(t, err)
+
+	var mail string
+	select {
+	case mail = <-mails:
+	case <-time.After(time.Second):
+		t.Fatal("no mail received")
+	}
+	header, _, _ := strings.Cut(mail, "\r\n\r\n")
+	require.Contains(t, header, "To: u1@example.com\r\n")
+	require.Contains(t, header, "Subject: Reminder: meeting  Bcc: victim@example.com")
+	require.NotContains(t, header, "\r\nBcc:")
+
+	var sent []string
+	for len(commands) > 0 {
+		sent = append(sent, <-commands)
+	}
+	require.Contains(t, sent, "MAIL FROM:<calendar@example.com>")
+	require.Contains(t, sent, "RCPT TO:<u1@example.com>")
+}
@@ -0,0 +1,159 @@
+package internalgrpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProto(e storage.Event) *eventpb.Event {
+	return &eventpb.Event{
+		Id:           e.ID,
+		Title:        e.Title,
+		StartAt:      timestamppb.New(e.StartAt),
+		EndAt:        timestamppb.New(e.EndAt),
+		Description:  e.Description,
+		UserId:       e.UserID,
+		NotifyBefore: durationpb.New(e.NotifyBefore),
+		TimeZone:     e.TimeZone,
+		Rrule:        e.RRule,
+		Exdates:      toTimestamps(e.ExDates),
+		SeriesId:     e.SeriesID,
+		RecurrenceId: toTimestamp(e.RecurrenceID),
+		Attendees:    toAttendeesProto(e.Attendees),
+		Reminders:    toRemindersProto(e.Reminders),
+		Version:      e.Version,
+		Uid:          e.UID,
+	}
+}
+
+func toAttendeesProto(attendees []storage.Attendee) []*eventpb.Attendee {
+	if len(attendees) == 0 {
+		return nil
+	}
+	resp := make([]*eventpb.Attendee, 0, len(attendees))
+	for _, a := range attendees {
+		resp = append(resp, &eventpb.Attendee{UserId: a.UserID, Status: string(a.Status)})
+	}
+	return resp
+}
+
+func toRemindersProto(reminders []storage.Reminder) []*eventpb.Reminder {
+	if len(reminders) == 0 {
+		return nil
+	}
+	resp := make([]*eventpb.Reminder, 0, len(reminders))
+	for _, r := range reminders {
+		resp = append(resp, &eventpb.Reminder{Before: durationpb.New(r.Before), Channel: string(r.Channel)})
+	}
+	return resp
+}
+
+// fromProto converts an event of a request; unset times are left zero to fail validation.
+func fromProto(e *eventpb.Event) storage.Event {
+	var attendees []storage.Attendee
+	for _, a := range e.GetAttendees() {
+		attendees = append(attendees, storage.Attendee{UserID: a.GetUserId()})
+	}
+	var reminders []storage.Reminder
+	for _, r := range e.GetReminders() {
+		reminders = append(reminders, storage.Reminder{
+			Before:  r.GetBefore().AsDuration(),
+			Channel: storage.Channel(r.GetChannel()),
+		})
+	}
+	return storage.Event{
+		Title:        e.GetTitle(),
+		StartAt:      asTime(e.GetStartAt()),
+		EndAt:        asTime(e.GetEndAt()),
+		Description:  e.GetDescription(),
+		NotifyBefore: e.GetNotifyBefore().AsDuration(),
+		TimeZone:     e.GetTimeZone(),
+		RRule:        e.GetRrule(),
+		ExDates:      asTimes(e.GetExdates()),
+		Attendees:    attendees,
+		Reminders:    reminders,
+		Version:      e.GetVersion(),
+	}
+}
+
+func asTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func asTimes(ts []*timestamppb.Timestamp) []time.Time {
+	if len(ts) == 0 {
+		return nil
+	}
+	times := make([]time.Time, 0, len(ts))
+	for _, t := range ts {
+		times = append(times, asTime(t))
+	}
+	return times
+}
+
+// toTimestamp converts t leaving zero time unset.
+func toTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func toTimestamps(times []time.Time) []*timestamppb.Timestamp {
+	if len(times) == 0 {
+		return nil
+	}
+	ts := make([]*timestamppb.Timestamp, 0, len(times))
+	for _, t := range times {
+		ts = append(ts, timestamppb.New(t))
+	}
+	return ts
+}
+
+// fromFreeBusyProto converts a free/busy request; unset working hours are app.DefaultWorkingHours.
+func fromFreeBusyProto(req *eventpb.FreeBusyRequest) (app.FreeBusyQuery, error) {
+	q := app.FreeBusyQuery{
+		From:         asTime(req.GetFrom()),
+		To:           asTime(req.GetTo()),
+		SlotDuration: req.GetSlotDuration().AsDuration(),
+		Slots:        int(req.GetSlots()),
+		WorkingHours: app.DefaultWorkingHours,
+	}
+	hours := req.GetWorkingHours()
+	if hours == nil {
+		return q, nil
+	}
+	loc, err := storage.LoadLocation(hours.GetTimeZone())
+	if err != nil {
+		return app.FreeBusyQuery{}, err
+	}
+	q.WorkingHours = app.WorkingHours{
+		Start:    hours.GetStart().AsDuration(),
+		End:      hours.GetEnd().AsDuration(),
+		Location: loc,
+	}
+	for _, day := range hours.GetDays() {
+		if day < int32(time.Sunday) || day > int32(time.Saturday) {
+			return app.FreeBusyQuery{}, fmt.Errorf("unknown day of week %d", day)
+		}
+		q.WorkingHours.Days = append(q.WorkingHours.Days, time.Weekday(day))
+	}
+	return q, nil
+}
+
+func toIntervalsProto(intervals []app.Interval) []*eventpb.Interval {
+	resp := make([]*eventpb.Interval, 0, len(intervals))
+	for _, in := range intervals {
+		resp = append(resp, &eventpb.Interval{Start: timestamppb.New(in.Start), End: timestamppb.New(in.End)})
+	}
+	return resp
+}
@@ -0,0 +1,226 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dateLayout is the format of ListEventsRequest.date.
+const dateLayout = "2006-01-02"
+
+func (s *Server) Create(ctx context.Context, req *eventpb.CreateEventRequest) (*eventpb.CreateEventResponse, error) {
+	ctx = overlapContext(ctx, req.GetAllowOverlap())
+	e, err := s.app.CreateEvent(ctx, userID(ctx), fromProto(req.GetEvent()))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	conflicts := s.conflicts(ctx, e, req.GetAllowOverlap())
+	return &eventpb.CreateEventResponse{Event: toProto(e), Conflicts: conflicts}, nil
+}
+
+func (s *Server) Update(ctx context.Context, req *eventpb.UpdateEventRequest) (*eventpb.UpdateEventResponse, error) {
+	ctx = overlapContext(ctx, req.GetAllowOverlap())
+	e, err := s.app.UpdateEvent(ctx, userID(ctx), req.GetId(), fromProto(req.GetEvent()))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	conflicts := s.conflicts(ctx, e, req.GetAllowOverlap())
+	return &eventpb.UpdateEventResponse{Event: toProto(e), Conflicts: conflicts}, nil
+}
+
+func (s *Server) Respond(ctx context.Context, req *eventpb.RespondRequest) (*eventpb.RespondResponse, error) {
+	e, err := s.app.RespondToEvent(ctx, userID(ctx), req.GetId(), storage.RSVPStatus(req.GetStatus()))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.RespondResponse{Event: toProto(e)}, nil
+}
+
+func (s *Server) Delete(ctx context.Context, req *eventpb.DeleteEventRequest) (*eventpb.DeleteEventResponse, error) {
+	if err := s.app.DeleteEvent(ctx, userID(ctx), req.GetId()); err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.DeleteEventResponse{}, nil
+}
+
+func (s *Server) Get(ctx context.Context, req *eventpb.GetEventRequest) (*eventpb.GetEventResponse, error) {
+	e, err := s.app.GetEvent(ctx, userID(ctx), req.GetId())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.GetEventResponse{Event: toProto(e)}, nil
+}
+
+func (s *Server) UpdateOccurrence(
+	ctx context.Context, req *eventpb.UpdateOccurrenceRequest,
+) (*eventpb.UpdateOccurrenceResponse, error) {
+	if req.GetStart() == nil {
+		return nil, status.Error(codes.InvalidArgument, "occurrence start is required")
+	}
+	ctx = overlapContext(ctx, req.GetAllowOverlap())
+	e, err := s.app.UpdateOccurrence(ctx, userID(ctx), req.GetId(), req.GetStart().AsTime(), fromProto(req.GetEvent()))
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	conflicts := s.conflicts(ctx, e, req.GetAllowOverlap())
+	return &eventpb.UpdateOccurrenceResponse{Event: toProto(e), Conflicts: conflicts}, nil
+}
+
+func (s *Server) DeleteOccurrence(
+	ctx context.Context, req *eventpb.DeleteOccurrenceRequest,
+) (*eventpb.DeleteOccurrenceResponse, error) {
+	if req.GetStart() == nil {
+		return nil, status.Error(codes.InvalidArgument, "occurrence start is required")
+	}
+	err := s.app.DeleteOccurrence(ctx, userID(ctx), req.GetId(), req.GetStart().AsTime(), req.GetVersion())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.DeleteOccurrenceResponse{}, nil
+}
+
+func (s *Server) FreeBusy(ctx context.Context, req *eventpb.FreeBusyRequest) (*eventpb.FreeBusyResponse, error) {
+	q, err := fromFreeBusyProto(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	fb, err := s.app.FreeBusy(ctx, userID(ctx), q)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	return &eventpb.FreeBusyResponse{Busy: toIntervalsProto(fb.Busy), Free: toIntervalsProto(fb.Free)}, nil
+}
+
+func (s *Server) Sync(ctx context.Context, req *eventpb.SyncRequest) (*eventpb.SyncResponse, error) {
+	result, err := s.app.Sync(ctx, userID(ctx), req.GetSyncToken())
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.SyncResponse{
+		Events:    make([]*eventpb.Event, 0, len(result.Events)),
+		Deleted:   result.Deleted,
+		SyncToken: result.Token,
+	}
+	for _, e := range result.Events {
+		resp.Events = append(resp.Events, toProto(e))
+	}
+	return resp, nil
+}
+
+func (s *Server) Search(ctx context.Context, req *eventpb.SearchRequest) (*eventpb.SearchResponse, error) {
+	result, err := s.app.SearchEvents(ctx, userID(ctx), app.SearchQuery{
+		Text:    req.GetQuery(),
+		OwnerID: req.GetOwnerId(),
+		From:    asTime(req.GetFrom()),
+		To:      asTime(req.GetTo()),
+		Limit:   int(req.GetLimit()),
+		Cursor:  req.GetCursor(),
+	})
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+	resp := &eventpb.SearchResponse{Events: make([]*eventpb.Event, 0, len(result.Events)), NextCursor: result.Cursor}
+	for _, e := range result.Events {
+		resp.Events = append(resp.Events, toProto(e))
+	}
+	return resp, nil
+}
+
+func (s *Server) ListDay(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+	return s.listEvents(ctx, req, s.app.ListDayEvents)
+}
+
+func (s *Server) ListWeek(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+	return s.listEvents(ctx, req, s.app.ListWeekEvents)
+}
+
+func (s *Server) ListMonth(ctx context.Context, req *eventpb.ListEventsRequest) (*eventpb.ListEventsResponse, error) {
+	return s.listEvents(ctx, req, s.app.ListMonthEvents)
+}
+
+type listFunc func(ctx context.Context, userID string, date time.Time) ([]storage.Event, error)
+
+func (s *Server) listEvents(
+	ctx context.Context, req *eventpb.ListEventsRequest, list listFunc,
+) (*eventpb.ListEventsResponse, error) {
+	loc, err := storage.LoadLocation(req.GetTimeZone())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	date, err := time.Parse(dateLayout, req.GetDate())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "date must be in YYYY-MM-DD format")
+	}
+	date = storage.DateIn(date, loc)
+
+	events, err := list(ctx, userID(ctx), date)
+	if err != nil {
+		return nil, s.toStatus(ctx, err)
+	}
+
+	resp := &eventpb.ListEventsResponse{Events: make([]*eventpb.Event, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toProto(e))
+	}
+	return resp, nil
+}
+
+// overlapContext returns the context an event is saved in, see CreateEventRequest.allow_overlap.
+func overlapContext(ctx context.Context, allowOverlap bool) context.Context {
+	if allowOverlap {
+		return storage.WithOverlap(ctx)
+	}
+	return ctx
+}
+
+// conflicts returns IDs of the events a saved event overlaps when overlaps are allowed.
+// The event is saved already, so a failure to list them is logged and no IDs are returned
+// rather than failing the call the client would retry.
+func (s *Server) conflicts(ctx context.Context, e storage.Event, allowOverlap bool) []string {
+	if !allowOverlap {
+		return nil
+	}
+	events, err := s.app.ListConflicts(ctx, e)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list conflicts of saved event", "event_id", e.ID, "err", err)
+		return nil
+	}
+	ids := make([]string, 0, len(events))
+	for _, other := range events {
+		ids = append(ids, other.ID)
+	}
+	return ids
+}
+
+func userID(ctx context.Context) string {
+	return firstMetadataValue(ctx, UserIDMetadataKey)
+}
+
+// toStatus maps application errors to gRPC status codes.
+func (s *Server) toStatus(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(err, app.ErrNoUser), errors.Is(err, storage.ErrInvalidEvent), errors.Is(err, app.ErrInvalidQuery),
+		errors.Is(err, app.ErrInvalidSyncToken):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, app.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrDateBusy), errors.Is(err, storage.ErrUIDTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrVersionMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, app.ErrSyncTokenExpired):
+		return status.Error(codes.OutOfRange, err.Error())
+	default:
+		s.logger.ErrorContext(ctx, "call failed", "err", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,109 @@
+package internalgrpc
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadataKey carries the ID of a call; it is generated unless passed by the client.
+const RequestIDMetadataKey = "x-request-id"
+
+// RetryAfterMetadataKey is the header carrying seconds to wait before retrying a call
+// rejected by the rate limiter.
+const RetryAfterMetadataKey = "retry-after"
+
+// readMethods are limited as reads, the other methods of the event service as writes.
+var readMethods = map[string]bool{
+	eventpb.EventService_Get_FullMethodName:       true,
+	eventpb.EventService_ListDay_FullMethodName:   true,
+	eventpb.EventService_ListWeek_FullMethodName:  true,
+	eventpb.EventService_ListMonth_FullMethodName: true,
+	eventpb.EventService_FreeBusy_FullMethodName:  true,
+	eventpb.EventService_Sync_FullMethodName:      true,
+	eventpb.EventService_Search_FullMethodName:    true,
+}
+
+// loggingInterceptor logs every unary call with its peer, status code and latency,
+// by analogy with the access log of the HTTP server. Request ID is attached to the call context.
+func loggingInterceptor(logger Logger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+
+		requestID := firstMetadataValue(ctx, RequestIDMetadataKey)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		ctx = logger.ContextWith(ctx, "request_id", requestID)
+
+		resp, err := handler(ctx, req)
+
+		ip := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			ip = p.Addr.String()
+		}
+		logger.InfoContext(ctx, "grpc call "+info.FullMethod,
+			"ip", ip,
+			"method", info.FullMethod,
+			"code", status.Code(err).String(),
+			"latency", time.Since(start),
+			"user_agent", firstMetadataValue(ctx, "user-agent"),
+		)
+		return resp, err
+	}
+}
+
+// metricsInterceptor records every unary call by its method and status code.
+func metricsInterceptor(metrics Metrics) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		metrics.ObserveCall(info.FullMethod, status.Code(err).String(), time.Since(start))
+		return resp, err
+	}
+}
+
+// rateLimitInterceptor rejects calls of a user over the limit of the method class with RESOURCE_EXHAUSTED
+// and the retry-after header. Calls without a user, such as health checks, are not limited.
+func rateLimitInterceptor(limiter Limiter, metrics Metrics) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		userID := firstMetadataValue(ctx, UserIDMetadataKey)
+		if userID == "" {
+			return handler(ctx, req)
+		}
+		class := ratelimit.ClassWrite
+		if readMethods[info.FullMethod] {
+			class = ratelimit.ClassRead
+		}
+		if ok, wait := limiter.Allow(userID, class); !ok {
+			metrics.ObserveRateLimited(info.FullMethod, string(class))
+			retryAfter := ratelimit.RetryAfterSeconds(wait)
+			// Fails only outside of a server stream, e.g. in tests calling the interceptor directly.
+			_ = grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadataKey, strconv.Itoa(retryAfter)))
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded: retry in %d s", retryAfter)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func firstMetadataValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
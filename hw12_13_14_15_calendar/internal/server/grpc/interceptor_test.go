@@ -0,0 +1,87 @@
+package internalgrpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type call struct {
+	method, code string
+}
+
+type recordingMetrics struct {
+	mu      sync.Mutex
+	calls   []call
+	limited []call
+}
+
+func (m *recordingMetrics) ObserveCall(method, code string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call{method: method, code: code})
+}
+
+// ObserveRateLimited records the class as the code of the call.
+func (m *recordingMetrics) ObserveRateLimited(method, class string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limited = append(m.limited, call{method: method, code: class})
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	m := &recordingMetrics{}
+	interceptor := metricsInterceptor(m)
+	info := &grpc.UnaryServerInfo{FullMethod: "/event.EventService/Get"}
+
+	_, err := interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	require.NoError(t, err)
+	_, err = interceptor(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "event not found")
+	})
+	require.Error(t, err)
+
+	require.Equal(t, []call{
+		{method: "/event.EventService/Get", code: "OK"},
+		{method: "/event.EventService/Get", code: "NotFound"},
+	}, m.calls)
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	m := &recordingMetrics{}
+	conn := newLimitedTestConn(t, ratelimit.New(map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassWrite: {Rate: 0.01, Burst: 1},
+	}), m)
+	client := eventpb.NewEventServiceClient(conn)
+	ctx := userCtx("u1")
+
+	_, err := client.Delete(ctx, &eventpb.DeleteEventRequest{Id: "8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	var header metadata.MD
+	_, err = client.Delete(ctx, &eventpb.DeleteEventRequest{Id: "8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b"},
+		grpc.Header(&header))
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, []string{"100"}, header.Get(RetryAfterMetadataKey))
+
+	_, err = client.Delete(userCtx("u2"), &eventpb.DeleteEventRequest{Id: "8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b"})
+	require.Equal(t, codes.NotFound, status.Code(err), "another user")
+	_, err = client.Get(ctx, &eventpb.GetEventRequest{Id: "8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b"})
+	require.Equal(t, codes.NotFound, status.Code(err), "reads are not limited")
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+
+	require.Equal(t, []call{{method: eventpb.EventService_Delete_FullMethodName, code: "write"}}, m.limited)
+}
@@ -0,0 +1,108 @@
+package internalgrpc
+
+//go:generate protoc -I ../../../api --go_out=../../../pkg/eventpb --go_opt=paths=source_relative --go-grpc_out=../../../pkg/eventpb --go-grpc_opt=paths=source_relative EventService.proto
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// UserIDMetadataKey carries the ID of the user on whose behalf a call is made.
+const UserIDMetadataKey = "x-user-id"
+
+type Server struct {
+	eventpb.UnimplementedEventServiceServer
+
+	logger  Logger
+	app     Application
+	metrics Metrics
+	limiter Limiter
+	addr    string
+	server  *grpc.Server
+}
+
+type Logger interface {
+	Info(msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+	// ContextWith attaches per-request fields to ctx for the *Context methods.
+	ContextWith(ctx context.Context, args ...interface{}) context.Context
+}
+
+// Metrics records call statistics.
+type Metrics interface {
+	ObserveCall(method, code string, latency time.Duration)
+	ObserveRateLimited(method, class string)
+}
+
+// Limiter limits the rate of calls of every user, see package ratelimit.
+type Limiter interface {
+	Allow(userID string, class ratelimit.Class) (bool, time.Duration)
+}
+
+type Application interface {
+	CreateEvent(ctx context.Context, userID string, e storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, userID, id string, e storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, userID, id string) error
+	GetEvent(ctx context.Context, userID, id string) (storage.Event, error)
+	ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error)
+	ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error)
+	ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error)
+	UpdateOccurrence(ctx context.Context, userID, id string, start time.Time, e storage.Event) (storage.Event, error)
+	DeleteOccurrence(ctx context.Context, userID, id string, start time.Time, version int64) error
+	ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error)
+	FreeBusy(ctx context.Context, userID string, q app.FreeBusyQuery) (app.FreeBusy, error)
+	RespondToEvent(ctx context.Context, userID, id string, status storage.RSVPStatus) (storage.Event, error)
+	Sync(ctx context.Context, userID, token string) (app.SyncResult, error)
+	SearchEvents(ctx context.Context, userID string, q app.SearchQuery) (app.SearchResult, error)
+}
+
+// NewServer returns a server of the event service that also serves the health checking protocol.
+func NewServer(
+	logger Logger, app Application, metrics Metrics, limiter Limiter, health healthpb.HealthServer, addr string,
+) *Server {
+	s := &Server{logger: logger, app: app, metrics: metrics, limiter: limiter, addr: addr}
+	s.server = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		loggingInterceptor(logger),
+		metricsInterceptor(metrics),
+		rateLimitInterceptor(limiter, metrics),
+	))
+	eventpb.RegisterEventServiceServer(s.server, s)
+	healthpb.RegisterHealthServer(s.server, health)
+	return s
+}
+
+// Start serves calls until Stop is called.
+func (s *Server) Start(ctx context.Context) error {
+	lis, err := (&net.ListenConfig{}).Listen(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("grpc server is listening", "addr", s.addr)
+	return s.server.Serve(lis)
+}
+
+// Stop gracefully shuts the server down, active calls are cancelled when ctx is done.
+func (s *Server) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
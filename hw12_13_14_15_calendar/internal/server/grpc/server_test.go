@@ -0,0 +1,412 @@
+package internalgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/pkg/eventpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{})                         {}
+func (nopLogger) Info(string, ...interface{})                          {}
+func (nopLogger) Warn(string, ...interface{})                          {}
+func (nopLogger) Error(string, ...interface{})                         {}
+func (nopLogger) InfoContext(context.Context, string, ...interface{})  {}
+func (nopLogger) ErrorContext(context.Context, string, ...interface{}) {}
+
+func (nopLogger) ContextWith(ctx context.Context, _ ...interface{}) context.Context {
+	return ctx
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveCall(string, string, time.Duration) {}
+func (nopMetrics) ObserveRateLimited(string, string)         {}
+
+var baseTime = time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+
+func newTestClient(t *testing.T) eventpb.EventServiceClient {
+	t.Helper()
+	return eventpb.NewEventServiceClient(newTestConn(t))
+}
+
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	return newLimitedTestConn(t, ratelimit.New(nil), nopMetrics{})
+}
+
+func newLimitedTestConn(t *testing.T, limiter Limiter, metrics Metrics) *grpc.ClientConn {
+	t.Helper()
+	return newAppTestConn(t, app.New(nopLogger{}, memorystorage.New()), limiter, metrics)
+}
+
+func newAppTestConn(t *testing.T, calendar Application, limiter Limiter, metrics Metrics) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := NewServer(nopLogger{}, calendar, metrics, limiter,
+		health.NewGRPCServer(health.NewChecker(nil), eventpb.EventService_ServiceDesc.ServiceName), "")
+	go s.server.Serve(lis)
+	t.Cleanup(s.server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// conflictsFailure is an application failing to list conflicts of saved events.
+type conflictsFailure struct {
+	*app.App
+}
+
+func (conflictsFailure) ListConflicts(context.Context, storage.Event) ([]storage.Event, error) {
+	return nil, errors.New("storage is down")
+}
+
+func userCtx(userID string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), UserIDMetadataKey, userID)
+}
+
+func newEvent(start time.Time) *eventpb.Event {
+	return &eventpb.Event{
+		Title:        "meeting",
+		StartAt:      timestamppb.New(start),
+		EndAt:        timestamppb.New(start.Add(time.Hour)),
+		Description:  "weekly sync",
+		NotifyBefore: durationpb.New(15 * time.Minute),
+	}
+}
+
+func TestServer(t *testing.T) {
+	t.Run("event lifecycle", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+
+		created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.NoError(t, err)
+		require.NotEmpty(t, created.Event.Id)
+		require.Equal(t, created.Event.Id, created.Event.Uid)
+		require.Equal(t, "u1", created.Event.UserId)
+		require.Equal(t, 15*time.Minute, created.Event.NotifyBefore.AsDuration())
+
+		got, err := client.Get(ctx, &eventpb.GetEventRequest{Id: created.Event.Id})
+		require.NoError(t, err)
+		require.Equal(t, created.Event.StartAt.AsTime(), got.Event.StartAt.AsTime())
+
+		upd := newEvent(baseTime)
+		upd.Title = "retro"
+		updated, err := client.Update(ctx, &eventpb.UpdateEventRequest{Id: created.Event.Id, Event: upd})
+		require.NoError(t, err)
+		require.Equal(t, "retro", updated.Event.Title)
+
+		_, err = client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime.AddDate(0, 0, 3))})
+		require.NoError(t, err)
+
+		day, err := client.ListDay(ctx, &eventpb.ListEventsRequest{Date: "2024-03-04"})
+		require.NoError(t, err)
+		require.Len(t, day.Events, 1)
+		week, err := client.ListWeek(ctx, &eventpb.ListEventsRequest{Date: "2024-03-04"})
+		require.NoError(t, err)
+		require.Len(t, week.Events, 2)
+		month, err := client.ListMonth(ctx, &eventpb.ListEventsRequest{Date: "2024-03-01"})
+		require.NoError(t, err)
+		require.Len(t, month.Events, 2)
+
+		_, err = client.Delete(ctx, &eventpb.DeleteEventRequest{Id: created.Event.Id})
+		require.NoError(t, err)
+		_, err = client.Get(ctx, &eventpb.GetEventRequest{Id: created.Event.Id})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("recurring event", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+
+		ev := newEvent(baseTime)
+		ev.Rrule = "FREQ=WEEKLY;BYDAY=MO,TU"
+		ev.Exdates = []*timestamppb.Timestamp{timestamppb.New(baseTime.AddDate(0, 0, 7))}
+		created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: ev})
+		require.NoError(t, err)
+		require.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TU", created.Event.Rrule)
+		require.Len(t, created.Event.Exdates, 1)
+
+		tuesday := baseTime.AddDate(0, 0, 1)
+		moved, err := client.UpdateOccurrence(ctx, &eventpb.UpdateOccurrenceRequest{
+			Id: created.Event.Id, Start: timestamppb.New(tuesday), Event: newEvent(tuesday.Add(3 * time.Hour)),
+		})
+		require.NoError(t, err)
+		require.Equal(t, created.Event.Id, moved.Event.SeriesId)
+		require.Equal(t, created.Event.Uid, moved.Event.Uid)
+		require.Equal(t, tuesday, moved.Event.RecurrenceId.AsTime())
+
+		_, err = client.DeleteOccurrence(ctx, &eventpb.DeleteOccurrenceRequest{
+			Id: created.Event.Id, Start: timestamppb.New(baseTime.AddDate(0, 0, 8)), Version: created.Event.Version,
+		})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		series, err := client.Get(ctx, &eventpb.GetEventRequest{Id: created.Event.Id})
+		require.NoError(t, err)
+		_, err = client.DeleteOccurrence(ctx, &eventpb.DeleteOccurrenceRequest{
+			Id: created.Event.Id, Start: timestamppb.New(baseTime.AddDate(0, 0, 8)), Version: series.Event.Version,
+		})
+		require.NoError(t, err)
+
+		month, err := client.ListMonth(ctx, &eventpb.ListEventsRequest{Date: "2024-03-01"})
+		require.NoError(t, err)
+		require.Len(t, month.Events, 6) // 4th, 5th moved, 18th, 19th, 25th, 26th; 11th and 12th deleted.
+
+		_, err = client.DeleteOccurrence(ctx, &eventpb.DeleteOccurrenceRequest{Id: created.Event.Id})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.DeleteOccurrence(userCtx("u2"), &eventpb.DeleteOccurrenceRequest{
+			Id: created.Event.Id, Start: timestamppb.New(baseTime),
+		})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("time zones", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+
+		// 00:30 on March 31 in Berlin is still March 30 in UTC.
+		start := time.Date(2024, time.March, 30, 23, 30, 0, 0, time.UTC)
+		ev := newEvent(start)
+		ev.EndAt = timestamppb.New(start.Add(20 * time.Minute))
+		ev.TimeZone = "Europe/Berlin"
+		created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: ev})
+		require.NoError(t, err)
+		require.Equal(t, "Europe/Berlin", created.Event.TimeZone)
+
+		day, err := client.ListDay(ctx, &eventpb.ListEventsRequest{Date: "2024-03-31", TimeZone: "Europe/Berlin"})
+		require.NoError(t, err)
+		require.Len(t, day.Events, 1)
+		day, err = client.ListDay(ctx, &eventpb.ListEventsRequest{Date: "2024-03-31"})
+		require.NoError(t, err)
+		require.Empty(t, day.Events)
+
+		_, err = client.ListDay(ctx, &eventpb.ListEventsRequest{Date: "2024-03-31", TimeZone: "Mars/Olympus"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("overlap and free/busy", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+
+		first, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.NoError(t, err)
+		require.Empty(t, first.Conflicts)
+		_, err = client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime.Add(30 * time.Minute))})
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+		second, err := client.Create(ctx, &eventpb.CreateEventRequest{
+			Event: newEvent(baseTime.Add(30 * time.Minute)), AllowOverlap: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{first.Event.Id}, second.Conflicts)
+
+		fb, err := client.FreeBusy(ctx, &eventpb.FreeBusyRequest{
+			From:         timestamppb.New(baseTime.Add(-10 * time.Hour)),
+			To:           timestamppb.New(baseTime.Add(14 * time.Hour)),
+			Slots:        1,
+			SlotDuration: durationpb.New(time.Hour),
+			WorkingHours: &eventpb.WorkingHours{
+				TimeZone: "Europe/Berlin",
+				Start:    durationpb.New(11 * time.Hour),
+				End:      durationpb.New(13 * time.Hour),
+				Days:     []int32{int32(time.Monday)},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, fb.Busy, 1)
+		require.Equal(t, baseTime, fb.Busy[0].Start.AsTime())
+		require.Equal(t, baseTime.Add(90*time.Minute), fb.Busy[0].End.AsTime())
+		require.Empty(t, fb.Free)
+
+		_, err = client.FreeBusy(ctx, &eventpb.FreeBusyRequest{
+			From: timestamppb.New(baseTime), To: timestamppb.New(baseTime.Add(time.Hour)),
+			WorkingHours: &eventpb.WorkingHours{Days: []int32{7}},
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.FreeBusy(ctx, &eventpb.FreeBusyRequest{From: timestamppb.New(baseTime)})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("saved event is returned when listing conflicts fails", func(t *testing.T) {
+		conn := newAppTestConn(t, conflictsFailure{app.New(nopLogger{}, memorystorage.New())},
+			ratelimit.New(nil), nopMetrics{})
+		client := eventpb.NewEventServiceClient(conn)
+		ctx := userCtx("u1")
+
+		created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime), AllowOverlap: true})
+		require.NoError(t, err)
+		require.Empty(t, created.Conflicts)
+		_, err = client.Get(ctx, &eventpb.GetEventRequest{Id: created.Event.Id})
+		require.NoError(t, err)
+	})
+
+	t.Run("attendees and rsvp", func(t *testing.T) {
+		client := newTestClient(t)
+		e := newEvent(baseTime)
+		e.Attendees = []*eventpb.Attendee{{UserId: "u2", Status: "accepted"}}
+		created, err := client.Create(userCtx("u1"), &eventpb.CreateEventRequest{Event: e})
+		require.NoError(t, err)
+		require.Equal(t, "needs-action", created.Event.Attendees[0].Status)
+
+		day, err := client.ListDay(userCtx("u2"), &eventpb.ListEventsRequest{Date: "2024-03-04"})
+		require.NoError(t, err)
+		require.Len(t, day.Events, 1)
+
+		resp, err := client.Respond(userCtx("u2"), &eventpb.RespondRequest{Id: created.Event.Id, Status: "tentative"})
+		require.NoError(t, err)
+		require.Equal(t, "tentative", resp.Event.Attendees[0].Status)
+
+		_, err = client.Delete(userCtx("u2"), &eventpb.DeleteEventRequest{Id: created.Event.Id})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		_, err = client.Respond(userCtx("u3"), &eventpb.RespondRequest{Id: created.Event.Id, Status: "accepted"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("reminders", func(t *testing.T) {
+		client := newTestClient(t)
+		e := newEvent(baseTime)
+		e.Reminders = []*eventpb.Reminder{{Before: durationpb.New(10 * time.Minute), Channel: "email"}}
+		created, err := client.Create(userCtx("u1"), &eventpb.CreateEventRequest{Event: e})
+		require.NoError(t, err)
+		require.Len(t, created.Event.Reminders, 1)
+		require.Equal(t, 10*time.Minute, created.Event.Reminders[0].Before.AsDuration())
+		require.Equal(t, "email", created.Event.Reminders[0].Channel)
+
+		e.Reminders[0].Channel = "sms"
+		_, err = client.Create(userCtx("u1"), &eventpb.CreateEventRequest{Event: e, AllowOverlap: true})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+		created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), created.Event.Version)
+
+		updated, err := client.Update(ctx, &eventpb.UpdateEventRequest{Id: created.Event.Id, Event: created.Event})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), updated.Event.Version)
+
+		_, err = client.Update(ctx, &eventpb.UpdateEventRequest{Id: created.Event.Id, Event: created.Event})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		got, err := client.Get(ctx, &eventpb.GetEventRequest{Id: created.Event.Id})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), got.Event.Version)
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+		created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.NoError(t, err)
+
+		full, err := client.Sync(ctx, &eventpb.SyncRequest{})
+		require.NoError(t, err)
+		require.Len(t, full.Events, 1)
+		require.Equal(t, created.Event.Id, full.Events[0].Id)
+
+		_, err = client.Delete(ctx, &eventpb.DeleteEventRequest{Id: created.Event.Id})
+		require.NoError(t, err)
+		changes, err := client.Sync(ctx, &eventpb.SyncRequest{SyncToken: full.SyncToken})
+		require.NoError(t, err)
+		require.Empty(t, changes.Events)
+		require.Equal(t, []string{created.Event.Id}, changes.Deleted)
+
+		_, err = client.Sync(ctx, &eventpb.SyncRequest{SyncToken: "garbage"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		_, err = client.Sync(ctx, &eventpb.SyncRequest{SyncToken: "djE6MTAw"})
+		require.Equal(t, codes.OutOfRange, status.Code(err))
+	})
+
+	t.Run("search", func(t *testing.T) {
+		client := newTestClient(t)
+		ctx := userCtx("u1")
+		var ids []string
+		for day := 0; day < 3; day++ {
+			created, err := client.Create(ctx, &eventpb.CreateEventRequest{Event: newEvent(baseTime.AddDate(0, 0, day))})
+			require.NoError(t, err)
+			ids = append(ids, created.Event.Id)
+		}
+
+		first, err := client.Search(ctx, &eventpb.SearchRequest{Query: "Meeting", Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, first.Events, 2)
+		require.Equal(t, ids[:2], []string{first.Events[0].Id, first.Events[1].Id})
+		next, err := client.Search(ctx, &eventpb.SearchRequest{Query: "meeting", Limit: 2, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, next.Events, 1)
+		require.Equal(t, ids[2], next.Events[0].Id)
+		require.Empty(t, next.NextCursor)
+
+		inRange, err := client.Search(ctx, &eventpb.SearchRequest{
+			Query: "meeting", From: timestamppb.New(baseTime.AddDate(0, 0, 1)), To: timestamppb.New(baseTime.AddDate(0, 0, 2)),
+		})
+		require.NoError(t, err)
+		require.Len(t, inRange.Events, 1)
+		require.Equal(t, ids[1], inRange.Events[0].Id)
+
+		_, err = client.Search(ctx, &eventpb.SearchRequest{Query: "?"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		client := newTestClient(t)
+		created, err := client.Create(userCtx("u1"), &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.NoError(t, err)
+
+		_, err = client.Create(userCtx("u1"), &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+
+		_, err = client.Create(userCtx("u1"), &eventpb.CreateEventRequest{Event: &eventpb.Event{Title: "no time"}})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.Create(context.Background(), &eventpb.CreateEventRequest{Event: newEvent(baseTime)})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.Get(userCtx("u2"), &eventpb.GetEventRequest{Id: created.Event.Id})
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		_, err = client.ListDay(userCtx("u1"), &eventpb.ListEventsRequest{Date: "04.03.2024"})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("health", func(t *testing.T) {
+		client := healthpb.NewHealthClient(newTestConn(t))
+		ctx := context.Background()
+
+		for _, service := range []string{"", "event.EventService"} {
+			resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			require.NoError(t, err)
+			require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+		}
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "event.Unknown"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
@@ -0,0 +1,355 @@
+package internalhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/api"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// openAPI is api/openapi.json decoded into generic JSON values, refs are resolved on the fly.
+type openAPI map[string]interface{}
+
+func loadOpenAPI(t *testing.T) openAPI {
+	t.Helper()
+	var doc openAPI
+	require.NoError(t, json.Unmarshal(api.OpenAPI, &doc))
+	return doc
+}
+
+// resolve follows a local "$ref" of node.
+func (doc openAPI) resolve(node interface{}) map[string]interface{} {
+	m, _ := node.(map[string]interface{})
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return m
+	}
+	var cur interface{} = map[string]interface{}(doc)
+	for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		cur = cur.(map[string]interface{})[key]
+	}
+	return doc.resolve(cur)
+}
+
+// operations returns operations by ServeMux patterns like "GET /events/{id}".
+func (doc openAPI) operations() map[string]map[string]interface{} {
+	ops := map[string]map[string]interface{}{}
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for method, op := range item.(map[string]interface{}) {
+			if method == "parameters" {
+				continue
+			}
+			ops[strings.ToUpper(method)+" "+path] = op.(map[string]interface{})
+		}
+	}
+	return ops
+}
+
+// validate returns mismatches of the JSON value with the schema.
+func (doc openAPI) validate(schemaNode interface{}, v interface{}, at string) []string {
+	schema := doc.resolve(schemaNode)
+	mismatch := func(format string, args ...interface{}) []string {
+		return []string{at + ": " + fmt.Sprintf(format, args...)}
+	}
+
+	var errs []string
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch("want object, got %T", v)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				errs = append(errs, at+": missing required "+name.(string))
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for name, value := range obj {
+			if prop, ok := props[name]; ok {
+				errs = append(errs, doc.validate(prop, value, at+"."+name)...)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					errs = append(errs, at+": unknown property "+name)
+				}
+			case map[string]interface{}:
+				errs = append(errs, doc.validate(extra, value, at+"."+name)...)
+			}
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return mismatch("want array, got %T", v)
+		}
+		for i, item := range items {
+			errs = append(errs, doc.validate(schema["items"], item, at+"["+strconv.Itoa(i)+"]")...)
+		}
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return mismatch("want string, got %T", v)
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok && !contains(enum, s) {
+			errs = append(errs, mismatch("%q is not one of %v", s, enum)...)
+		}
+		if layout, ok := map[interface{}]string{"date-time": time.RFC3339, "date": dateLayout}[schema["format"]]; ok {
+			if _, err := time.Parse(layout, s); err != nil {
+				errs = append(errs, mismatch("%q is not a %s", s, schema["format"])...)
+			}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return mismatch("want integer, got %v", v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch("want boolean, got %T", v)
+		}
+	}
+	return errs
+}
+
+func contains(values []interface{}, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// exchange is a request served by the route of the pattern.
+type exchange struct {
+	pattern     string
+	requestBody []byte
+	status      int
+	contentType string
+	body        []byte
+}
+
+// recorder keeps exchanges served by the wrapped mux.
+type recorder struct {
+	mu        sync.Mutex
+	exchanges []exchange
+}
+
+func (rec *recorder) wrap(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		resp := httptest.NewRecorder()
+		mux.ServeHTTP(resp, r)
+
+		rec.mu.Lock()
+		rec.exchanges = append(rec.exchanges, exchange{
+			pattern:     r.Pattern,
+			requestBody: reqBody,
+			status:      resp.Code,
+			contentType: resp.Header().Get("Content-Type"),
+			body:        resp.Body.Bytes(),
+		})
+		rec.mu.Unlock()
+
+		for k, v := range resp.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.Code)
+		w.Write(resp.Body.Bytes())
+	})
+}
+
+func TestOpenAPI(t *testing.T) {
+	doc := loadOpenAPI(t)
+	newServer := func() *Server {
+		probes := health.Handler(health.NewChecker(nil), health.BuildInfo{Release: "test"})
+		calendar := app.New(nopLogger{}, memorystorage.New())
+		m := metrics.NewHTTP(prometheus.NewRegistry())
+		return NewServer(nopLogger{}, calendar, m, ratelimit.New(nil), probes, "")
+	}
+
+	t.Run("routes match operations", func(t *testing.T) {
+		var routes, operations []string
+		for pattern := range newServer().handlers() {
+			routes = append(routes, pattern)
+		}
+		for pattern := range doc.operations() {
+			operations = append(operations, pattern)
+		}
+		require.ElementsMatch(t, operations, routes)
+	})
+
+	t.Run("served document", func(t *testing.T) {
+		ts := httptest.NewServer(newServer().Handler())
+		t.Cleanup(ts.Close)
+
+		status, body := doRequest(t, ts, http.MethodGet, "/openapi.json", "", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, string(api.OpenAPI), string(body))
+	})
+
+	t.Run("exchanges match schemas", func(t *testing.T) {
+		rec := &recorder{}
+		ts := httptest.NewServer(rec.wrap(newServer().routes()))
+		t.Cleanup(ts.Close)
+		runContractScenario(t, ts)
+
+		ops := doc.operations()
+		covered := map[string]bool{}
+		for _, ex := range rec.exchanges {
+			op, ok := ops[ex.pattern]
+			require.True(t, ok, "no operation for %q", ex.pattern)
+			covered[ex.pattern] = true
+			at := fmt.Sprintf("%s %d", ex.pattern, ex.status)
+
+			if reqBody := doc.resolve(op["requestBody"]); reqBody != nil && len(ex.requestBody) > 0 {
+				if media := doc.resolve(reqBody["content"].(map[string]interface{})["application/json"]); media != nil {
+					var v interface{}
+					require.NoError(t, json.Unmarshal(ex.requestBody, &v), at)
+					require.Empty(t, doc.validate(media["schema"], v, "request"), at)
+				}
+			}
+
+			resp := doc.resolve(op["responses"].(map[string]interface{})[strconv.Itoa(ex.status)])
+			require.NotNil(t, resp, "%s: status is not documented, body %s", at, ex.body)
+			content, _ := resp["content"].(map[string]interface{})
+			if content == nil {
+				require.Empty(t, ex.body, at)
+				continue
+			}
+			mediaType, _, _ := strings.Cut(ex.contentType, ";")
+			media, ok := content[mediaType]
+			require.True(t, ok, "%s: content type %q is not documented", at, ex.contentType)
+			if mediaType == "application/json" {
+				var v interface{}
+				require.NoError(t, json.Unmarshal(ex.body, &v), at)
+				require.Empty(t, doc.validate(doc.resolve(media)["schema"], v, "response"), "%s: %s", at, ex.body)
+			}
+		}
+
+		var uncovered []string
+		for pattern := range ops {
+			if !covered[pattern] {
+				uncovered = append(uncovered, pattern)
+			}
+		}
+		sort.Strings(uncovered)
+		require.Empty(t, uncovered, "operations the scenario does not exercise")
+	})
+}
+
+// runContractScenario calls every operation with all request fields set and provokes errors.
+func runContractScenario(t *testing.T, ts *httptest.Server) {
+	t.Helper()
+	start := time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+	occurrenceEvent := func(start time.Time) string {
+		return fmt.Sprintf(`{"title":"standup","start_at":%q,"end_at":%q,"description":"daily",`+
+			`"notify_before":"15m","time_zone":"Europe/Berlin","attendees":["u2"]}`,
+			start.Format(time.RFC3339), start.Add(30*time.Minute).Format(time.RFC3339))
+	}
+	event := func(start time.Time) string {
+		return strings.TrimSuffix(occurrenceEvent(start), "}") +
+			fmt.Sprintf(`,"rrule":"FREQ=WEEKLY","exdates":[%q]}`, start.AddDate(0, 0, 14).Format(time.RFC3339))
+	}
+	do := func(method, path, userID, body string, header ...string) (int, []byte, http.Header) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body)) //nolint:noctx
+		require.NoError(t, err)
+		req.Header.Set(UserIDHeader, userID)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, respBody, resp.Header
+	}
+
+	status, body, _ := do(http.MethodPost, "/events", "u1", event(start))
+	require.Equal(t, http.StatusCreated, status, string(body))
+	id := decode[eventResponse](t, body).ID
+	status, _, _ = do(http.MethodPost, "/events", "u1", event(start))
+	require.Equal(t, http.StatusConflict, status)
+	status, _, _ = do(http.MethodPost, "/events?allow_overlap=true", "u1", event(start))
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _, header := do(http.MethodGet, "/events/"+id, "u1", "")
+	require.Equal(t, http.StatusOK, status)
+	status, _, _ = do(http.MethodGet, "/events/"+id, "u3", "")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _, _ = do(http.MethodPut, "/events/"+id+"?allow_overlap=true", "u1", event(start),
+		"If-Match", header.Get("ETag"))
+	require.Equal(t, http.StatusOK, status)
+	status, _, _ = do(http.MethodPut, "/events/"+id, "u1", event(start), "If-Match", header.Get("ETag"))
+	require.Equal(t, http.StatusPreconditionFailed, status)
+	status, _, _ = do(http.MethodPut, "/events/"+id+"/rsvp", "u2", `{"status":"accepted"}`)
+	require.Equal(t, http.StatusOK, status)
+
+	occurrence := "/events/" + id + "/occurrences/" + start.AddDate(0, 0, 7).Format(time.RFC3339)
+	status, body, _ = do(http.MethodPut, occurrence+"?allow_overlap=true", "u1",
+		occurrenceEvent(start.AddDate(0, 0, 7).Add(time.Hour)))
+	require.Equal(t, http.StatusOK, status, string(body))
+	cancelled := "/events/" + id + "/occurrences/" + start.AddDate(0, 0, 21).Format(time.RFC3339)
+	status, _, _ = do(http.MethodDelete, cancelled, "u1", "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	for _, period := range []string{"day", "week", "month"} {
+		status, _, _ = do(http.MethodGet, "/events/"+period+"?date=2024-03-04&tz=Europe/Berlin", "u1", "")
+		require.Equal(t, http.StatusOK, status)
+	}
+	status, _, _ = do(http.MethodGet, "/events/day", "u1", "")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, ics, _ := do(http.MethodGet, "/events/export?from=2024-03-01&to=2024-03-31", "u1", "")
+	require.Equal(t, http.StatusOK, status)
+	status, _, _ = do(http.MethodPost, "/events/import", "u1", string(ics))
+	require.Equal(t, http.StatusOK, status)
+
+	status, _, _ = do(http.MethodGet, "/events/freebusy?from=2024-03-04&to=2024-03-08&slots=2&duration=30m", "u1", "")
+	require.Equal(t, http.StatusOK, status)
+	status, _, _ = do(http.MethodGet, "/events/sync", "u1", "")
+	require.Equal(t, http.StatusOK, status)
+	status, body, _ = do(http.MethodGet, "/events/search?q=Daily+standup&limit=1", "u1", "")
+	require.Equal(t, http.StatusOK, status)
+	page := decode[searchResponse](t, body)
+	require.NotEmpty(t, page.NextCursor)
+	status, _, _ = do(http.MethodGet, "/events/search?q=standup&from=2024-03-01&to=2024-03-31&tz=Europe/Berlin"+
+		"&owner=u1&cursor="+page.NextCursor, "u2", "")
+	require.Equal(t, http.StatusOK, status)
+	status, _, _ = do(http.MethodGet, "/events/search?q=", "u1", "")
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _, _ = do(http.MethodGet, "/events/sync?sync_token=bogus", "u1", "")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status, _, _ = do(http.MethodDelete, "/events/"+id, "u2", "")
+	require.Equal(t, http.StatusForbidden, status)
+	status, _, _ = do(http.MethodDelete, "/events/"+id, "u1", "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	for _, path := range []string{"/healthz", "/readyz", "/version", "/metrics", "/openapi.json"} {
+		status, _, _ = do(http.MethodGet, path, "", "")
+		require.Equal(t, http.StatusOK, status, path)
+	}
+}
@@ -0,0 +1,273 @@
+package internalhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// dateLayout is the format of the "date" query parameter of list endpoints.
+const dateLayout = "2006-01-02"
+
+// zoneParam is the query parameter with the IANA time zone dates are given in, UTC by default.
+const zoneParam = "tz"
+
+// allowOverlapParam is the query parameter that makes create and update requests save events
+// overlapping other events of the user and list them in conflicts instead of failing with 409.
+const allowOverlapParam = "allow_overlap"
+
+// syncTokenParam is the query parameter with the token of the previous sync.
+const syncTokenParam = "sync_token"
+
+// cursorParam is the query parameter with the cursor of the search page to continue from.
+const cursorParam = "cursor"
+
+// clockLayout is the format of working hours of the free/busy endpoint.
+const clockLayout = "15:04"
+
+type eventRequest struct {
+	Title        string    `json:"title"`
+	StartAt      time.Time `json:"start_at"`
+	EndAt        time.Time `json:"end_at"`
+	Description  string    `json:"description"`
+	NotifyBefore duration  `json:"notify_before"`
+	// TimeZone is an IANA time zone name like "Europe/Berlin", UTC by default.
+	TimeZone string `json:"time_zone,omitempty"`
+	// RRule is a recurrence rule in RFC 5545 format, e.g. "FREQ=WEEKLY;BYDAY=MO,WE".
+	RRule   string      `json:"rrule,omitempty"`
+	ExDates []time.Time `json:"exdates,omitempty"`
+	// Attendees are IDs of the invited users.
+	Attendees []string `json:"attendees,omitempty"`
+	// Reminders are sent through their channel in addition to NotifyBefore, which goes through every one.
+	Reminders []reminderDTO `json:"reminders,omitempty"`
+}
+
+func (r eventRequest) toEvent() storage.Event {
+	var attendees []storage.Attendee
+	for _, userID := range r.Attendees {
+		attendees = append(attendees, storage.Attendee{UserID: userID})
+	}
+	var reminders []storage.Reminder
+	for _, rem := range r.Reminders {
+		reminders = append(reminders, storage.Reminder{Before: time.Duration(rem.Before), Channel: rem.Channel})
+	}
+	return storage.Event{
+		Title:        r.Title,
+		StartAt:      r.StartAt,
+		EndAt:        r.EndAt,
+		Description:  r.Description,
+		NotifyBefore: time.Duration(r.NotifyBefore),
+		TimeZone:     r.TimeZone,
+		RRule:        r.RRule,
+		ExDates:      r.ExDates,
+		Attendees:    attendees,
+		Reminders:    reminders,
+	}
+}
+
+type rsvpRequest struct {
+	Status storage.RSVPStatus `json:"status"`
+}
+
+type eventResponse struct {
+	ID           string      `json:"id"`
+	Title        string      `json:"title"`
+	StartAt      time.Time   `json:"start_at"`
+	EndAt        time.Time   `json:"end_at"`
+	Description  string      `json:"description"`
+	UserID       string      `json:"user_id"`
+	NotifyBefore duration    `json:"notify_before"`
+	TimeZone     string      `json:"time_zone"`
+	RRule        string      `json:"rrule,omitempty"`
+	ExDates      []time.Time `json:"exdates,omitempty"`
+	// SeriesID and RecurrenceID link an edited occurrence to its recurring event.
+	SeriesID     string             `json:"series_id,omitempty"`
+	RecurrenceID *time.Time         `json:"recurrence_id,omitempty"`
+	UID          string             `json:"uid"`
+	Attendees    []attendeeResponse `json:"attendees,omitempty"`
+	Reminders    []reminderDTO      `json:"reminders,omitempty"`
+	// Version is also sent as the ETag header and is expected in If-Match of updates.
+	Version int64 `json:"version"`
+	// Conflicts are IDs of the events the saved event overlaps, see allowOverlapParam.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// toEventResponse renders event times in the time zone of the event.
+func toEventResponse(e storage.Event) eventResponse {
+	loc := e.Location()
+	resp := eventResponse{
+		ID:           e.ID,
+		Title:        e.Title,
+		StartAt:      e.StartAt.In(loc),
+		EndAt:        e.EndAt.In(loc),
+		Description:  e.Description,
+		UserID:       e.UserID,
+		NotifyBefore: duration(e.NotifyBefore),
+		TimeZone:     e.TimeZone,
+		RRule:        e.RRule,
+		ExDates:      e.ExDates,
+		SeriesID:     e.SeriesID,
+		UID:          e.UID,
+		Version:      e.Version,
+	}
+	if !e.RecurrenceID.IsZero() {
+		resp.RecurrenceID = &e.RecurrenceID
+	}
+	for _, a := range e.Attendees {
+		resp.Attendees = append(resp.Attendees, attendeeResponse{UserID: a.UserID, Status: a.Status})
+	}
+	for _, r := range e.Reminders {
+		resp.Reminders = append(resp.Reminders, reminderDTO{Before: duration(r.Before), Channel: r.Channel})
+	}
+	return resp
+}
+
+type attendeeResponse struct {
+	UserID string             `json:"user_id"`
+	Status storage.RSVPStatus `json:"status"`
+}
+
+type reminderDTO struct {
+	Before  duration        `json:"before"`
+	Channel storage.Channel `json:"channel"`
+}
+
+type eventsResponse struct {
+	Events []eventResponse `json:"events"`
+}
+
+func toEventsResponse(events []storage.Event) eventsResponse {
+	resp := eventsResponse{Events: make([]eventResponse, 0, len(events))}
+	for _, e := range events {
+		resp.Events = append(resp.Events, toEventResponse(e))
+	}
+	return resp
+}
+
+// syncResponse is the answer of the sync endpoint, see app.SyncResult.
+type syncResponse struct {
+	Events    []eventResponse `json:"events"`
+	Deleted   []string        `json:"deleted"`
+	SyncToken string          `json:"sync_token"`
+}
+
+func toSyncResponse(result app.SyncResult) syncResponse {
+	return syncResponse{
+		Events:    toEventsResponse(result.Events).Events,
+		Deleted:   result.Deleted,
+		SyncToken: result.Token,
+	}
+}
+
+// searchResponse is a page of events found by the search endpoint, see app.SearchResult.
+type searchResponse struct {
+	Events []eventResponse `json:"events"`
+	// NextCursor is the cursorParam of the next page, omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func toSearchResponse(result app.SearchResult) searchResponse {
+	return searchResponse{Events: toEventsResponse(result.Events).Events, NextCursor: result.Cursor}
+}
+
+// importResponse reports the outcome of importing every VEVENT of an iCalendar file.
+type importResponse struct {
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Failed    int                   `json:"failed"`
+	Entries   []importEntryResponse `json:"entries"`
+}
+
+type importEntryResponse struct {
+	// Line is the number of the BEGIN:VEVENT line of the entry.
+	Line         int              `json:"line"`
+	UID          string           `json:"uid"`
+	RecurrenceID *time.Time       `json:"recurrence_id,omitempty"`
+	ID           string           `json:"id,omitempty"`
+	Status       app.ImportStatus `json:"status"`
+	Error        string           `json:"error,omitempty"`
+}
+
+func toImportResponse(results []app.ImportResult) importResponse {
+	resp := importResponse{Entries: make([]importEntryResponse, 0, len(results))}
+	for _, r := range results {
+		entry := importEntryResponse{Line: r.Line, UID: r.UID, ID: r.ID, Status: r.Status}
+		if !r.RecurrenceID.IsZero() {
+			entry.RecurrenceID = &r.RecurrenceID
+		}
+		switch r.Status {
+		case app.ImportCreated:
+			resp.Created++
+		case app.ImportUpdated:
+			resp.Updated++
+		case app.ImportUnchanged:
+			resp.Unchanged++
+		case app.ImportFailed:
+			resp.Failed++
+			entry.Error = r.Err.Error()
+		}
+		resp.Entries = append(resp.Entries, entry)
+	}
+	return resp
+}
+
+type freeBusyResponse struct {
+	Busy []intervalResponse `json:"busy"`
+	Free []intervalResponse `json:"free"`
+}
+
+type intervalResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// toFreeBusyResponse renders intervals in the requested time zone.
+func toFreeBusyResponse(fb app.FreeBusy, loc *time.Location) freeBusyResponse {
+	return freeBusyResponse{Busy: toIntervalsResponse(fb.Busy, loc), Free: toIntervalsResponse(fb.Free, loc)}
+}
+
+func toIntervalsResponse(intervals []app.Interval, loc *time.Location) []intervalResponse {
+	resp := make([]intervalResponse, 0, len(intervals))
+	for _, in := range intervals {
+		resp = append(resp, intervalResponse{Start: in.Start.In(loc), End: in.End.In(loc)})
+	}
+	return resp
+}
+
+// errorResponse is the body of every non-2xx response.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// duration is a time.Duration represented in JSON as a string like "1h30m".
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15m\": %w", err)
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
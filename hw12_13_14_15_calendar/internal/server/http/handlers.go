@@ -0,0 +1,545 @@
+package internalhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/api"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+const (
+	maxBodySize   = 1 << 20
+	maxImportSize = 10 << 20
+)
+
+var (
+	errBadRequest  = errors.New("bad request")
+	errNoDate      = errors.New(`query parameter "date" is required`)
+	errNoRange     = errors.New(`query parameters "from" and "to" are required`)
+	errRateLimited = errors.New("rate limit exceeded")
+)
+
+func (s *Server) createEvent(w http.ResponseWriter, r *http.Request) {
+	var req eventRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	ctx, allowOverlap, err := overlapContext(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	e, err := s.app.CreateEvent(ctx, userID(r), req.toEvent())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeSaved(w, r, http.StatusCreated, e, allowOverlap)
+}
+
+func (s *Server) getEvent(w http.ResponseWriter, r *http.Request) {
+	e, err := s.app.GetEvent(r.Context(), userID(r), r.PathValue("id"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(e))
+	s.writeJSON(w, r, http.StatusOK, toEventResponse(e))
+}
+
+// updateEvent replaces the event, only the version in If-Match if the header is sent.
+func (s *Server) updateEvent(w http.ResponseWriter, r *http.Request) {
+	var req eventRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	ctx, allowOverlap, err := overlapContext(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	e := req.toEvent()
+	if e.Version, err = ifMatch(r); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	e, err = s.app.UpdateEvent(ctx, userID(r), r.PathValue("id"), e)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeSaved(w, r, http.StatusOK, e, allowOverlap)
+}
+
+// respondToEvent sets the RSVP status of the user attending the event.
+func (s *Server) respondToEvent(w http.ResponseWriter, r *http.Request) {
+	var req rsvpRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	e, err := s.app.RespondToEvent(r.Context(), userID(r), r.PathValue("id"), req.Status)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(e))
+	s.writeJSON(w, r, http.StatusOK, toEventResponse(e))
+}
+
+func (s *Server) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	if err := s.app.DeleteEvent(r.Context(), userID(r), r.PathValue("id")); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateOccurrence detaches the occurrence, only from the series version in If-Match
+// if the header is sent.
+func (s *Server) updateOccurrence(w http.ResponseWriter, r *http.Request) {
+	start, err := parseOccurrence(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	var req eventRequest
+	if err := decodeBody(w, r, &req); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	ctx, allowOverlap, err := overlapContext(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	e := req.toEvent()
+	if e.Version, err = ifMatch(r); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	e, err = s.app.UpdateOccurrence(ctx, userID(r), r.PathValue("id"), start, e)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeSaved(w, r, http.StatusOK, e, allowOverlap)
+}
+
+// deleteOccurrence excludes the occurrence, only from the series version in If-Match
+// if the header is sent.
+func (s *Server) deleteOccurrence(w http.ResponseWriter, r *http.Request) {
+	start, err := parseOccurrence(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if err := s.app.DeleteOccurrence(r.Context(), userID(r), r.PathValue("id"), start, version); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// exportEvents serves user's events from the "from" till the "to" day inclusive as an iCalendar file.
+func (s *Server) exportEvents(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseRange(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	events, err := s.app.ExportEvents(r.Context(), userID(r), from, to)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", ical.ContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar.ics"`)
+	if err := ical.Encode(w, events, time.Now()); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "err", err)
+	}
+}
+
+// importEvents creates or updates events from an iCalendar file in the request body.
+// Entries that cannot be imported are reported in the response, not as an error.
+func (s *Server) importEvents(w http.ResponseWriter, r *http.Request) {
+	entries, err := ical.Decode(http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		s.writeError(w, r, fmt.Errorf("%w: %w", errBadRequest, err))
+		return
+	}
+
+	results, err := s.app.ImportEvents(r.Context(), userID(r), entries)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, toImportResponse(results))
+}
+
+// freeBusy serves busy intervals of the user from the "from" till the "to" day inclusive
+// and suggests free slots within working hours.
+func (s *Server) freeBusy(w http.ResponseWriter, r *http.Request) {
+	q, err := parseFreeBusyQuery(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	fb, err := s.app.FreeBusy(r.Context(), userID(r), q)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, toFreeBusyResponse(fb, q.WorkingHours.Location))
+}
+
+// sync serves events changed since the syncTokenParam token, all of user's events without it.
+func (s *Server) sync(w http.ResponseWriter, r *http.Request) {
+	result, err := s.app.Sync(r.Context(), userID(r), r.URL.Query().Get(syncTokenParam))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, toSyncResponse(result))
+}
+
+// searchEvents serves a page of events whose title or description contain every word
+// of the "q" query parameter, see parseSearchQuery.
+func (s *Server) searchEvents(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSearchQuery(r)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	result, err := s.app.SearchEvents(r.Context(), userID(r), q)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, toSearchResponse(result))
+}
+
+// openAPI serves the OpenAPI document of the server.
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(api.OpenAPI); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "err", err)
+	}
+}
+
+type listFunc func(ctx context.Context, userID string, date time.Time) ([]storage.Event, error)
+
+// listEvents serves listing of events for the period starting at the "date" query parameter.
+func (s *Server) listEvents(list listFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		date, err := parseDate(r)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		events, err := list(r.Context(), userID(r), date)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		s.writeJSON(w, r, http.StatusOK, toEventsResponse(events))
+	}
+}
+
+func userID(r *http.Request) string {
+	return r.Header.Get(UserIDHeader)
+}
+
+// parseDate parses the "date" query parameter as the start of the day in the requested zone.
+func parseDate(r *http.Request) (time.Time, error) {
+	loc, err := parseZone(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	raw := r.URL.Query().Get("date")
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("%w: %w", errBadRequest, errNoDate)
+	}
+	date, err := time.Parse(dateLayout, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: date must be in YYYY-MM-DD format", errBadRequest)
+	}
+	return storage.DateIn(date, loc), nil
+}
+
+func parseZone(r *http.Request) (*time.Location, error) {
+	loc, err := storage.LoadLocation(r.URL.Query().Get(zoneParam))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+	return loc, nil
+}
+
+// parseRange parses the "from" and "to" query parameters into the range from the start
+// of the "from" day till the end of the "to" day in the requested zone.
+func parseRange(r *http.Request) (from, to time.Time, err error) {
+	loc, err := parseZone(r)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	rawFrom, rawTo := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if rawFrom == "" || rawTo == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %w", errBadRequest, errNoRange)
+	}
+	from, err = time.Parse(dateLayout, rawFrom)
+	if err == nil {
+		to, err = time.Parse(dateLayout, rawTo)
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from and to must be in YYYY-MM-DD format", errBadRequest)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must not be before from", errBadRequest)
+	}
+	_, to = storage.DayRange(storage.DateIn(to, loc))
+	return storage.DateIn(from, loc), to, nil
+}
+
+// overlapContext parses the allowOverlapParam query parameter and returns the context
+// the event should be saved in.
+func overlapContext(r *http.Request) (context.Context, bool, error) {
+	raw := r.URL.Query().Get(allowOverlapParam)
+	if raw == "" {
+		return r.Context(), false, nil
+	}
+	allow, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %s must be true or false", errBadRequest, allowOverlapParam)
+	}
+	if !allow {
+		return r.Context(), false, nil
+	}
+	return storage.WithOverlap(r.Context()), true, nil
+}
+
+// etag returns the entity tag of the event version.
+func etag(e storage.Event) string {
+	return strconv.Quote(strconv.FormatInt(e.Version, 10))
+}
+
+// ifMatch returns the event version required by the If-Match header, zero when any version
+// matches. A header that is not a single entity tag is a bad request; a weak tag or one that
+// etag does not return is well-formed but matches no event.
+func ifMatch(r *http.Request) (int64, error) {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, nil
+	}
+	tag, weak := strings.CutPrefix(raw, "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' || strings.Contains(tag[1:len(tag)-1], `"`) {
+		return 0, fmt.Errorf("%w: If-Match must be a single entity tag like \"1\", got %s", errBadRequest, raw)
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if weak || err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: If-Match %s is not an event version", storage.ErrVersionMismatch, raw)
+	}
+	return version, nil
+}
+
+// parseFreeBusyQuery parses the range of parseRange, the "slots" number of free slots to suggest
+// and their "duration", and working hours from "work_start" till "work_end" on "work_days"
+// in the requested zone. Unset parameters default to app.DefaultWorkingHours and no slots.
+func parseFreeBusyQuery(r *http.Request) (app.FreeBusyQuery, error) {
+	from, to, err := parseRange(r)
+	if err != nil {
+		return app.FreeBusyQuery{}, err
+	}
+	loc, err := parseZone(r)
+	if err != nil {
+		return app.FreeBusyQuery{}, err
+	}
+	query := r.URL.Query()
+	q := app.FreeBusyQuery{From: from, To: to, WorkingHours: app.DefaultWorkingHours}
+	q.WorkingHours.Location = loc
+
+	if raw := query.Get("slots"); raw != "" {
+		if q.Slots, err = strconv.Atoi(raw); err != nil {
+			return app.FreeBusyQuery{}, fmt.Errorf("%w: slots must be a number", errBadRequest)
+		}
+	}
+	if raw := query.Get("duration"); raw != "" {
+		if q.SlotDuration, err = time.ParseDuration(raw); err != nil {
+			return app.FreeBusyQuery{}, fmt.Errorf("%w: duration must be like \"30m\"", errBadRequest)
+		}
+	}
+	if raw := query.Get("work_start"); raw != "" {
+		if q.WorkingHours.Start, err = parseClock(raw); err != nil {
+			return app.FreeBusyQuery{}, err
+		}
+	}
+	if raw := query.Get("work_end"); raw != "" {
+		if q.WorkingHours.End, err = parseClock(raw); err != nil {
+			return app.FreeBusyQuery{}, err
+		}
+	}
+	if raw := query.Get("work_days"); raw != "" {
+		if q.WorkingHours.Days, err = parseWeekdays(raw); err != nil {
+			return app.FreeBusyQuery{}, err
+		}
+	}
+	return q, nil
+}
+
+// parseSearchQuery parses the "q" search text, the "owner" of events, the optional range
+// of parseRange, the "limit" of events per page and the cursorParam of the page.
+func parseSearchQuery(r *http.Request) (app.SearchQuery, error) {
+	query := r.URL.Query()
+	q := app.SearchQuery{Text: query.Get("q"), OwnerID: query.Get("owner"), Cursor: query.Get(cursorParam)}
+	if query.Get("from") != "" || query.Get("to") != "" {
+		var err error
+		if q.From, q.To, err = parseRange(r); err != nil {
+			return app.SearchQuery{}, err
+		}
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return app.SearchQuery{}, fmt.Errorf("%w: limit must be a number", errBadRequest)
+		}
+		q.Limit = limit
+	}
+	return q, nil
+}
+
+// parseClock parses a wall clock time in HH:MM format, 24:00 being the end of the day.
+func parseClock(raw string) (time.Duration, error) {
+	if raw == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse(clockLayout, raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: working hours must be in HH:MM format", errBadRequest)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// parseWeekdays parses a comma-separated list of two-letter days of week like "MO,TU".
+func parseWeekdays(raw string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range strings.Split(raw, ",") {
+		day, ok := weekdays[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown day of week %q", errBadRequest, name)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+// parseOccurrence parses the "start" path value identifying an occurrence of a recurring event.
+func parseOccurrence(r *http.Request) (time.Time, error) {
+	start, err := time.Parse(time.RFC3339, r.PathValue("start"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: occurrence start must be in RFC 3339 format", errBadRequest)
+	}
+	return start, nil
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(dst); err != nil {
+		return fmt.Errorf("%w: invalid JSON body: %s", errBadRequest, err.Error())
+	}
+	return nil
+}
+
+// writeSaved writes a saved event with IDs of the events it overlaps when overlaps are allowed.
+// The event is saved already, so a failure to list them is logged and the event is written without
+// them rather than failing the request the client would retry.
+func (s *Server) writeSaved(w http.ResponseWriter, r *http.Request, status int, e storage.Event, allowOverlap bool) {
+	resp := toEventResponse(e)
+	if allowOverlap {
+		conflicts, err := s.app.ListConflicts(r.Context(), e)
+		if err != nil {
+			s.logger.ErrorContext(r.Context(), "failed to list conflicts of saved event", "event_id", e.ID, "err", err)
+		}
+		for _, c := range conflicts {
+			resp.Conflicts = append(resp.Conflicts, c.ID)
+		}
+	}
+	w.Header().Set("ETag", etag(e))
+	s.writeJSON(w, r, status, resp)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.logger.ErrorContext(r.Context(), "failed to write response", "err", err)
+	}
+}
+
+// writeError maps application errors to HTTP statuses and writes them as errorResponse.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := http.StatusInternalServerError, "internal"
+	switch {
+	case errors.Is(err, errBadRequest):
+		status, code = http.StatusBadRequest, "bad_request"
+	case errors.Is(err, app.ErrNoUser):
+		status, code = http.StatusBadRequest, "no_user"
+	case errors.Is(err, storage.ErrInvalidEvent):
+		status, code = http.StatusBadRequest, "invalid_event"
+	case errors.Is(err, app.ErrInvalidQuery):
+		status, code = http.StatusBadRequest, "invalid_query"
+	case errors.Is(err, app.ErrInvalidSyncToken):
+		status, code = http.StatusBadRequest, "invalid_sync_token"
+	case errors.Is(err, app.ErrForbidden):
+		status, code = http.StatusForbidden, "forbidden"
+	case errors.Is(err, storage.ErrNotFound):
+		status, code = http.StatusNotFound, "not_found"
+	case errors.Is(err, storage.ErrDateBusy):
+		status, code = http.StatusConflict, "date_busy"
+	case errors.Is(err, storage.ErrUIDTaken):
+		status, code = http.StatusConflict, "uid_taken"
+	case errors.Is(err, storage.ErrVersionMismatch):
+		status, code = http.StatusPreconditionFailed, "version_mismatch"
+	case errors.Is(err, app.ErrSyncTokenExpired):
+		status, code = http.StatusGone, "sync_token_expired"
+	case errors.Is(err, errRateLimited):
+		status, code = http.StatusTooManyRequests, "rate_limited"
+	}
+
+	msg := err.Error()
+	if status == http.StatusInternalServerError {
+		s.logger.ErrorContext(r.Context(), "request failed", "err", err)
+		msg = http.StatusText(status)
+	}
+	s.writeJSON(w, r, status, errorResponse{Error: errorBody{Code: code, Message: msg}})
+}
@@ -1,11 +1,125 @@
 package internalhttp
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/google/uuid"
 )
 
-func loggingMiddleware(next http.Handler) http.Handler {
+// RequestIDHeader carries the ID of a request; it is generated unless passed by the client.
+const RequestIDHeader = "X-Request-ID"
+
+// accessLogTimeLayout is the time format of access log lines, e.g. 25/Feb/2020:19:11:24 +0600.
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// loggingMiddleware writes an access log line for every request, e.g.
+// 66.249.65.3 [25/Feb/2020:19:11:24 +0600] GET /hello?q=1 HTTP/1.1 200 30 "Mozilla/5.0",
+// where 30 is the latency in milliseconds. Request ID is attached to the request context.
+func loggingMiddleware(logger Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logger.ContextWith(r.Context(), "request_id", requestID)
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		latency := time.Since(start)
+		ip := clientIP(r)
+		logger.InfoContext(ctx,
+			fmt.Sprintf("%s [%s] %s %s %s %d %d %q",
+				ip, start.Format(accessLogTimeLayout), r.Method, r.URL.RequestURI(), r.Proto,
+				rw.status, latency.Milliseconds(), r.UserAgent()),
+			"ip", ip,
+			"method", r.Method,
+			"path", r.URL.RequestURI(),
+			"proto", r.Proto,
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"latency", latency,
+			"user_agent", r.UserAgent(),
+		)
+	})
+}
+
+// metricsMiddleware records every request by the route it matched. It must wrap the mux directly,
+// since the mux reports the matched pattern in the request it is given.
+func metricsMiddleware(metrics Metrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		metrics.ObserveRequest(r.Pattern, rw.status, time.Since(start))
+	})
+}
+
+// rateLimitMiddleware rejects requests of a user over the limit of the route class with 429 and
+// Retry-After. GET requests are reads, the others are writes. Requests without a user, such as probes
+// and metrics scrapes, are not limited. It must be registered in the mux to see the matched route.
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO
+		userID := r.Header.Get(UserIDHeader)
+		if userID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		class := ratelimit.ClassWrite
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			class = ratelimit.ClassRead
+		}
+		if ok, wait := s.limiter.Allow(userID, class); !ok {
+			s.metrics.ObserveRateLimited(r.Pattern, string(class))
+			retryAfter := ratelimit.RetryAfterSeconds(wait)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			s.writeError(w, r, fmt.Errorf("%w: retry in %d s", errRateLimited, retryAfter))
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// responseWriter captures the status code and the number of bytes written to the response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
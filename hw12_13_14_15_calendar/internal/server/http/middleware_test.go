@@ -0,0 +1,147 @@
+package internalhttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+type logRecord struct {
+	msg    string
+	fields map[string]interface{}
+}
+
+// captureLogger keeps Info records written with context fields merged in.
+type captureLogger struct {
+	nopLogger
+	mu      sync.Mutex
+	records []logRecord
+}
+
+type ctxFieldsKey struct{}
+
+func (l *captureLogger) ContextWith(ctx context.Context, args ...interface{}) context.Context {
+	fields := map[string]interface{}{}
+	if prev, ok := ctx.Value(ctxFieldsKey{}).(map[string]interface{}); ok {
+		for k, v := range prev {
+			fields[k] = v
+		}
+	}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[args[i].(string)] = args[i+1]
+	}
+	return context.WithValue(ctx, ctxFieldsKey{}, fields)
+}
+
+func (l *captureLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	fields := l.ContextWith(ctx, args...).Value(ctxFieldsKey{}).(map[string]interface{})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, logRecord{msg: msg, fields: fields})
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	t.Run("access log line", func(t *testing.T) {
+		logg := &captureLogger{}
+		var ctxRequestID interface{}
+		h := loggingMiddleware(logg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctxRequestID = r.Context().Value(ctxFieldsKey{}).(map[string]interface{})["request_id"]
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("short and stout"))
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/hello?q=1", nil)
+		req.RemoteAddr = "66.249.65.3:53412"
+		req.Header.Set("User-Agent", "Mozilla/5.0")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusTeapot, rec.Code)
+		require.Len(t, logg.records, 1)
+		record := logg.records[0]
+		require.Regexp(t,
+			regexp.MustCompile(`^66\.249\.65\.3 \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] `+
+				`GET /hello\?q=1 HTTP/1\.1 418 \d+ "Mozilla/5\.0"$`),
+			record.msg)
+		require.Equal(t, 418, record.fields["status"])
+		require.Equal(t, 15, record.fields["bytes"])
+		require.Equal(t, "66.249.65.3", record.fields["ip"])
+		require.NotEmpty(t, rec.Header().Get(RequestIDHeader))
+		require.Equal(t, rec.Header().Get(RequestIDHeader), record.fields["request_id"])
+		require.Equal(t, record.fields["request_id"], ctxRequestID)
+	})
+
+	t.Run("implicit status and client request id", func(t *testing.T) {
+		logg := &captureLogger{}
+		h := loggingMiddleware(logg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+			w.WriteHeader(http.StatusInternalServerError) // superfluous, ignored by net/http.
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/events", nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		require.Len(t, logg.records, 1)
+		require.Equal(t, http.StatusOK, logg.records[0].fields["status"])
+		require.Equal(t, 2, logg.records[0].fields["bytes"])
+		require.Equal(t, "req-1", logg.records[0].fields["request_id"])
+	})
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	ts := newTestServer(t)
+
+	status, _ := doRequest(t, ts, http.MethodGet, "/events/8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b", "u1", "")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _ = doRequest(t, ts, http.MethodGet, "/nowhere", "u1", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	status, body := doRequest(t, ts, http.MethodGet, "/metrics", "", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, string(body), `calendar_http_requests_total{route="GET /events/{id}",status="404"} 1`)
+	require.Contains(t, string(body), `calendar_http_requests_total{route="unmatched",status="404"} 1`)
+	require.Contains(t, string(body),
+		`calendar_http_request_duration_seconds_count{route="GET /events/{id}",status="404"} 1`)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	ts := newLimitedTestServer(t, ratelimit.New(map[ratelimit.Class]ratelimit.Limit{
+		ratelimit.ClassWrite: {Rate: 0.01, Burst: 1},
+	}))
+	path := "/events/8f2b1c3e-5d4a-4e6f-9a7b-0c1d2e3f4a5b"
+
+	status, _ := doRequest(t, ts, http.MethodDelete, path, "u1", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodDelete, ts.URL+path, nil)
+	require.NoError(t, err)
+	req.Header.Set(UserIDHeader, "u1")
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.Equal(t, "100", resp.Header.Get("Retry-After"))
+	var body errorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, "rate_limited", body.Error.Code)
+
+	status, _ = doRequest(t, ts, http.MethodDelete, path, "u2", "")
+	require.Equal(t, http.StatusNotFound, status, "another user")
+	status, _ = doRequest(t, ts, http.MethodGet, path, "u1", "")
+	require.Equal(t, http.StatusNotFound, status, "reads are not limited")
+
+	status, metricsBody := doRequest(t, ts, http.MethodGet, "/metrics", "", "")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, string(metricsBody),
+		`calendar_http_rate_limited_total{class="write",route="DELETE /events/{id}"} 1`)
+	require.Contains(t, string(metricsBody), `calendar_http_requests_total{route="DELETE /events/{id}",status="429"} 1`)
+}
@@ -2,30 +2,131 @@ package internalhttp
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ical"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
 )
 
-type Server struct { // TODO
+// UserIDHeader carries the ID of the user on whose behalf a request is made.
+const UserIDHeader = "X-User-ID"
+
+type Server struct {
+	logger  Logger
+	app     Application
+	metrics Metrics
+	limiter Limiter
+	probes  http.Handler
+	server  *http.Server
+}
+
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+	// ContextWith attaches per-request fields to ctx for the *Context methods.
+	ContextWith(ctx context.Context, args ...interface{}) context.Context
+}
+
+// Metrics records request statistics and exposes them at /metrics.
+type Metrics interface {
+	ObserveRequest(route string, status int, latency time.Duration)
+	ObserveRateLimited(route, class string)
+	Handler() http.Handler
+}
+
+// Limiter limits the rate of requests of every user, see package ratelimit.
+type Limiter interface {
+	Allow(userID string, class ratelimit.Class) (bool, time.Duration)
+}
+
+type Application interface {
+	CreateEvent(ctx context.Context, userID string, e storage.Event) (storage.Event, error)
+	UpdateEvent(ctx context.Context, userID, id string, e storage.Event) (storage.Event, error)
+	DeleteEvent(ctx context.Context, userID, id string) error
+	GetEvent(ctx context.Context, userID, id string) (storage.Event, error)
+	ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error)
+	ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error)
+	ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error)
+	UpdateOccurrence(ctx context.Context, userID, id string, start time.Time, e storage.Event) (storage.Event, error)
+	DeleteOccurrence(ctx context.Context, userID, id string, start time.Time, version int64) error
+	ExportEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error)
+	ImportEvents(ctx context.Context, userID string, entries []ical.Entry) ([]app.ImportResult, error)
+	ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error)
+	FreeBusy(ctx context.Context, userID string, q app.FreeBusyQuery) (app.FreeBusy, error)
+	RespondToEvent(ctx context.Context, userID, id string, status storage.RSVPStatus) (storage.Event, error)
+	Sync(ctx context.Context, userID, token string) (app.SyncResult, error)
+	SearchEvents(ctx context.Context, userID string, q app.SearchQuery) (app.SearchResult, error)
 }
 
-type Logger interface { // TODO
+// NewServer returns a server of the app API. Probes serve GET /healthz, /readyz and /version,
+// see package health.
+func NewServer(
+	logger Logger, app Application, metrics Metrics, limiter Limiter, probes http.Handler, addr string,
+) *Server {
+	s := &Server{logger: logger, app: app, metrics: metrics, limiter: limiter, probes: probes}
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           loggingMiddleware(logger, metricsMiddleware(metrics, s.routes())),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
 }
 
-type Application interface { // TODO
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, handler := range s.handlers() {
+		mux.Handle(pattern, s.rateLimitMiddleware(handler))
+	}
+	return mux
 }
 
-func NewServer(logger Logger, app Application) *Server {
-	return &Server{}
+// handlers returns handlers by ServeMux patterns, every pattern is an operation of api/openapi.json.
+func (s *Server) handlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		"POST /events":                            http.HandlerFunc(s.createEvent),
+		"GET /events/{id}":                        http.HandlerFunc(s.getEvent),
+		"PUT /events/{id}":                        http.HandlerFunc(s.updateEvent),
+		"DELETE /events/{id}":                     http.HandlerFunc(s.deleteEvent),
+		"PUT /events/{id}/rsvp":                   http.HandlerFunc(s.respondToEvent),
+		"PUT /events/{id}/occurrences/{start}":    http.HandlerFunc(s.updateOccurrence),
+		"DELETE /events/{id}/occurrences/{start}": http.HandlerFunc(s.deleteOccurrence),
+		"GET /events/day":                         s.listEvents(s.app.ListDayEvents),
+		"GET /events/week":                        s.listEvents(s.app.ListWeekEvents),
+		"GET /events/month":                       s.listEvents(s.app.ListMonthEvents),
+		"GET /events/export":                      http.HandlerFunc(s.exportEvents),
+		"POST /events/import":                     http.HandlerFunc(s.importEvents),
+		"GET /events/freebusy":                    http.HandlerFunc(s.freeBusy),
+		"GET /events/sync":                        http.HandlerFunc(s.sync),
+		"GET /events/search":                      http.HandlerFunc(s.searchEvents),
+		"GET /metrics":                            s.metrics.Handler(),
+		"GET /healthz":                            s.probes,
+		"GET /readyz":                             s.probes,
+		"GET /version":                            s.probes,
+		"GET /openapi.json":                       http.HandlerFunc(s.openAPI),
+	}
 }
 
+// Handler returns the handler of all requests to the server, e.g. to serve it with httptest.
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Start serves requests until Stop is called.
 func (s *Server) Start(ctx context.Context) error {
-	// TODO
-	<-ctx.Done()
+	s.logger.Info("http server is listening", "addr", s.server.Addr)
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
 	return nil
 }
 
+// Stop gracefully shuts the server down waiting for active requests until ctx is done.
 func (s *Server) Stop(ctx context.Context) error {
-	// TODO
-	return nil
+	return s.server.Shutdown(ctx)
 }
-
-// TODO
@@ -0,0 +1,630 @@
+package internalhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{})                         {}
+func (nopLogger) Info(string, ...interface{})                          {}
+func (nopLogger) Warn(string, ...interface{})                          {}
+func (nopLogger) Error(string, ...interface{})                         {}
+func (nopLogger) InfoContext(context.Context, string, ...interface{})  {}
+func (nopLogger) ErrorContext(context.Context, string, ...interface{}) {}
+
+func (nopLogger) ContextWith(ctx context.Context, _ ...interface{}) context.Context {
+	return ctx
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newLimitedTestServer(t, ratelimit.New(nil))
+}
+
+func newLimitedTestServer(t *testing.T, limiter Limiter) *httptest.Server {
+	t.Helper()
+	return newAppTestServer(t, app.New(nopLogger{}, memorystorage.New()), limiter)
+}
+
+func newAppTestServer(t *testing.T, calendar Application, limiter Limiter) *httptest.Server {
+	t.Helper()
+	probes := health.Handler(health.NewChecker(nil), health.BuildInfo{Release: "test"})
+	s := NewServer(nopLogger{}, calendar, metrics.NewHTTP(prometheus.NewRegistry()), limiter, probes, "")
+	ts := httptest.NewServer(s.server.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// conflictsFailure is an application failing to list conflicts of saved events.
+type conflictsFailure struct {
+	*app.App
+}
+
+func (conflictsFailure) ListConflicts(context.Context, storage.Event) ([]storage.Event, error) {
+	return nil, errors.New("storage is down")
+}
+
+func doRequest(t *testing.T, ts *httptest.Server, method, path, userID, body string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), method, ts.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	if userID != "" {
+		req.Header.Set(UserIDHeader, userID)
+	}
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, respBody
+}
+
+func decode[T any](t *testing.T, body []byte) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(body, &v), string(body))
+	return v
+}
+
+const eventJSON = `{
+	"title": "meeting",
+	"start_at": "2024-03-04T10:00:00Z",
+	"end_at": "2024-03-04T11:00:00Z",
+	"description": "weekly sync",
+	"notify_before": "15m"
+}`
+
+func TestServer(t *testing.T) {
+	t.Run("event lifecycle", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", eventJSON)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		created := decode[eventResponse](t, body)
+		require.NotEmpty(t, created.ID)
+		require.Equal(t, "u1", created.UserID)
+		require.Equal(t, "15m0s", decode[map[string]interface{}](t, body)["notify_before"])
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/"+created.ID, "u1", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, created, decode[eventResponse](t, body))
+
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+created.ID, "u1",
+			strings.Replace(eventJSON, "meeting", "retro", 1))
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Equal(t, "retro", decode[eventResponse](t, body).Title)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/day?date=2024-03-04", "u1", "")
+		require.Equal(t, http.StatusOK, status)
+		events := decode[eventsResponse](t, body).Events
+		require.Len(t, events, 1)
+		require.Equal(t, "retro", events[0].Title)
+
+		status, _ = doRequest(t, ts, http.MethodDelete, "/events/"+created.ID, "u1", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/"+created.ID, "u1", "")
+		require.Equal(t, http.StatusNotFound, status)
+		require.Equal(t, "not_found", decode[errorResponse](t, body).Error.Code)
+	})
+
+	t.Run("list week and month", func(t *testing.T) {
+		ts := newTestServer(t)
+		for _, day := range []string{"04", "10", "11", "31"} {
+			ev := strings.ReplaceAll(eventJSON, "2024-03-04", "2024-03-"+day)
+			status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+			require.Equal(t, http.StatusCreated, status, string(body))
+		}
+
+		status, body := doRequest(t, ts, http.MethodGet, "/events/week?date=2024-03-04", "u1", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, decode[eventsResponse](t, body).Events, 2)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/month?date=2024-03-01", "u1", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Len(t, decode[eventsResponse](t, body).Events, 4)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/month?date=2024-03-01", "u2", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"events":[]}`, string(body))
+	})
+
+	t.Run("recurring event", func(t *testing.T) {
+		ts := newTestServer(t)
+		ev := strings.Replace(eventJSON, `"notify_before"`, `"rrule": "FREQ=DAILY;COUNT=5", "notify_before"`, 1)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		series := decode[eventResponse](t, body)
+		require.Equal(t, "FREQ=DAILY;COUNT=5", series.RRule)
+
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+series.ID+"/occurrences/2024-03-05T10:00:00Z", "u1",
+			`{"title": "moved", "start_at": "2024-03-05T14:00:00Z", "end_at": "2024-03-05T15:00:00Z"}`)
+		require.Equal(t, http.StatusOK, status, string(body))
+		moved := decode[eventResponse](t, body)
+		require.Equal(t, series.ID, moved.SeriesID)
+		require.NotNil(t, moved.RecurrenceID)
+		require.Equal(t, "2024-03-05T10:00:00Z", moved.RecurrenceID.Format(time.RFC3339))
+
+		status, _ = doRequest(t, ts, http.MethodDelete, "/events/"+series.ID+"/occurrences/2024-03-06T10:00:00Z", "u1", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/week?date=2024-03-04", "u1", "")
+		require.Equal(t, http.StatusOK, status)
+		events := decode[eventsResponse](t, body).Events
+		require.Len(t, events, 4)
+		require.Equal(t, "moved", events[1].Title)
+
+		status, body = doRequest(t, ts, http.MethodDelete, "/events/"+series.ID+"/occurrences/2024-03-06T10:00:00Z", "u1", "")
+		require.Equal(t, http.StatusNotFound, status, string(body))
+		status, body = doRequest(t, ts, http.MethodDelete, "/events/"+series.ID+"/occurrences/tomorrow", "u1", "")
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+	})
+
+	t.Run("time zones", func(t *testing.T) {
+		ts := newTestServer(t)
+		for _, ev := range []string{
+			`{"title": "early", "start_at": "2024-03-31T00:30:00+01:00", "end_at": "2024-03-31T01:00:00+01:00",
+				"time_zone": "Europe/Berlin"}`,
+			`{"title": "daily", "start_at": "2024-03-30T09:00:00+01:00", "end_at": "2024-03-30T10:00:00+01:00",
+				"time_zone": "Europe/Berlin", "rrule": "FREQ=DAILY;COUNT=3"}`,
+		} {
+			status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+			require.Equal(t, http.StatusCreated, status, string(body))
+		}
+
+		status, body := doRequest(t, ts, http.MethodGet, "/events/day?date=2024-03-31&tz=Europe/Berlin", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		events := decode[eventsResponse](t, body).Events
+		require.Len(t, events, 2)
+		require.Equal(t, "early", events[0].Title)
+		require.Equal(t, "Europe/Berlin", events[1].TimeZone)
+		require.Equal(t, "2024-03-31T09:00:00+02:00", events[1].StartAt.Format(time.RFC3339))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/day?date=2024-03-31", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		events = decode[eventsResponse](t, body).Events
+		require.Len(t, events, 1)
+		require.Equal(t, "daily", events[0].Title)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/day?date=2024-03-31&tz=Mars/Olympus", "u1", "")
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		status, body = doRequest(t, ts, http.MethodPost, "/events", "u1",
+			strings.Replace(eventJSON, `"notify_before"`, `"time_zone": "Mars/Olympus", "notify_before"`, 1))
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		require.Equal(t, "invalid_event", decode[errorResponse](t, body).Error.Code)
+	})
+
+	t.Run("overlap and free/busy", func(t *testing.T) {
+		ts := newTestServer(t)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", eventJSON)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		first := decode[eventResponse](t, body)
+		require.Nil(t, decode[map[string]interface{}](t, body)["conflicts"])
+
+		overlapping := strings.ReplaceAll(eventJSON, "T10:00:00Z", "T10:30:00Z")
+		status, body = doRequest(t, ts, http.MethodPost, "/events", "u1", overlapping)
+		require.Equal(t, http.StatusConflict, status, string(body))
+		status, body = doRequest(t, ts, http.MethodPost, "/events?allow_overlap=true", "u1", overlapping)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		second := decode[eventResponse](t, body)
+		require.Equal(t, []string{first.ID}, second.Conflicts)
+
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+second.ID+"?allow_overlap=1", "u1",
+			`{"title": "moved", "start_at": "2024-03-04T11:00:00Z", "end_at": "2024-03-04T12:00:00Z"}`)
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Empty(t, decode[eventResponse](t, body).Conflicts)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/freebusy?from=2024-03-04&to=2024-03-04&tz=Europe/Berlin"+
+			"&slots=2&duration=90m&work_start=10:00&work_end=24:00", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.JSONEq(t, `{
+			"busy": [{"start": "2024-03-04T11:00:00+01:00", "end": "2024-03-04T13:00:00+01:00"}],
+			"free": [
+				{"start": "2024-03-04T13:00:00+01:00", "end": "2024-03-04T14:30:00+01:00"},
+				{"start": "2024-03-04T14:30:00+01:00", "end": "2024-03-04T16:00:00+01:00"}
+			]
+		}`, string(body))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/freebusy?from=2024-03-09&to=2024-03-10&slots=1&duration=1h",
+			"u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.JSONEq(t, `{"busy": [], "free": []}`, string(body))
+		status, body = doRequest(t, ts, http.MethodGet,
+			"/events/freebusy?from=2024-03-09&to=2024-03-10&slots=1&duration=1h&work_days=sa,su", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Len(t, decode[freeBusyResponse](t, body).Free, 1)
+	})
+
+	t.Run("saved event is returned when listing conflicts fails", func(t *testing.T) {
+		ts := newAppTestServer(t, conflictsFailure{app.New(nopLogger{}, memorystorage.New())}, ratelimit.New(nil))
+		status, body := doRequest(t, ts, http.MethodPost, "/events?allow_overlap=true", "u1", eventJSON)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		created := decode[eventResponse](t, body)
+		require.Empty(t, created.Conflicts)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/"+created.ID, "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+	})
+
+	t.Run("attendees and rsvp", func(t *testing.T) {
+		ts := newTestServer(t)
+		ev := strings.Replace(eventJSON, `"notify_before"`, `"attendees": ["u2", "u3"], "notify_before"`, 1)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		created := decode[eventResponse](t, body)
+		require.Equal(t, []attendeeResponse{{"u2", "needs-action"}, {"u3", "needs-action"}}, created.Attendees)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/day?date=2024-03-04", "u2", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Len(t, decode[eventsResponse](t, body).Events, 1)
+
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+created.ID+"/rsvp", "u2", `{"status": "accepted"}`)
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Equal(t, "accepted", string(decode[eventResponse](t, body).Attendees[0].Status))
+
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+created.ID, "u1", ev)
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Equal(t, "accepted", string(decode[eventResponse](t, body).Attendees[0].Status))
+
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+created.ID, "u2", ev)
+		require.Equal(t, http.StatusForbidden, status, string(body))
+		require.Equal(t, "forbidden", decode[errorResponse](t, body).Error.Code)
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+created.ID+"/rsvp", "u4", `{"status": "accepted"}`)
+		require.Equal(t, http.StatusNotFound, status, string(body))
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+created.ID+"/rsvp", "u3", `{"status": "maybe"}`)
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+	})
+
+	t.Run("reminders", func(t *testing.T) {
+		ts := newTestServer(t)
+		ev := strings.Replace(eventJSON, `"notify_before"`,
+			`"reminders": [{"before": "10m", "channel": "email"}, {"before": "1h", "channel": "webhook"}], "notify_before"`, 1)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		require.Equal(t, []reminderDTO{
+			{Before: duration(time.Hour), Channel: "webhook"},
+			{Before: duration(10 * time.Minute), Channel: "email"},
+		}, decode[eventResponse](t, body).Reminders)
+
+		ev = strings.Replace(eventJSON, `"notify_before"`,
+			`"reminders": [{"before": "10m", "channel": "sms"}], "notify_before"`, 1)
+		status, body = doRequest(t, ts, http.MethodPost, "/events?allow_overlap=true", "u1", ev)
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		require.Equal(t, "invalid_event", decode[errorResponse](t, body).Error.Code)
+	})
+
+	t.Run("versions and etags", func(t *testing.T) {
+		ts := newTestServer(t)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", eventJSON)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		created := decode[eventResponse](t, body)
+		require.Equal(t, int64(1), created.Version)
+
+		update := func(ifMatch string) (*http.Response, []byte) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, ts.URL+"/events/"+created.ID,
+				strings.NewReader(eventJSON))
+			require.NoError(t, err)
+			req.Header.Set(UserIDHeader, "u1")
+			if ifMatch != "" {
+				req.Header.Set("If-Match", ifMatch)
+			}
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return resp, body
+		}
+
+		resp, body := update(`"1"`)
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		require.Equal(t, `"2"`, resp.Header.Get("ETag"))
+		require.Equal(t, int64(2), decode[eventResponse](t, body).Version)
+
+		for _, stale := range []string{`"1"`, `W/"2"`, `"two"`} {
+			resp, body = update(stale)
+			require.Equal(t, http.StatusPreconditionFailed, resp.StatusCode, stale)
+			require.Equal(t, "version_mismatch", decode[errorResponse](t, body).Error.Code)
+		}
+		for _, malformed := range []string{"2", `"2`, `"1", "2"`, `W/`} {
+			resp, body = update(malformed)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode, malformed)
+			require.Equal(t, "bad_request", decode[errorResponse](t, body).Error.Code)
+		}
+
+		resp, body = update("*")
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		resp, body = update("")
+		require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+		require.Equal(t, `"4"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("occurrence versions", func(t *testing.T) {
+		ts := newTestServer(t)
+		ev := strings.Replace(eventJSON, `"notify_before"`, `"rrule": "FREQ=DAILY;COUNT=5", "notify_before"`, 1)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		series := decode[eventResponse](t, body)
+
+		change := func(method, start, ifMatch string) (int, []byte) {
+			req, err := http.NewRequestWithContext(context.Background(), method,
+				ts.URL+"/events/"+series.ID+"/occurrences/"+start,
+				strings.NewReader(`{"title": "moved", "start_at": "`+start+`", "end_at": "2024-03-05T15:00:00Z"}`))
+			require.NoError(t, err)
+			req.Header.Set(UserIDHeader, "u1")
+			req.Header.Set("If-Match", ifMatch)
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return resp.StatusCode, body
+		}
+
+		status, body = change(http.MethodPut, "2024-03-05T10:00:00Z", `"1"`)
+		require.Equal(t, http.StatusOK, status, string(body))
+		status, body = change(http.MethodPut, "2024-03-05T10:00:00Z", `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, status, string(body))
+		require.Equal(t, "version_mismatch", decode[errorResponse](t, body).Error.Code)
+		status, body = change(http.MethodDelete, "2024-03-06T10:00:00Z", `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, status, string(body))
+		require.Equal(t, "version_mismatch", decode[errorResponse](t, body).Error.Code)
+		status, body = change(http.MethodDelete, "2024-03-06T10:00:00Z", "1")
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		status, body = change(http.MethodDelete, "2024-03-06T10:00:00Z", `"2"`)
+		require.Equal(t, http.StatusNoContent, status, string(body))
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		ts := newTestServer(t)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", eventJSON)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		first := decode[eventResponse](t, body)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/sync", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		full := decode[syncResponse](t, body)
+		require.Equal(t, []eventResponse{first}, full.Events)
+		require.NotEmpty(t, full.SyncToken)
+
+		status, body = doRequest(t, ts, http.MethodPost, "/events", "u1",
+			strings.ReplaceAll(eventJSON, "2024-03-04", "2024-03-05"))
+		require.Equal(t, http.StatusCreated, status, string(body))
+		second := decode[eventResponse](t, body)
+		status, _ = doRequest(t, ts, http.MethodDelete, "/events/"+first.ID, "u1", "")
+		require.Equal(t, http.StatusNoContent, status)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/sync?sync_token="+full.SyncToken, "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		changes := decode[syncResponse](t, body)
+		require.Equal(t, []eventResponse{second}, changes.Events)
+		require.Equal(t, []string{first.ID}, changes.Deleted)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/sync?sync_token="+changes.SyncToken, "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.JSONEq(t, `{"events": [], "deleted": [], "sync_token": "`+changes.SyncToken+`"}`, string(body))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/sync?sync_token=garbage", "u1", "")
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		require.Equal(t, "invalid_sync_token", decode[errorResponse](t, body).Error.Code)
+		// A token from the future, e.g. issued before a restart of the memory storage.
+		status, body = doRequest(t, ts, http.MethodGet, "/events/sync?sync_token=djE6MTAw", "u1", "")
+		require.Equal(t, http.StatusGone, status, string(body))
+		require.Equal(t, "sync_token_expired", decode[errorResponse](t, body).Error.Code)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		ts := newTestServer(t)
+		var created []eventResponse
+		for _, day := range []string{"04", "11", "18"} {
+			status, body := doRequest(t, ts, http.MethodPost, "/events", "u1",
+				strings.ReplaceAll(eventJSON, "2024-03-04", "2024-03-"+day))
+			require.Equal(t, http.StatusCreated, status, string(body))
+			created = append(created, decode[eventResponse](t, body))
+		}
+
+		status, body := doRequest(t, ts, http.MethodGet, "/events/search?q=Weekly+SYNC&limit=2", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		page := decode[searchResponse](t, body)
+		require.Equal(t, created[:2], page.Events)
+		status, body = doRequest(t, ts, http.MethodGet, "/events/search?q=weekly+sync&limit=2&cursor="+page.NextCursor,
+			"u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Equal(t, searchResponse{Events: created[2:]}, decode[searchResponse](t, body))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/search?q=meeting&from=2024-03-10&to=2024-03-11", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Equal(t, created[1:2], decode[searchResponse](t, body).Events)
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/search?q=meeting", "u2", "")
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.JSONEq(t, `{"events": []}`, string(body))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/events/search?q=meeting&from=2024-03-10", "u1", "")
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		status, body = doRequest(t, ts, http.MethodGet, "/events/search?q=meeting&cursor=garbage", "u1", "")
+		require.Equal(t, http.StatusBadRequest, status, string(body))
+		require.Equal(t, "invalid_query", decode[errorResponse](t, body).Error.Code)
+	})
+
+	t.Run("ical export and import", func(t *testing.T) {
+		ts := newTestServer(t)
+		ev := strings.Replace(eventJSON, `"notify_before"`, `"rrule": "FREQ=DAILY;COUNT=5", `+
+			`"reminders": [{"before": "1h", "channel": "email"}, {"before": "10m", "channel": "log"}], "notify_before"`, 1)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", ev)
+		require.Equal(t, http.StatusCreated, status, string(body))
+		series := decode[eventResponse](t, body)
+		require.Len(t, series.Reminders, 2)
+		status, _ = doRequest(t, ts, http.MethodDelete, "/events/"+series.ID+"/occurrences/2024-03-06T10:00:00Z", "u1", "")
+		require.Equal(t, http.StatusNoContent, status)
+		status, body = doRequest(t, ts, http.MethodPut, "/events/"+series.ID+"/occurrences/2024-03-05T10:00:00Z", "u1",
+			`{"title": "moved", "start_at": "2024-03-05T14:00:00Z", "end_at": "2024-03-05T15:00:00Z"}`)
+		require.Equal(t, http.StatusOK, status, string(body))
+
+		status, exported := doRequest(t, ts, http.MethodGet, "/events/export?from=2024-03-01&to=2024-03-31", "u1", "")
+		require.Equal(t, http.StatusOK, status, string(exported))
+		require.Contains(t, string(exported), "UID:"+series.ID+"\r\n")
+		require.Contains(t, string(exported), "RECURRENCE-ID:20240305T100000Z\r\n")
+
+		listWeek := func(userID string) []eventResponse {
+			status, body := doRequest(t, ts, http.MethodGet, "/events/week?date=2024-03-04", userID, "")
+			require.Equal(t, http.StatusOK, status)
+			return decode[eventsResponse](t, body).Events
+		}
+		before := listWeek("u1")
+		require.Len(t, before, 4)
+
+		for _, tc := range []struct {
+			userID             string
+			created, unchanged int
+		}{{"u1", 0, 2}, {"u2", 2, 0}, {"u2", 0, 2}} {
+			status, body = doRequest(t, ts, http.MethodPost, "/events/import", tc.userID, string(exported))
+			require.Equal(t, http.StatusOK, status, string(body))
+			resp := decode[importResponse](t, body)
+			require.Equal(t, tc.created, resp.Created, string(body))
+			require.Equal(t, tc.unchanged, resp.Unchanged, string(body))
+			require.Zero(t, resp.Updated, string(body))
+			require.Zero(t, resp.Failed, string(body))
+		}
+		require.Equal(t, before, listWeek("u1"))
+		status, body = doRequest(t, ts, http.MethodPost, "/events/import", "u1",
+			strings.Replace(string(exported), "SUMMARY:moved", "SUMMARY:moved again", 1))
+		require.Equal(t, http.StatusOK, status, string(body))
+		resp := decode[importResponse](t, body)
+		require.Equal(t, 1, resp.Updated, string(body))
+		require.Equal(t, 1, resp.Unchanged, string(body))
+		require.Equal(t, "moved again", listWeek("u1")[1].Title)
+		imported := listWeek("u2")
+		require.Len(t, imported, 4)
+		for i := range imported {
+			require.Equal(t, before[i].Title, imported[i].Title)
+			require.Equal(t, before[i].StartAt, imported[i].StartAt)
+			require.Equal(t, before[i].Reminders, imported[i].Reminders)
+			require.Equal(t, series.ID, imported[i].UID)
+		}
+
+		// Files of tools that drop alarms keep reminders of imported events.
+		withoutAlarms := regexp.MustCompile(`BEGIN:VALARM\r\n(?:.*\r\n)*?END:VALARM\r\n`).
+			ReplaceAllString(string(exported), "")
+		require.NotContains(t, withoutAlarms, "TRIGGER")
+		status, body = doRequest(t, ts, http.MethodPost, "/events/import", "u2", withoutAlarms)
+		require.Equal(t, http.StatusOK, status, string(body))
+		require.Equal(t, imported[0].Reminders, listWeek("u2")[0].Reminders)
+
+		status, body = doRequest(t, ts, http.MethodPost, "/events/import", "u3", strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"BEGIN:VEVENT",
+			"UID:orphan",
+			"RECURRENCE-ID:20240305T100000Z",
+			"DTSTART:20240305T140000Z",
+			"DTEND:20240305T150000Z",
+			"SUMMARY:orphan",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:no-title",
+			"DTSTART:20240305T140000Z",
+			"DTEND:20240305T150000Z",
+			"END:VEVENT",
+			"BEGIN:VEVENT",
+			"UID:ok",
+			"DTSTART:20240305T140000Z",
+			"DTEND:20240305T150000Z",
+			"SUMMARY:ok",
+			"END:VEVENT",
+			"END:VCALENDAR",
+		}, "\r\n"))
+		require.Equal(t, http.StatusOK, status, string(body))
+		resp = decode[importResponse](t, body)
+		require.Equal(t, 1, resp.Created)
+		require.Equal(t, 2, resp.Failed)
+		require.Equal(t, app.ImportFailed, resp.Entries[0].Status)
+		require.Equal(t, 2, resp.Entries[0].Line)
+		require.Contains(t, resp.Entries[1].Error, "title is empty")
+		require.Equal(t, app.ImportCreated, resp.Entries[2].Status)
+	})
+
+	t.Run("probes", func(t *testing.T) {
+		ts := newTestServer(t)
+
+		status, body := doRequest(t, ts, http.MethodGet, "/healthz", "", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"status":"ok"}`, string(body))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/readyz", "", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"status":"ok"}`, string(body))
+
+		status, body = doRequest(t, ts, http.MethodGet, "/version", "", "")
+		require.Equal(t, http.StatusOK, status)
+		require.JSONEq(t, `{"Release":"test","BuildDate":"","GitHash":""}`, string(body))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ts := newTestServer(t)
+		status, body := doRequest(t, ts, http.MethodPost, "/events", "u1", eventJSON)
+		require.Equal(t, http.StatusCreated, status)
+		id := decode[eventResponse](t, body).ID
+		freeBusy := "/events/freebusy?from=2024-03-04&to=2024-03-04"
+
+		for _, tc := range []struct {
+			name, method, path, userID, body string
+			status                           int
+			code                             string
+		}{
+			{"busy", http.MethodPost, "/events", "u1", eventJSON, http.StatusConflict, "date_busy"},
+			{"invalid", http.MethodPost, "/events", "u1", `{"title":""}`, http.StatusBadRequest, "invalid_event"},
+			{"bad json", http.MethodPost, "/events", "u1", `{`, http.StatusBadRequest, "bad_request"},
+			{"bad duration", http.MethodPost, "/events", "u1", `{"notify_before":"soon"}`, http.StatusBadRequest, "bad_request"},
+			{"no user", http.MethodPost, "/events", "", eventJSON, http.StatusBadRequest, "no_user"},
+			{"other user", http.MethodGet, "/events/" + id, "u2", "", http.StatusNotFound, "not_found"},
+			{"other user update", http.MethodPut, "/events/" + id, "u2", eventJSON, http.StatusNotFound, "not_found"},
+			{"other user delete", http.MethodDelete, "/events/" + id, "u2", "", http.StatusNotFound, "not_found"},
+			{"no date", http.MethodGet, "/events/day", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"bad date", http.MethodGet, "/events/day?date=04.03.2024", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"no range", http.MethodGet, "/events/export?from=2024-03-01", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"bad range", http.MethodGet, "/events/export?from=2024-03-31&to=2024-03-01", "u1", "",
+				http.StatusBadRequest, "bad_request"},
+			{"bad calendar", http.MethodPost, "/events/import", "u1", "BEGIN:VEVENT", http.StatusBadRequest, "bad_request"},
+			{"import no user", http.MethodPost, "/events/import", "", "BEGIN:VCALENDAR\nEND:VCALENDAR",
+				http.StatusBadRequest, "no_user"},
+			{"bad overlap", http.MethodPost, "/events?allow_overlap=maybe", "u1", eventJSON,
+				http.StatusBadRequest, "bad_request"},
+			{"free/busy no range", http.MethodGet, "/events/freebusy", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"bad work hours", http.MethodGet, freeBusy + "&work_end=6pm", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"bad work days", http.MethodGet, freeBusy + "&work_days=MO,XX", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"bad slots", http.MethodGet, freeBusy + "&slots=many", "u1", "", http.StatusBadRequest, "bad_request"},
+			{"inverted work hours", http.MethodGet, freeBusy + "&work_start=18:00&work_end=09:00", "u1", "",
+				http.StatusBadRequest, "invalid_query"},
+			{"no slot duration", http.MethodGet, freeBusy + "&slots=3", "u1", "", http.StatusBadRequest, "invalid_query"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				status, body := doRequest(t, ts, tc.method, tc.path, tc.userID, tc.body)
+				require.Equal(t, tc.status, status, string(body))
+				errResp := decode[errorResponse](t, body)
+				require.Equal(t, tc.code, errResp.Error.Code)
+				require.NotEmpty(t, errResp.Error.Message)
+			})
+		}
+	})
+}
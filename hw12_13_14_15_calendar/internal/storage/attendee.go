@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RSVPStatus is the response of an attendee to the invitation to an event.
+type RSVPStatus string
+
+const (
+	RSVPNeedsAction RSVPStatus = "needs-action"
+	RSVPAccepted    RSVPStatus = "accepted"
+	RSVPDeclined    RSVPStatus = "declined"
+	RSVPTentative   RSVPStatus = "tentative"
+)
+
+// Valid reports whether the status is one of the known ones.
+func (s RSVPStatus) Valid() bool {
+	switch s {
+	case RSVPNeedsAction, RSVPAccepted, RSVPDeclined, RSVPTentative:
+		return true
+	}
+	return false
+}
+
+// Attendee is a user invited to an event owned by another user.
+type Attendee struct {
+	UserID string
+	Status RSVPStatus
+}
+
+func (e Event) validateAttendees() error {
+	seen := make(map[string]bool, len(e.Attendees))
+	for _, a := range e.Attendees {
+		switch {
+		case a.UserID == "":
+			return fmt.Errorf("%w: attendee user id is empty", ErrInvalidEvent)
+		case a.UserID == e.UserID:
+			return fmt.Errorf("%w: owner cannot attend own event", ErrInvalidEvent)
+		case seen[a.UserID]:
+			return fmt.Errorf("%w: attendee %s is listed twice", ErrInvalidEvent, a.UserID)
+		case a.Status != "" && !a.Status.Valid():
+			return fmt.Errorf("%w: unknown RSVP status %q", ErrInvalidEvent, a.Status)
+		}
+		seen[a.UserID] = true
+	}
+	return nil
+}
+
+// Involves reports whether the user owns or attends the event.
+func (e Event) Involves(userID string) bool {
+	_, ok := e.Attendee(userID)
+	return e.UserID == userID || ok
+}
+
+// Attendee returns the attendee of the event with the given user ID.
+func (e Event) Attendee(userID string) (Attendee, bool) {
+	i := slices.IndexFunc(e.Attendees, func(a Attendee) bool { return a.UserID == userID })
+	if i < 0 {
+		return Attendee{}, false
+	}
+	return e.Attendees[i], true
+}
+
+// Respond returns the event with the status of the attendee changed; ok is false
+// if the user does not attend the event.
+func (e Event) Respond(userID string, status RSVPStatus) (_ Event, ok bool) {
+	i := slices.IndexFunc(e.Attendees, func(a Attendee) bool { return a.UserID == userID })
+	if i < 0 {
+		return e, false
+	}
+	e.Attendees = slices.Clone(e.Attendees)
+	e.Attendees[i].Status = status
+	return e, true
+}
+
+// Invite returns the event with statuses of attendees taken from previous, the attendees of
+// the stored version of the event, so that only attendees change them; new attendees need to respond.
+func (e Event) Invite(previous []Attendee) Event {
+	attendees := make([]Attendee, 0, len(e.Attendees))
+	for _, a := range e.Attendees {
+		a.Status = RSVPNeedsAction
+		if i := slices.IndexFunc(previous, func(p Attendee) bool { return p.UserID == a.UserID }); i >= 0 {
+			a.Status = previous[i].Status
+		}
+		attendees = append(attendees, a)
+	}
+	e.Attendees = attendees
+	return e
+}
+
+// Recipients returns the users notified of the event: the owner and accepted attendees.
+func (e Event) Recipients() []string {
+	recipients := []string{e.UserID}
+	for _, a := range e.Attendees {
+		if a.Status == RSVPAccepted {
+			recipients = append(recipients, a.UserID)
+		}
+	}
+	return recipients
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+)
+
+// Changes are the events a user owns or attends changed after a position in the change log.
+// Storages log a change for every user who owned or attended the event before or after it.
+type Changes struct {
+	// Events are the current versions of created and updated events, recurring ones unexpanded.
+	Events []Event
+	// Deleted are IDs of deleted events and of events the user no longer attends.
+	Deleted []string
+	// Seq is the position of the last change to continue from.
+	Seq int64
+}
+
+// NewChanges sorts out events changed for the user: changed are IDs of the events,
+// current are the stored ones among them.
+func NewChanges(userID string, changed []string, current []Event, seq int64) Changes {
+	changes := Changes{Events: make([]Event, 0, len(current)), Deleted: make([]string, 0), Seq: seq}
+	for _, e := range current {
+		if e.Involves(userID) {
+			changes.Events = append(changes.Events, e)
+		}
+	}
+	SortEvents(changes.Events)
+	for _, id := range changed {
+		if !slices.ContainsFunc(changes.Events, func(e Event) bool { return e.ID == id }) {
+			changes.Deleted = append(changes.Deleted, id)
+		}
+	}
+	sort.Strings(changes.Deleted)
+	return changes
+}
+
+// Participants returns sorted IDs of the owners and attendees of the events.
+func Participants(events ...Event) []string {
+	var users []string
+	for _, e := range events {
+		if e.UserID != "" {
+			users = append(users, e.UserID)
+		}
+		for _, a := range e.Attendees {
+			users = append(users, a.UserID)
+		}
+	}
+	sort.Strings(users)
+	return slices.Compact(users)
+}
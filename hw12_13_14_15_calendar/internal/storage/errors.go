@@ -0,0 +1,14 @@
+package storage
+
+import "errors"
+
+var (
+	ErrNotFound     = errors.New("event not found")
+	ErrDateBusy     = errors.New("event time is already taken by another event")
+	ErrInvalidEvent = errors.New("invalid event")
+	ErrUIDTaken     = errors.New("event uid is already taken by another event")
+	// ErrVersionMismatch means the event was changed since the version the update is based on.
+	ErrVersionMismatch = errors.New("event version does not match the stored one")
+	// ErrChangesCompacted means the change log no longer has changes after the requested position.
+	ErrChangesCompacted = errors.New("changes are compacted")
+)
@@ -1,7 +1,183 @@
 package storage
 
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/rrule"
+)
+
+// Event is a calendar event owned by a single user.
 type Event struct {
-	ID    string
-	Title string
-	// TODO
+	ID           string
+	Title        string
+	StartAt      time.Time
+	EndAt        time.Time
+	Description  string
+	UserID       string
+	NotifyBefore time.Duration
+	// Reminders are sent through their channels in addition to the reminder of NotifyBefore.
+	Reminders []Reminder
+	// TimeZone is the IANA name of the zone the event is planned in. Times are kept in UTC,
+	// while occurrences of a recurring event keep the wall clock time of StartAt in this zone.
+	TimeZone string
+	// RRule makes the event recurring, see package rrule for the supported subset of RFC 5545.
+	RRule string
+	// ExDates are start times of deleted or edited occurrences of a recurring event.
+	ExDates []time.Time
+	// SeriesID and RecurrenceID link an edited occurrence to its recurring event
+	// and to the start time of the occurrence it replaces.
+	SeriesID     string
+	RecurrenceID time.Time
+	// UID identifies the event in iCalendar files: the ID unless the event was imported.
+	// Edited occurrences share the UID of their recurring event.
+	UID string
+	// Attendees are other users invited to the event, see Involves.
+	Attendees []Attendee
+	// Version counts changes of the stored event starting from 1. An update carrying
+	// a non-zero version is applied only to that version, see NextVersion.
+	Version int64
+}
+
+// NextVersion returns the version of e replacing the stored event or ErrVersionMismatch
+// when e is based on another version of it.
+func (e Event) NextVersion(stored Event) (int64, error) {
+	if e.Version != 0 && e.Version != stored.Version {
+		return 0, fmt.Errorf("%w: expected %d, stored %d", ErrVersionMismatch, e.Version, stored.Version)
+	}
+	return stored.Version + 1, nil
+}
+
+// Validate checks that the event has all required fields and a sane time span.
+func (e Event) Validate() error {
+	switch {
+	case e.Title == "":
+		return fmt.Errorf("%w: title is empty", ErrInvalidEvent)
+	case e.UserID == "":
+		return fmt.Errorf("%w: user id is empty", ErrInvalidEvent)
+	case e.StartAt.IsZero():
+		return fmt.Errorf("%w: start time is not set", ErrInvalidEvent)
+	case !e.EndAt.After(e.StartAt):
+		return fmt.Errorf("%w: end time must be after start time", ErrInvalidEvent)
+	case e.NotifyBefore < 0:
+		return fmt.Errorf("%w: notify before must not be negative", ErrInvalidEvent)
+	case e.RRule == "" && len(e.ExDates) > 0:
+		return fmt.Errorf("%w: exception dates require a recurrence rule", ErrInvalidEvent)
+	case e.RRule != "" && e.SeriesID != "":
+		return fmt.Errorf("%w: an occurrence of a recurring event cannot recur", ErrInvalidEvent)
+	}
+	if _, err := LoadLocation(e.TimeZone); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+	}
+	for _, r := range e.Reminders {
+		if err := r.validate(); err != nil {
+			return err
+		}
+	}
+	if err := e.validateAttendees(); err != nil {
+		return err
+	}
+	if e.RRule != "" {
+		if _, err := rrule.Parse(e.RRule); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidEvent, err)
+		}
+	}
+	return nil
+}
+
+// Normalize brings event times to UTC with microsecond precision and the recurrence rule
+// to canonical form, the way every storage keeps them. ExDates are sorted and deduplicated.
+// An empty time zone becomes UTC and attendees who have not responded need to.
+// Reminders are sorted from the earliest and deduplicated.
+func (e Event) Normalize() Event {
+	if e.TimeZone == "" {
+		e.TimeZone = "UTC"
+	}
+	e.StartAt = normalizeTime(e.StartAt)
+	e.EndAt = normalizeTime(e.EndAt)
+	if r, err := rrule.Parse(e.RRule); err == nil {
+		e.RRule = r.String()
+	}
+	if len(e.ExDates) > 0 {
+		exDates := make([]time.Time, 0, len(e.ExDates))
+		for _, t := range e.ExDates {
+			exDates = append(exDates, normalizeTime(t))
+		}
+		sort.Slice(exDates, func(i, j int) bool { return exDates[i].Before(exDates[j]) })
+		e.ExDates = slices.CompactFunc(exDates, time.Time.Equal)
+	} else {
+		e.ExDates = nil
+	}
+	if !e.RecurrenceID.IsZero() {
+		e.RecurrenceID = normalizeTime(e.RecurrenceID)
+	}
+	if len(e.Attendees) > 0 {
+		attendees := slices.Clone(e.Attendees)
+		for i := range attendees {
+			if attendees[i].Status == "" {
+				attendees[i].Status = RSVPNeedsAction
+			}
+		}
+		e.Attendees = attendees
+	} else {
+		e.Attendees = nil
+	}
+	e.Reminders = normalizeReminders(e.Reminders)
+	return e
+}
+
+func normalizeTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// Location returns the time zone of the event, UTC if it is unknown.
+func (e Event) Location() *time.Location {
+	loc, err := LoadLocation(e.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Overlaps reports whether the event intersects the half-open interval [from, to).
+func (e Event) Overlaps(from, to time.Time) bool {
+	return e.StartAt.Before(to) && e.EndAt.After(from)
+}
+
+// DayRange returns the bounds of the day containing date, in date's location.
+// Days of DST transitions are 23 or 25 hours long.
+func DayRange(date time.Time) (from, to time.Time) {
+	return startOfDay(date, 0, 0), startOfDay(date, 0, 1)
+}
+
+// WeekRange returns the bounds of the seven days starting at weekStart.
+func WeekRange(weekStart time.Time) (from, to time.Time) {
+	return startOfDay(weekStart, 0, 0), startOfDay(weekStart, 0, 7)
+}
+
+// MonthRange returns the bounds of the month starting at monthStart.
+func MonthRange(monthStart time.Time) (from, to time.Time) {
+	return startOfDay(monthStart, 0, 0), startOfDay(monthStart, 1, 0)
+}
+
+// DateIn returns the start of the calendar day of date in loc. Unlike time.ParseInLocation,
+// it keeps the day when its midnight is skipped by a DST transition.
+func DateIn(date time.Time, loc *time.Location) time.Time {
+	y, m, d := date.Date()
+	return startOfDay(time.Date(y, m, d, 12, 0, 0, 0, loc), 0, 0)
+}
+
+// startOfDay returns the start of the day the given number of months and days after t's day
+// in t's location: the midnight or, when it is skipped, the DST transition.
+func startOfDay(t time.Time, months, days int) time.Time {
+	y, m, d := t.Date()
+	day := time.Date(y, m+time.Month(months), d+days, 12, 0, 0, 0, t.Location())
+	y, m, d = day.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	if start.Day() != d {
+		_, start = start.ZoneBounds()
+	}
+	return start
 }
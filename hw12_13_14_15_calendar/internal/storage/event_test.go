@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := LoadLocation(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestRanges(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		zone       string
+		date       string
+		rangeFunc  func(time.Time) (time.Time, time.Time)
+		from       string
+		wantLength time.Duration
+	}{
+		{"plain day", "UTC", "2024-03-31", DayRange, "2024-03-31T00:00:00Z", 24 * time.Hour},
+		{"spring forward day", "Europe/Berlin", "2024-03-31", DayRange, "2024-03-31T00:00:00+01:00", 23 * time.Hour},
+		{"day after spring forward", "Europe/Berlin", "2024-04-01", DayRange, "2024-04-01T00:00:00+02:00", 24 * time.Hour},
+		{"fall back day", "Europe/Berlin", "2024-10-27", DayRange, "2024-10-27T00:00:00+02:00", 25 * time.Hour},
+		{"spring forward in america", "America/New_York", "2024-03-10", DayRange,
+			"2024-03-10T00:00:00-05:00", 23 * time.Hour},
+		{"skipped midnight", "America/Santiago", "2024-09-08", DayRange, "2024-09-08T01:00:00-03:00", 23 * time.Hour},
+		{"spring forward week", "Europe/Berlin", "2024-03-25", WeekRange, "2024-03-25T00:00:00+01:00", 167 * time.Hour},
+		{"fall back week", "Europe/Berlin", "2024-10-21", WeekRange, "2024-10-21T00:00:00+02:00", 169 * time.Hour},
+		{"spring forward month", "Europe/Berlin", "2024-03-01", MonthRange, "2024-03-01T00:00:00+01:00", 743 * time.Hour},
+		{"fall back month", "Europe/Berlin", "2024-10-01", MonthRange, "2024-10-01T00:00:00+02:00", 745 * time.Hour},
+		{"southern hemisphere month", "Australia/Sydney", "2024-04-01", MonthRange,
+			"2024-04-01T00:00:00+11:00", 721 * time.Hour},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			date, err := time.Parse("2006-01-02", tc.date)
+			require.NoError(t, err)
+			date = DateIn(date, mustLoad(t, tc.zone))
+			wantFrom, err := time.Parse(time.RFC3339, tc.from)
+			require.NoError(t, err)
+
+			from, to := tc.rangeFunc(date)
+			require.True(t, wantFrom.Equal(from), "from %s", from)
+			require.Equal(t, tc.wantLength, to.Sub(from))
+		})
+	}
+}
+
+func TestOccurrencesAcrossDST(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		zone  string
+		start string
+		rule  string
+		want  []string
+	}{
+		{
+			"utc keeps utc time", "UTC", "2024-03-30T10:00:00Z", "FREQ=DAILY;COUNT=3",
+			[]string{"2024-03-30T10:00:00Z", "2024-03-31T10:00:00Z", "2024-04-01T10:00:00Z"},
+		},
+		{
+			"spring forward keeps wall clock", "Europe/Berlin", "2024-03-30T09:00:00Z", "FREQ=DAILY;COUNT=3",
+			[]string{"2024-03-30T09:00:00Z", "2024-03-31T08:00:00Z", "2024-04-01T08:00:00Z"},
+		},
+		{
+			"fall back keeps wall clock", "America/New_York", "2024-10-28T14:00:00Z", "FREQ=WEEKLY;COUNT=2",
+			[]string{"2024-10-28T14:00:00Z", "2024-11-04T15:00:00Z"},
+		},
+		{
+			"weekday in the zone of the event", "Asia/Tokyo", "2024-03-03T20:00:00Z", "FREQ=WEEKLY;BYDAY=MO;COUNT=2",
+			[]string{"2024-03-03T20:00:00Z", "2024-03-10T20:00:00Z"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, err := time.Parse(time.RFC3339, tc.start)
+			require.NoError(t, err)
+			e := Event{
+				Title: "meeting", UserID: "u1", StartAt: start, EndAt: start.Add(time.Hour),
+				TimeZone: tc.zone, RRule: tc.rule,
+			}
+			require.NoError(t, e.Validate())
+
+			var got []string
+			for _, o := range e.Normalize().Occurrences(start, start.AddDate(0, 1, 0)) {
+				require.Equal(t, time.UTC, o.StartAt.Location())
+				require.Equal(t, time.Hour, o.EndAt.Sub(o.StartAt))
+				got = append(got, o.StartAt.Format(time.RFC3339))
+			}
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAncientRecurrence(t *testing.T) {
+	ancient := time.Date(1, time.January, 1, 9, 0, 0, 0, time.UTC)
+	e := Event{Title: "t", UserID: "u1", StartAt: ancient, EndAt: ancient.Add(time.Hour), RRule: "FREQ=DAILY"}
+	from, to := WeekRange(time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC))
+
+	occurrences := e.Occurrences(from, to)
+	require.Len(t, occurrences, 7)
+	require.Equal(t, time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC), occurrences[0].StartAt)
+	// Expanding every day since the year 1 would allocate for each of them.
+	require.Less(t, testing.AllocsPerRun(10, func() { e.Occurrences(from, to) }), 100.0)
+
+	e.RRule = "FREQ=DAILY;UNTIL=20240305"
+	require.Less(t, testing.AllocsPerRun(10, func() { e.LastEnd() }), 1000.0)
+	end, ok := e.LastEnd()
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC), end)
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	e := Event{Title: "meeting", UserID: "u1", StartAt: time.Now(), EndAt: time.Now().Add(time.Hour)}
+	for zone, valid := range map[string]bool{
+		"": true, "UTC": true, "Europe/Berlin": true, "Mars/Olympus": false, "Local": false,
+	} {
+		e.TimeZone = zone
+		if valid {
+			require.NoError(t, e.Validate(), zone)
+		} else {
+			require.ErrorIs(t, e.Validate(), ErrInvalidEvent, zone)
+		}
+	}
+}
+
+func TestReminders(t *testing.T) {
+	start := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+	e := Event{
+		ID: "e1", Title: "meeting", UserID: "u1", StartAt: start, EndAt: start.Add(time.Hour),
+		NotifyBefore: 15 * time.Minute, RRule: "FREQ=DAILY;COUNT=2",
+	}
+
+	t.Run("validate", func(t *testing.T) {
+		for _, tc := range []struct {
+			reminder Reminder
+			valid    bool
+		}{
+			{Reminder{Before: time.Hour, Channel: ChannelEmail}, true},
+			{Reminder{Before: 0, Channel: ChannelLog}, true},
+			{Reminder{Before: -time.Minute, Channel: ChannelLog}, false},
+			{Reminder{Before: time.Hour}, false},
+			{Reminder{Before: time.Hour, Channel: "sms"}, false},
+		} {
+			e := e
+			e.Reminders = []Reminder{tc.reminder}
+			if tc.valid {
+				require.NoError(t, e.Validate(), tc.reminder)
+			} else {
+				require.ErrorIs(t, e.Validate(), ErrInvalidEvent, tc.reminder)
+			}
+		}
+	})
+
+	t.Run("normalize", func(t *testing.T) {
+		e := e
+		e.Reminders = []Reminder{
+			{Before: time.Minute, Channel: ChannelLog},
+			{Before: time.Hour, Channel: ChannelWebhook},
+			{Before: time.Hour, Channel: ChannelEmail},
+			{Before: time.Minute, Channel: ChannelLog},
+		}
+		require.Equal(t, []Reminder{
+			{Before: time.Hour, Channel: ChannelEmail},
+			{Before: time.Hour, Channel: ChannelWebhook},
+			{Before: time.Minute, Channel: ChannelLog},
+		}, e.Normalize().Reminders)
+	})
+
+	t.Run("due reminders", func(t *testing.T) {
+		e := e
+		e.Reminders = []Reminder{{Before: 24 * time.Hour, Channel: ChannelEmail}}
+
+		due := e.DueReminders(start.Add(-time.Hour), start.Add(time.Hour))
+		SortDueReminders(due)
+		require.Len(t, due, 2)
+		require.Equal(t, Reminder{Before: 15 * time.Minute}, due[0].Reminder)
+		require.Equal(t, start.Add(-15*time.Minute), due[0].NotifyAt())
+		require.Equal(t, ChannelEmail, due[1].Reminder.Channel)
+		require.Equal(t, start.AddDate(0, 0, 1), due[1].Event.StartAt)
+
+		require.Empty(t, e.DueReminders(start.Add(-15*time.Minute+time.Second), start))
+	})
+
+	t.Run("notification ids", func(t *testing.T) {
+		d := DueReminder{Event: e, Reminder: Reminder{Before: time.Hour, Channel: ChannelEmail}}
+		n := NewNotification(d, "u2")
+		require.Equal(t, "e1:"+strconv.FormatInt(start.UnixMicro(), 10)+":3600000000000:email:u2", n.ID)
+		require.Equal(t, ChannelEmail, n.Channel)
+
+		next := d
+		next.Event.StartAt = start.AddDate(0, 0, 1)
+		require.NotEqual(t, n.ID, NewNotification(next, "u2").ID)
+		require.NotEqual(t, n.ID, NewNotification(d, "u3").ID)
+	})
+}
@@ -1,14 +1,597 @@
 package memorystorage
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+)
 
 type Storage struct {
-	// TODO
-	mu sync.RWMutex
+	mu     sync.RWMutex
+	events map[string]storage.Event
+	// index is the inverted index of event titles and descriptions:
+	// weights of search terms by term and event ID, see storage.Event.TermWeights.
+	index map[string]map[string]float64
+
+	// changes is the change log ordered by seq, the position of the last logged change.
+	// Changes up to compacted are removed from it.
+	changes   []change
+	seq       int64
+	compacted int64
+
+	// outbox holds notifications by their IDs, see EnqueueNotifications.
+	outbox map[string]outboxEntry
+}
+
+type outboxEntry struct {
+	notification storage.Notification
+	enqueuedAt   time.Time
+	// publishedAt is zero while the notification is pending.
+	publishedAt time.Time
+	// claimedBy is the publisher of the notification since claimedAt, see ClaimPendingNotifications.
+	claimedBy string
+	claimedAt time.Time
+}
+
+// change is an entry of the change log: the event changed for the user.
+type change struct {
+	seq     int64
+	userID  string
+	eventID string
+	at      time.Time
 }
 
 func New() *Storage {
-	return &Storage{}
+	return &Storage{
+		events: make(map[string]storage.Event),
+		index:  make(map[string]map[string]float64),
+		outbox: make(map[string]outboxEntry),
+	}
+}
+
+// Connect is a no-op, it makes memory storage interchangeable with the sql one.
+func (s *Storage) Connect(ctx context.Context) error {
+	return nil
+}
+
+// Close is a no-op, it makes memory storage interchangeable with the sql one.
+func (s *Storage) Close(ctx context.Context) error {
+	return nil
+}
+
+// Ping always succeeds, it makes memory storage interchangeable with the sql one.
+func (s *Storage) Ping(ctx context.Context) error {
+	return nil
+}
+
+// CreateEvent stores a new event under a generated ID and returns it.
+// The ID becomes the UID of the event unless it has one.
+func (s *Storage) CreateEvent(ctx context.Context, e storage.Event) (storage.Event, error) {
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e = e.Normalize()
+	e.ID = uuid.NewString()
+	e.Version = 1
+	if e.UID == "" {
+		e.UID = e.ID
+	} else if _, ok := s.byUID(e.UserID, e.UID, time.Time{}); ok {
+		return storage.Event{}, storage.ErrUIDTaken
+	}
+	if !storage.OverlapAllowed(ctx) && s.isBusy(e) {
+		return storage.Event{}, storage.ErrDateBusy
+	}
+	s.put(e)
+	s.logChange(e.ID, e)
+	return e, nil
+}
+
+// UpdateEvent replaces the event with the given ID and returns the stored version.
+func (s *Storage) UpdateEvent(ctx context.Context, id string, e storage.Event) (storage.Event, error) {
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.events[id]
+	if !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	version, err := e.NextVersion(stored)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	e = e.Normalize()
+	e.ID, e.Version = id, version
+	e.SeriesID, e.RecurrenceID, e.UID = stored.SeriesID, stored.RecurrenceID, stored.UID
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	if !storage.OverlapAllowed(ctx) && s.isBusy(e) {
+		return storage.Event{}, storage.ErrDateBusy
+	}
+	s.put(e)
+	s.logChange(id, stored, e)
+	return e, nil
+}
+
+// DeleteEvent deletes the event, a recurring one together with its edited occurrences.
+func (s *Storage) DeleteEvent(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.events[id]; !ok {
+		return storage.ErrNotFound
+	}
+	s.delete(id)
+	return nil
+}
+
+// ExcludeOccurrence deletes the occurrence of the recurring event starting at start.
+// A non-zero version must equal the version of the series.
+func (s *Storage) ExcludeOccurrence(ctx context.Context, id string, start time.Time, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.events[id]
+	if !ok || !series.HasOccurrence(start) {
+		return storage.ErrNotFound
+	}
+	if _, err := (storage.Event{Version: version}).NextVersion(series); err != nil {
+		return err
+	}
+	s.put(excluded(series, start))
+	s.logChange(id, series)
+	return nil
+}
+
+// DetachOccurrence replaces the occurrence of the recurring event starting at start
+// with e, a one-off event linked to the series, and returns it. The occurrence may be
+// a deleted one; an already edited occurrence is replaced keeping its ID. A non-zero
+// e.Version must equal the version of the series.
+func (s *Storage) DetachOccurrence(
+	ctx context.Context, id string, start time.Time, e storage.Event,
+) (storage.Event, error) {
+	e.SeriesID, e.RecurrenceID = id, start
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.events[id]
+	if !ok || !series.RecursAt(start) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	if _, err := e.NextVersion(series); err != nil {
+		return storage.Event{}, err
+	}
+	e = e.Normalize()
+	e.UID = series.UID
+	edited, ok := s.byUID(series.UserID, series.UID, e.RecurrenceID)
+	if ok {
+		e.ID, e.Version = edited.ID, edited.Version+1
+	} else {
+		e.ID, e.Version = uuid.NewString(), 1
+	}
+
+	s.put(excluded(series, start))
+	if !storage.OverlapAllowed(ctx) && s.isBusy(e) {
+		s.put(series)
+		return storage.Event{}, storage.ErrDateBusy
+	}
+	s.put(e)
+	if s.events[id].Version != series.Version {
+		s.logChange(id, series)
+	}
+	s.logChange(e.ID, edited, e)
+	return e, nil
+}
+
+// RespondToEvent sets the RSVP status of the attendee of the event and returns the event.
+// The event is not found for users who do not attend it.
+func (s *Storage) RespondToEvent(
+	ctx context.Context, id, userID string, status storage.RSVPStatus,
+) (storage.Event, error) {
+	if !status.Valid() {
+		return storage.Event{}, fmt.Errorf("%w: unknown RSVP status %q", storage.ErrInvalidEvent, status)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.events[id]
+	if !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	if e, ok = e.Respond(userID, status); !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	e.Version++
+	s.put(e)
+	s.logChange(id, e)
+	return e, nil
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.events[id]
+	if !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return e, nil
+}
+
+// GetEventByUID returns user's event with the given UID. A non-zero recurrenceID
+// selects the edited occurrence of the recurring event starting at that time.
+func (s *Storage) GetEventByUID(
+	ctx context.Context, userID, uid string, recurrenceID time.Time,
+) (storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.byUID(userID, uid, recurrenceID)
+	if !ok {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	return e, nil
+}
+
+// ListConflicts returns other events of the user of e whose occurrences intersect occurrences of e
+// within its BusySpan, ordered by start time.
+func (s *Storage) ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conflicts := make([]storage.Event, 0)
+	for _, other := range s.events {
+		if other.ID != e.ID && other.UserID == e.UserID && e.ConflictsWith(other) {
+			conflicts = append(conflicts, other)
+		}
+	}
+	storage.SortEvents(conflicts)
+	return conflicts, nil
+}
+
+// ListEvents returns user's events active within [from, to) ordered by start time.
+// Unlike the other listings, recurring events are returned as stored, not expanded.
+func (s *Storage) ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0)
+	for _, e := range s.events {
+		if e.UserID == userID && e.ActiveWithin(from, to) {
+			events = append(events, e)
+		}
+	}
+	storage.SortEvents(events)
+	return events, nil
+}
+
+func (s *Storage) ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error) {
+	from, to := storage.DayRange(date)
+	return s.listEvents(userID, from, to), nil
+}
+
+func (s *Storage) ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error) {
+	from, to := storage.WeekRange(weekStart)
+	return s.listEvents(userID, from, to), nil
+}
+
+func (s *Storage) ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error) {
+	from, to := storage.MonthRange(monthStart)
+	return s.listEvents(userID, from, to), nil
+}
+
+// ListDueReminders returns reminders of events and their occurrences firing within [from, to)
+// ordered by the moment they fire.
+func (s *Storage) ListDueReminders(ctx context.Context, from, to time.Time) ([]storage.DueReminder, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	due := make([]storage.DueReminder, 0)
+	for _, e := range s.events {
+		due = append(due, e.DueReminders(from, to)...)
+	}
+	storage.SortDueReminders(due)
+	return due, nil
+}
+
+// EnqueueNotifications adds notifications to the outbox unless they were added before
+// and returns the number of added ones.
+func (s *Storage) EnqueueNotifications(ctx context.Context, ns []storage.Notification, at time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := 0
+	for _, n := range ns {
+		if _, ok := s.outbox[n.ID]; ok {
+			continue
+		}
+		s.outbox[n.ID] = outboxEntry{notification: n, enqueuedAt: at}
+		added++
+	}
+	return added, nil
+}
+
+// ClaimPendingNotifications claims up to limit notifications of the outbox not published yet
+// for the claimer and returns them in the order they were added. Notifications claimed by others
+// are skipped unless their claim was made before staleBefore.
+func (s *Storage) ClaimPendingNotifications(
+	ctx context.Context, claimer string, at, staleBefore time.Time, limit int,
+) ([]storage.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := make([]outboxEntry, 0)
+	for _, e := range s.outbox {
+		claimable := e.claimedBy == "" || e.claimedBy == claimer || e.claimedAt.Before(staleBefore)
+		if e.publishedAt.IsZero() && claimable {
+			pending = append(pending, e)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if !pending[i].enqueuedAt.Equal(pending[j].enqueuedAt) {
+			return pending[i].enqueuedAt.Before(pending[j].enqueuedAt)
+		}
+		return pending[i].notification.ID < pending[j].notification.ID
+	})
+
+	ns := make([]storage.Notification, 0, min(limit, len(pending)))
+	for _, e := range pending[:min(limit, len(pending))] {
+		e.claimedBy, e.claimedAt = claimer, at
+		s.outbox[e.notification.ID] = e
+		ns = append(ns, e.notification)
+	}
+	return ns, nil
+}
+
+// MarkNotificationPublished keeps the notification in the outbox, but no longer lists it as pending.
+func (s *Storage) MarkNotificationPublished(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.outbox[id]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	e.publishedAt = at
+	s.outbox[id] = e
+	return nil
+}
+
+// CompactOutbox drops published notifications added before the given moment and returns their number.
+func (s *Storage) CompactOutbox(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var compacted int64
+	for id, e := range s.outbox {
+		if !e.publishedAt.IsZero() && e.enqueuedAt.Before(before) {
+			delete(s.outbox, id)
+			compacted++
+		}
+	}
+	return compacted, nil
+}
+
+// DeleteEventsEndedBefore deletes events whose last occurrence ended before the given moment
+// and returns their number, counting edited occurrences deleted with their recurring events.
+func (s *Storage) DeleteEventsEndedBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, e := range s.events {
+		if end, ok := e.LastEnd(); ok && end.Before(before) {
+			deleted += s.delete(id)
+		}
+	}
+	return deleted, nil
+}
+
+// ListChanges returns changes of the events the user owns or attends after the seq position
+// of the change log, or all of the events when seq is zero. It fails with ErrChangesCompacted
+// when the changes are no longer logged.
+func (s *Storage) ListChanges(ctx context.Context, userID string, seq int64) (storage.Changes, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if seq == 0 {
+		var events []storage.Event
+		for _, e := range s.events {
+			if e.Involves(userID) {
+				events = append(events, e)
+			}
+		}
+		return storage.NewChanges(userID, nil, events, s.seq), nil
+	}
+	if seq < s.compacted || seq > s.seq {
+		return storage.Changes{}, storage.ErrChangesCompacted
+	}
+
+	var (
+		changed []string
+		current []storage.Event
+		seen    = make(map[string]bool)
+	)
+	for _, c := range s.changes[sort.Search(len(s.changes), func(i int) bool { return s.changes[i].seq > seq }):] {
+		if c.userID != userID || seen[c.eventID] {
+			continue
+		}
+		seen[c.eventID] = true
+		changed = append(changed, c.eventID)
+		if e, ok := s.events[c.eventID]; ok {
+			current = append(current, e)
+		}
+	}
+	return storage.NewChanges(userID, changed, current, s.seq), nil
 }
 
-// TODO
+// CompactChanges removes changes logged before the given moment and returns their number.
+func (s *Storage) CompactChanges(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := sort.Search(len(s.changes), func(i int) bool { return !s.changes[i].at.Before(before) })
+	if n > 0 {
+		s.compacted = s.changes[n-1].seq
+		s.changes = append([]change(nil), s.changes[n:]...)
+	}
+	return int64(n), nil
+}
+
+// SearchEvents returns events selected by the query ordered by storage.SortHits. An event is ranked
+// by the weights of the query terms in it.
+func (s *Storage) SearchEvents(ctx context.Context, q storage.SearchQuery) ([]storage.SearchHit, error) {
+	terms := storage.SearchTerms(q.Text)
+	if len(terms) == 0 {
+		return []storage.SearchHit{}, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Candidates are the events with the rarest term, the others are looked up in their order
+	// to keep ranks of an event the same for the same query.
+	rarest := terms[0]
+	for _, term := range terms[1:] {
+		if len(s.index[term]) < len(s.index[rarest]) {
+			rarest = term
+		}
+	}
+	hits := make([]storage.SearchHit, 0)
+	for id := range s.index[rarest] {
+		hit, ok := storage.SearchHit{Event: s.events[id]}, true
+		for _, term := range terms {
+			weight, found := s.index[term][id]
+			ok = ok && found
+			hit.Rank += weight
+		}
+		if ok && q.Selects(hit.Event) && (q.After == nil || q.After.Before(hit)) {
+			hits = append(hits, hit)
+		}
+	}
+	storage.SortHits(hits)
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// listEvents returns occurrences of events the user owns or attends intersecting [from, to)
+// ordered by start time.
+func (s *Storage) listEvents(userID string, from, to time.Time) []storage.Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]storage.Event, 0)
+	for _, e := range s.events {
+		if e.Involves(userID) {
+			events = append(events, e.Occurrences(from, to)...)
+		}
+	}
+	storage.SortEvents(events)
+	return events
+}
+
+// isBusy reports whether another event of the same user intersects e. Must be called under lock.
+// Storage.ListConflicts is the listing counterpart.
+func (s *Storage) isBusy(e storage.Event) bool {
+	for _, other := range s.events {
+		if other.ID != e.ID && other.UserID == e.UserID && e.ConflictsWith(other) {
+			return true
+		}
+	}
+	return false
+}
+
+// byUID finds user's event by UID and recurrence ID. Must be called under lock.
+func (s *Storage) byUID(userID, uid string, recurrenceID time.Time) (storage.Event, bool) {
+	recurrenceID = recurrenceID.UTC().Truncate(time.Microsecond)
+	for _, e := range s.events {
+		if e.UserID == userID && e.UID == uid && e.RecurrenceID.Equal(recurrenceID) {
+			return e, true
+		}
+	}
+	return storage.Event{}, false
+}
+
+// delete deletes the event with its edited occurrences and returns the number of deleted events.
+// Must be called under lock.
+func (s *Storage) delete(id string) int64 {
+	deleted := int64(1)
+	s.logChange(id, s.events[id])
+	s.remove(id)
+	for otherID, other := range s.events {
+		if other.SeriesID == id {
+			s.logChange(otherID, other)
+			s.remove(otherID)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// put stores the event and indexes its title and description. Must be called under lock.
+func (s *Storage) put(e storage.Event) {
+	if stored, ok := s.events[e.ID]; ok {
+		s.unindex(stored)
+	}
+	s.events[e.ID] = e
+	for term, weight := range e.TermWeights() {
+		if s.index[term] == nil {
+			s.index[term] = make(map[string]float64)
+		}
+		s.index[term][e.ID] = weight
+	}
+}
+
+// remove deletes the event from the storage and the index. Must be called under lock.
+func (s *Storage) remove(id string) {
+	s.unindex(s.events[id])
+	delete(s.events, id)
+}
+
+func (s *Storage) unindex(e storage.Event) {
+	for term := range e.TermWeights() {
+		delete(s.index[term], e.ID)
+		if len(s.index[term]) == 0 {
+			delete(s.index, term)
+		}
+	}
+}
+
+// logChange logs the change of the event for the participants of its versions before
+// and after the change. Must be called under lock.
+func (s *Storage) logChange(id string, versions ...storage.Event) {
+	now := time.Now()
+	for _, userID := range storage.Participants(versions...) {
+		s.seq++
+		s.changes = append(s.changes, change{seq: s.seq, userID: userID, eventID: id, at: now})
+	}
+}
+
+// excluded returns the next version of the series without the occurrence starting at start.
+func excluded(series storage.Event, start time.Time) storage.Event {
+	if series.HasOccurrence(start) {
+		series = series.Exclude(start)
+		series.Version++
+	}
+	return series
+}
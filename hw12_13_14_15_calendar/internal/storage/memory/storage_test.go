@@ -1,7 +1,662 @@
 package memorystorage
 
-import "testing"
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC) // Monday.
+
+func newEvent(userID string, start time.Time, dur time.Duration) storage.Event {
+	return storage.Event{
+		Title:   "event at " + start.Format(time.RFC3339),
+		StartAt: start,
+		EndAt:   start.Add(dur),
+		UserID:  userID,
+	}
+}
 
 func TestStorage(t *testing.T) {
-	// TODO
+	ctx := context.Background()
+
+	t.Run("create, get, update, delete", func(t *testing.T) {
+		s := New()
+
+		created, err := s.CreateEvent(ctx, newEvent("u1", baseTime, time.Hour))
+		require.NoError(t, err)
+		require.NotEmpty(t, created.ID)
+
+		got, err := s.GetEvent(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, created, got)
+
+		upd := got
+		upd.Title = "updated"
+		upd.Description = "long text"
+		upd.NotifyBefore = 15 * time.Minute
+		upd.ID = "ignored"
+		updated, err := s.UpdateEvent(ctx, created.ID, upd)
+		require.NoError(t, err)
+		require.Equal(t, created.ID, updated.ID)
+		require.Equal(t, "updated", updated.Title)
+
+		got, err = s.GetEvent(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, updated, got)
+
+		require.NoError(t, s.DeleteEvent(ctx, created.ID))
+		_, err = s.GetEvent(ctx, created.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		s := New()
+
+		e := newEvent("u1", baseTime, time.Hour)
+		e.RRule = "FREQ=DAILY"
+		e.Attendees = []storage.Attendee{{UserID: "u2"}}
+		created, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), created.Version)
+
+		updated, err := s.UpdateEvent(ctx, created.ID, created)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), updated.Version)
+		_, err = s.UpdateEvent(ctx, created.ID, created)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+		e.Version = 0
+		updated, err = s.UpdateEvent(ctx, created.ID, e)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), updated.Version)
+
+		responded, err := s.RespondToEvent(ctx, created.ID, "u2", storage.RSVPAccepted)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), responded.Version)
+
+		second := baseTime.AddDate(0, 0, 1)
+		moved := newEvent("u1", second.Add(time.Hour), time.Hour)
+		moved.Version = updated.Version
+		_, err = s.DetachOccurrence(ctx, created.ID, second, moved)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+		moved.Version = responded.Version
+		detached, err := s.DetachOccurrence(ctx, created.ID, second, moved)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), detached.Version)
+		detached, err = s.DetachOccurrence(ctx, created.ID, second, newEvent("u1", second.Add(2*time.Hour), time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), detached.Version)
+		require.ErrorIs(t, s.ExcludeOccurrence(ctx, created.ID, baseTime, responded.Version), storage.ErrVersionMismatch)
+		require.NoError(t, s.ExcludeOccurrence(ctx, created.ID, baseTime, responded.Version+1))
+		got, err := s.GetEvent(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(6), got.Version)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s := New()
+
+		_, err := s.GetEvent(ctx, "missing")
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		_, err = s.UpdateEvent(ctx, "missing", newEvent("u1", baseTime, time.Hour))
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		require.ErrorIs(t, s.DeleteEvent(ctx, "missing"), storage.ErrNotFound)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		s := New()
+
+		for name, e := range map[string]storage.Event{
+			"empty title":     {UserID: "u1", StartAt: baseTime, EndAt: baseTime.Add(time.Hour)},
+			"empty user":      {Title: "t", StartAt: baseTime, EndAt: baseTime.Add(time.Hour)},
+			"no start":        {Title: "t", UserID: "u1", EndAt: baseTime},
+			"end before":      {Title: "t", UserID: "u1", StartAt: baseTime, EndAt: baseTime.Add(-time.Hour)},
+			"negative notify": {Title: "t", UserID: "u1", StartAt: baseTime, EndAt: baseTime.Add(time.Hour), NotifyBefore: -1},
+		} {
+			_, err := s.CreateEvent(ctx, e)
+			require.ErrorIs(t, err, storage.ErrInvalidEvent, name)
+		}
+	})
+
+	t.Run("date busy", func(t *testing.T) {
+		s := New()
+
+		first, err := s.CreateEvent(ctx, newEvent("u1", baseTime, time.Hour))
+		require.NoError(t, err)
+
+		_, err = s.CreateEvent(ctx, newEvent("u1", baseTime.Add(30*time.Minute), time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		// Adjacent events and events of other users do not conflict.
+		second, err := s.CreateEvent(ctx, newEvent("u1", baseTime.Add(time.Hour), time.Hour))
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("u2", baseTime, time.Hour))
+		require.NoError(t, err)
+
+		// An event does not conflict with itself on update, but does with others.
+		_, err = s.UpdateEvent(ctx, first.ID, newEvent("u1", baseTime.Add(-30*time.Minute), time.Hour))
+		require.NoError(t, err)
+		_, err = s.UpdateEvent(ctx, second.ID, newEvent("u1", baseTime, time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+	})
+
+	t.Run("allowed overlap", func(t *testing.T) {
+		s := New()
+
+		first, err := s.CreateEvent(ctx, newEvent("u1", baseTime, time.Hour))
+		require.NoError(t, err)
+		_, err = s.CreateEvent(ctx, newEvent("u2", baseTime, time.Hour))
+		require.NoError(t, err)
+
+		overlapping, err := s.CreateEvent(storage.WithOverlap(ctx),
+			newEvent("u1", baseTime.Add(30*time.Minute), time.Hour))
+		require.NoError(t, err)
+		conflicts, err := s.ListConflicts(ctx, overlapping)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{first}, conflicts)
+
+		moved, err := s.UpdateEvent(ctx, overlapping.ID, newEvent("u1", baseTime.Add(time.Hour), time.Hour))
+		require.NoError(t, err)
+		conflicts, err = s.ListConflicts(ctx, moved)
+		require.NoError(t, err)
+		require.Empty(t, conflicts)
+	})
+
+	t.Run("list day, week, month", func(t *testing.T) {
+		s := New()
+
+		for _, start := range []time.Time{
+			baseTime.AddDate(0, 0, -5),  // previous month
+			baseTime.AddDate(0, 0, -1),  // previous day
+			baseTime,                    // this day
+			baseTime.Add(3 * time.Hour), // this day
+			baseTime.AddDate(0, 0, 6),   // last day of the week
+			baseTime.AddDate(0, 0, 7),   // next week
+			baseTime.AddDate(0, 0, 28),  // next month
+		} {
+			_, err := s.CreateEvent(ctx, newEvent("u1", start, time.Hour))
+			require.NoError(t, err)
+		}
+		_, err := s.CreateEvent(ctx, newEvent("u2", baseTime, time.Hour))
+		require.NoError(t, err)
+
+		day, err := s.ListDayEvents(ctx, "u1", baseTime.Add(5*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, day, 2)
+		require.True(t, day[0].StartAt.Before(day[1].StartAt))
+
+		week, err := s.ListWeekEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.Len(t, week, 3)
+
+		month, err := s.ListMonthEvents(ctx, "u1", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, month, 5)
+
+		empty, err := s.ListDayEvents(ctx, "u3", baseTime)
+		require.NoError(t, err)
+		require.Empty(t, empty)
+	})
+
+	t.Run("event spanning range bound is listed", func(t *testing.T) {
+		s := New()
+
+		_, err := s.CreateEvent(ctx, newEvent("u1", baseTime.Add(-12*time.Hour), 13*time.Hour))
+		require.NoError(t, err)
+
+		day, err := s.ListDayEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.Len(t, day, 1)
+	})
+
+	t.Run("due reminders", func(t *testing.T) {
+		s := New()
+
+		var due storage.Event
+		for i, notifyBefore := range []time.Duration{0, time.Hour, 2 * time.Hour, 30 * time.Minute} {
+			e := newEvent("u1", baseTime.Add(time.Duration(i)*3*time.Hour), time.Hour)
+			e.NotifyBefore = notifyBefore
+			if i == 3 {
+				e.Reminders = []storage.Reminder{{Before: 5 * time.Hour, Channel: storage.ChannelEmail}}
+			}
+			created, err := s.CreateEvent(ctx, e)
+			require.NoError(t, err)
+			if i == 2 {
+				due = created
+			}
+		}
+
+		// Reminders fire at: never, 2:00, 4:00, 8:30 and, by email, 4:00 after baseTime.
+		reminders, err := s.ListDueReminders(ctx, baseTime.Add(4*time.Hour), baseTime.Add(5*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, reminders, 2)
+		require.Equal(t, storage.DueReminder{Event: due, Reminder: storage.Reminder{Before: 2 * time.Hour}}, reminders[0])
+		require.Equal(t, storage.ChannelEmail, reminders[1].Reminder.Channel)
+
+		reminders, err = s.ListDueReminders(ctx, baseTime.Add(-time.Hour), baseTime.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, reminders)
+	})
+
+	t.Run("notification outbox", func(t *testing.T) {
+		s := New()
+		n1 := storage.Notification{ID: "n1", EventID: "e1", UserID: "u1"}
+		n2 := storage.Notification{ID: "n2", EventID: "e2", UserID: "u1"}
+
+		added, err := s.EnqueueNotifications(ctx, []storage.Notification{n2}, baseTime.Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, 1, added)
+		added, err = s.EnqueueNotifications(ctx, []storage.Notification{n1, n2}, baseTime)
+		require.NoError(t, err)
+		require.Equal(t, 1, added)
+
+		pending, err := s.ClaimPendingNotifications(ctx, "s1", baseTime, baseTime.Add(-time.Minute), 1)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Notification{n1}, pending)
+		pending, err = s.ClaimPendingNotifications(ctx, "s2", baseTime, baseTime.Add(-time.Minute), 10)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Notification{n2}, pending, "claimed by s1")
+		pending, err = s.ClaimPendingNotifications(ctx, "s1", baseTime, baseTime.Add(-time.Minute), 10)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Notification{n1}, pending, "claimed by s2")
+		pending, err = s.ClaimPendingNotifications(ctx, "s3", baseTime.Add(time.Minute), baseTime.Add(time.Second), 10)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Notification{n1, n2}, pending, "stale claims")
+
+		require.NoError(t, s.MarkNotificationPublished(ctx, "n1", baseTime.Add(time.Hour)))
+		require.ErrorIs(t, s.MarkNotificationPublished(ctx, "n3", baseTime), storage.ErrNotFound)
+		pending, err = s.ClaimPendingNotifications(ctx, "s3", baseTime.Add(time.Minute), baseTime, 10)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Notification{n2}, pending)
+
+		compacted, err := s.CompactOutbox(ctx, baseTime.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), compacted, "pending notifications are kept")
+		added, err = s.EnqueueNotifications(ctx, []storage.Notification{n2}, baseTime.Add(time.Hour))
+		require.NoError(t, err)
+		require.Zero(t, added)
+	})
+
+	t.Run("delete old events", func(t *testing.T) {
+		s := New()
+
+		old, err := s.CreateEvent(ctx, newEvent("u1", baseTime.AddDate(-1, 0, -1), time.Hour))
+		require.NoError(t, err)
+		fresh, err := s.CreateEvent(ctx, newEvent("u1", baseTime.AddDate(-1, 0, 1), time.Hour))
+		require.NoError(t, err)
+
+		deleted, err := s.DeleteEventsEndedBefore(ctx, baseTime.AddDate(-1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), deleted)
+
+		_, err = s.GetEvent(ctx, old.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = s.GetEvent(ctx, fresh.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("recurring event is expanded", func(t *testing.T) {
+		s := New()
+
+		e := newEvent("u1", baseTime, time.Hour)
+		e.RRule = "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=5"
+		e.ExDates = []time.Time{baseTime.AddDate(0, 0, 7)}
+		series, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		week, err := s.ListWeekEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.Len(t, week, 2)
+		require.Equal(t, baseTime.AddDate(0, 0, 3), week[1].StartAt)
+		require.Equal(t, series.ID, week[1].ID)
+
+		month, err := s.ListMonthEvents(ctx, "u1", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, month, 4) // 4th, 7th, 14th and 18th; the 11th is excluded.
+	})
+
+	t.Run("occurrence conflicts", func(t *testing.T) {
+		s := New()
+
+		daily := newEvent("u1", baseTime.AddDate(0, 0, -10), time.Hour)
+		daily.RRule = "FREQ=DAILY"
+		_, err := s.CreateEvent(ctx, daily)
+		require.NoError(t, err)
+
+		_, err = s.CreateEvent(ctx, newEvent("u1", baseTime.AddDate(0, 1, 0).Add(30*time.Minute), time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+
+		weekly := newEvent("u1", baseTime.Add(-3*time.Hour), time.Hour)
+		weekly.RRule = "FREQ=WEEKLY"
+		_, err = s.CreateEvent(ctx, weekly)
+		require.NoError(t, err)
+
+		weekly.StartAt, weekly.EndAt = baseTime.AddDate(0, 0, 1), baseTime.AddDate(0, 0, 1).Add(time.Hour)
+		_, err = s.CreateEvent(ctx, weekly)
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+	})
+
+	t.Run("edit and delete single occurrence", func(t *testing.T) {
+		s := New()
+
+		e := newEvent("u1", baseTime, time.Hour)
+		e.RRule = "FREQ=DAILY;COUNT=3"
+		series, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		second, third := baseTime.AddDate(0, 0, 1), baseTime.AddDate(0, 0, 2)
+		require.NoError(t, s.ExcludeOccurrence(ctx, series.ID, third, 0))
+		require.ErrorIs(t, s.ExcludeOccurrence(ctx, series.ID, third, 0), storage.ErrNotFound)
+		require.ErrorIs(t, s.ExcludeOccurrence(ctx, series.ID, second.Add(time.Minute), 0), storage.ErrNotFound)
+
+		moved := newEvent("u1", second.Add(2*time.Hour), time.Hour)
+		moved.Title = "moved"
+		detached, err := s.DetachOccurrence(ctx, series.ID, second, moved)
+		require.NoError(t, err)
+		require.Equal(t, series.ID, detached.SeriesID)
+		require.Equal(t, second, detached.RecurrenceID)
+
+		_, err = s.DetachOccurrence(ctx, series.ID, baseTime, newEvent("u1", second.Add(2*time.Hour), time.Hour))
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+		week, err := s.ListWeekEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.Len(t, week, 2)
+		require.Equal(t, baseTime, week[0].StartAt)
+		require.Equal(t, "moved", week[1].Title)
+
+		recurring := moved
+		recurring.RRule = "FREQ=DAILY"
+		_, err = s.UpdateEvent(ctx, detached.ID, recurring)
+		require.ErrorIs(t, err, storage.ErrInvalidEvent)
+
+		require.NoError(t, s.DeleteEvent(ctx, series.ID))
+		_, err = s.GetEvent(ctx, detached.ID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("events by uid", func(t *testing.T) {
+		s := New()
+
+		created, err := s.CreateEvent(ctx, newEvent("u1", baseTime, time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, created.ID, created.UID)
+
+		e := newEvent("u1", baseTime.AddDate(0, 0, 1), time.Hour)
+		e.UID = "imported@example.com"
+		e.RRule = "FREQ=DAILY;COUNT=3"
+		series, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+		e.StartAt, e.EndAt = e.StartAt.AddDate(0, 1, 0), e.EndAt.AddDate(0, 1, 0)
+		_, err = s.CreateEvent(ctx, e)
+		require.ErrorIs(t, err, storage.ErrUIDTaken)
+
+		second := baseTime.AddDate(0, 0, 2)
+		require.NoError(t, s.ExcludeOccurrence(ctx, series.ID, second, 0))
+		detached, err := s.DetachOccurrence(ctx, series.ID, second, newEvent("u1", second.Add(2*time.Hour), time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, series.UID, detached.UID)
+		again, err := s.DetachOccurrence(ctx, series.ID, second, newEvent("u1", second.Add(4*time.Hour), time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, detached.ID, again.ID)
+
+		got, err := s.GetEventByUID(ctx, "u1", "imported@example.com", time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, series.ID, got.ID)
+		got, err = s.GetEventByUID(ctx, "u1", "imported@example.com", second)
+		require.NoError(t, err)
+		require.Equal(t, again, got)
+		_, err = s.GetEventByUID(ctx, "u2", "imported@example.com", time.Time{})
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		events, err := s.ListEvents(ctx, "u1", baseTime.AddDate(0, 0, 1), baseTime.AddDate(0, 0, 7))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, []string{series.ID, again.ID}, []string{events[0].ID, events[1].ID})
+	})
+
+	t.Run("attendees and rsvp", func(t *testing.T) {
+		s := New()
+
+		e := newEvent("u1", baseTime, time.Hour)
+		e.Attendees = []storage.Attendee{{UserID: "u2"}, {UserID: "u3"}}
+		created, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+		require.Equal(t, storage.RSVPNeedsAction, created.Attendees[0].Status)
+
+		events, err := s.ListDayEvents(ctx, "u2", baseTime)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		events, err = s.ListDayEvents(ctx, "u4", baseTime)
+		require.NoError(t, err)
+		require.Empty(t, events)
+		events, err = s.ListEvents(ctx, "u2", baseTime, baseTime.Add(time.Hour))
+		require.NoError(t, err)
+		require.Empty(t, events)
+
+		responded, err := s.RespondToEvent(ctx, created.ID, "u2", storage.RSVPAccepted)
+		require.NoError(t, err)
+		require.Equal(t, storage.RSVPAccepted, responded.Attendees[0].Status)
+		require.Equal(t, storage.RSVPNeedsAction, created.Attendees[0].Status)
+		got, err := s.GetEvent(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, responded, got)
+
+		_, err = s.RespondToEvent(ctx, created.ID, "u4", storage.RSVPAccepted)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		_, err = s.RespondToEvent(ctx, created.ID, "u2", "maybe")
+		require.ErrorIs(t, err, storage.ErrInvalidEvent)
+
+		e.Attendees = []storage.Attendee{{UserID: "u1"}}
+		_, err = s.CreateEvent(ctx, e)
+		require.ErrorIs(t, err, storage.ErrInvalidEvent)
+	})
+
+	t.Run("change log", func(t *testing.T) {
+		s := New()
+
+		e := newEvent("u1", baseTime, time.Hour)
+		e.Attendees = []storage.Attendee{{UserID: "u2"}}
+		shared, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+		own, err := s.CreateEvent(ctx, newEvent("u1", baseTime.Add(time.Hour), time.Hour))
+		require.NoError(t, err)
+
+		full, err := s.ListChanges(ctx, "u2", 0)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{shared}, full.Events)
+		require.Empty(t, full.Deleted)
+
+		e.Attendees = nil
+		_, err = s.UpdateEvent(ctx, shared.ID, e)
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteEvent(ctx, own.ID))
+
+		changes, err := s.ListChanges(ctx, "u2", full.Seq)
+		require.NoError(t, err)
+		require.Empty(t, changes.Events)
+		require.Equal(t, []string{shared.ID}, changes.Deleted)
+		changes, err = s.ListChanges(ctx, "u1", full.Seq)
+		require.NoError(t, err)
+		require.Len(t, changes.Events, 1)
+		require.Equal(t, shared.ID, changes.Events[0].ID)
+		require.Equal(t, []string{own.ID}, changes.Deleted)
+
+		latest, err := s.ListChanges(ctx, "u1", changes.Seq)
+		require.NoError(t, err)
+		require.Empty(t, latest.Events)
+		require.Empty(t, latest.Deleted)
+		require.Equal(t, changes.Seq, latest.Seq)
+
+		compacted, err := s.CompactChanges(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Equal(t, int64(6), compacted)
+		_, err = s.ListChanges(ctx, "u1", full.Seq)
+		require.ErrorIs(t, err, storage.ErrChangesCompacted)
+		_, err = s.ListChanges(ctx, "u1", changes.Seq+1)
+		require.ErrorIs(t, err, storage.ErrChangesCompacted)
+		latest, err = s.ListChanges(ctx, "u1", changes.Seq)
+		require.NoError(t, err)
+		require.Empty(t, latest.Deleted)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		s := New()
+		save := func(userID, title, description string, day int) storage.Event {
+			e := newEvent(userID, baseTime.AddDate(0, 0, day), time.Hour)
+			e.Title, e.Description = title, description
+			e.Attendees = []storage.Attendee{{UserID: "u2"}}
+			e, err := s.CreateEvent(ctx, e)
+			require.NoError(t, err)
+			return e
+		}
+		inTitle := save("u1", "Budget review", "", 1)
+		inDescription := save("u1", "Planning", "budget for Q3, review it", 0)
+		otherOwner := save("u3", "Budget review!", "", 2)
+		save("u1", "Budget", "without the other word", 3)
+
+		search := func(q storage.SearchQuery) []storage.SearchHit {
+			hits, err := s.SearchEvents(ctx, q)
+			require.NoError(t, err)
+			return hits
+		}
+		ids := func(hits []storage.SearchHit) []string {
+			var ids []string
+			for _, h := range hits {
+				ids = append(ids, h.Event.ID)
+			}
+			return ids
+		}
+
+		hits := search(storage.SearchQuery{UserID: "u2", Text: "REVIEW budget"})
+		require.Equal(t, []string{inTitle.ID, otherOwner.ID, inDescription.ID}, ids(hits))
+		require.InDelta(t, 2*storage.TitleWeight, hits[0].Rank, 1e-9)
+		require.InDelta(t, 2*storage.DescriptionWeight, hits[2].Rank, 1e-9)
+
+		require.Equal(t, []string{inTitle.ID, inDescription.ID},
+			ids(search(storage.SearchQuery{UserID: "u1", Text: "budget review"})))
+		require.Equal(t, []string{otherOwner.ID},
+			ids(search(storage.SearchQuery{UserID: "u2", Text: "budget review", OwnerID: "u3"})))
+		require.Equal(t, []string{inDescription.ID}, ids(search(storage.SearchQuery{
+			UserID: "u2", Text: "budget review", From: baseTime, To: baseTime.Add(time.Hour),
+		})))
+
+		first := search(storage.SearchQuery{UserID: "u2", Text: "budget review", Limit: 2})
+		require.Equal(t, []string{inTitle.ID, otherOwner.ID}, ids(first))
+		after := first[1].Cursor()
+		rest := search(storage.SearchQuery{UserID: "u2", Text: "budget review", After: &after, Limit: 2})
+		require.Equal(t, []string{inDescription.ID}, ids(rest))
+
+		inTitle.Title = "Renamed"
+		_, err := s.UpdateEvent(ctx, inTitle.ID, inTitle)
+		require.NoError(t, err)
+		require.NoError(t, s.DeleteEvent(ctx, otherOwner.ID))
+		require.Equal(t, []string{inDescription.ID}, ids(search(storage.SearchQuery{UserID: "u2", Text: "budget review"})))
+		require.Equal(t, []string{inTitle.ID}, ids(search(storage.SearchQuery{UserID: "u2", Text: "renamed"})))
+		require.Empty(t, search(storage.SearchQuery{UserID: "u2", Text: "?!"}))
+		require.NotContains(t, s.index, "budget!")
+		require.Len(t, s.index["review"], 1)
+	})
+
+	t.Run("reminders of occurrences", func(t *testing.T) {
+		s := New()
+
+		e := newEvent("u1", baseTime, time.Hour)
+		e.RRule = "FREQ=DAILY"
+		e.NotifyBefore = time.Hour
+		_, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+
+		third := baseTime.AddDate(0, 0, 2)
+		reminders, err := s.ListDueReminders(ctx, third.Add(-time.Hour), third.Add(-time.Hour+time.Minute))
+		require.NoError(t, err)
+		require.Len(t, reminders, 1)
+		require.Equal(t, third, reminders[0].Event.StartAt)
+	})
+
+	t.Run("delete finished series", func(t *testing.T) {
+		s := New()
+
+		finished := newEvent("u1", baseTime.AddDate(-2, 0, 0), time.Hour)
+		finished.RRule = "FREQ=MONTHLY;COUNT=3"
+		series, err := s.CreateEvent(ctx, finished)
+		require.NoError(t, err)
+		second := series.StartAt.AddDate(0, 1, 0)
+		moved := newEvent("u1", second.Add(3*time.Hour), time.Hour)
+		_, err = s.DetachOccurrence(ctx, series.ID, second, moved)
+		require.NoError(t, err)
+		endless := newEvent("u1", baseTime.AddDate(-2, 0, 0).Add(2*time.Hour), time.Hour)
+		endless.RRule = "FREQ=MONTHLY"
+		_, err = s.CreateEvent(ctx, endless)
+		require.NoError(t, err)
+
+		deleted, err := s.DeleteEventsEndedBefore(ctx, baseTime.AddDate(-1, 0, 0))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), deleted, "with the edited occurrence")
+	})
+
+	t.Run("concurrent access", func(t *testing.T) {
+		s := New()
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				userID := "u" + strconv.Itoa(i%5)
+				e, err := s.CreateEvent(ctx, newEvent(userID, baseTime.Add(time.Duration(i)*time.Hour), time.Hour))
+				require.NoError(t, err)
+
+				e.Title = "updated"
+				_, err = s.UpdateEvent(ctx, e.ID, e)
+				require.NoError(t, err)
+
+				_, err = s.ListMonthEvents(ctx, userID, baseTime)
+				require.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+
+		var total int
+		for i := 0; i < 5; i++ {
+			events, err := s.ListMonthEvents(ctx, "u"+strconv.Itoa(i), baseTime)
+			require.NoError(t, err)
+			total += len(events)
+		}
+		require.Equal(t, 50, total)
+	})
+
+	t.Run("concurrent creates on the same slot", func(t *testing.T) {
+		s := New()
+		wg := sync.WaitGroup{}
+		var mu sync.Mutex
+		var created, busy int
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.CreateEvent(ctx, newEvent("u1", baseTime, time.Hour))
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err == nil {
+					created++
+					return
+				}
+				require.ErrorIs(t, err, storage.ErrDateBusy)
+				busy++
+			}()
+		}
+		wg.Wait()
+
+		require.Equal(t, 1, created)
+		require.Equal(t, 19, busy)
+	})
 }
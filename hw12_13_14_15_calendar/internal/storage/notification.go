@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notification is a reminder of an upcoming event to one of its Recipients. It is not stored,
+// the scheduler passes it to the sender through a queue.
+type Notification struct {
+	// ID identifies the reminder of the event or its occurrence to the user, so that it is sent once.
+	ID      string    `json:"id"`
+	EventID string    `json:"event_id"`
+	Title   string    `json:"title"`
+	StartAt time.Time `json:"start_at"`
+	UserID  string    `json:"user_id"`
+	// Channel is the channel of the reminder, empty for NotifyBefore sent through every channel.
+	Channel Channel `json:"channel,omitempty"`
+}
+
+// NewNotification makes a notification of the due reminder for the user.
+func NewNotification(d DueReminder, userID string) Notification {
+	id := strings.Join([]string{
+		d.Event.ID,
+		strconv.FormatInt(d.Event.StartAt.UnixMicro(), 10),
+		strconv.FormatInt(int64(d.Reminder.Before), 10),
+		string(d.Reminder.Channel),
+		userID,
+	}, ":")
+	return Notification{
+		ID:      id,
+		EventID: d.Event.ID,
+		Title:   d.Event.Title,
+		StartAt: d.Event.StartAt,
+		UserID:  userID,
+		Channel: d.Reminder.Channel,
+	}
+}
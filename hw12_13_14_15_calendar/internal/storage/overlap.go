@@ -0,0 +1,17 @@
+package storage
+
+import "context"
+
+type overlapKey struct{}
+
+// WithOverlap returns a context in which storages save events overlapping other events
+// of the same user instead of failing with ErrDateBusy.
+func WithOverlap(ctx context.Context) context.Context {
+	return context.WithValue(ctx, overlapKey{}, true)
+}
+
+// OverlapAllowed reports whether ctx was derived from a WithOverlap one.
+func OverlapAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(overlapKey{}).(bool)
+	return allowed
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/rrule"
+)
+
+// busyHorizon limits how far ahead occurrences of a recurring event are checked for conflicts.
+const busyHorizon = 366 * 24 * time.Hour
+
+// Recurring reports whether the event has a recurrence rule.
+func (e Event) Recurring() bool {
+	return e.RRule != ""
+}
+
+// Occurrences returns occurrences of the event intersecting [from, to) in chronological order.
+// An occurrence is a copy of the event moved to its start time; a one-off event is its own single occurrence.
+// Occurrences of a recurring event start at the same wall clock time in the zone of the event, so they
+// move relative to UTC across DST transitions.
+func (e Event) Occurrences(from, to time.Time) []Event {
+	if !e.Recurring() {
+		if e.Overlaps(from, to) {
+			return []Event{e}
+		}
+		return nil
+	}
+	rule, err := rrule.Parse(e.RRule)
+	if err != nil {
+		return nil
+	}
+
+	var occurrences []Event
+	duration := e.EndAt.Sub(e.StartAt)
+	for start := range rule.Since(e.StartAt.In(e.Location()), from.Add(-duration)) {
+		start = start.UTC()
+		if !start.Before(to) {
+			break
+		}
+		if start.Add(duration).After(from) && !e.excluded(start) {
+			occurrence := e
+			occurrence.StartAt, occurrence.EndAt = start, start.Add(duration)
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences
+}
+
+// HasOccurrence reports whether an occurrence of the recurring event starts at start.
+func (e Event) HasOccurrence(start time.Time) bool {
+	if !e.Recurring() {
+		return false
+	}
+	occurrences := e.Occurrences(start, start.Add(time.Microsecond))
+	return slices.ContainsFunc(occurrences, func(o Event) bool { return o.StartAt.Equal(start) })
+}
+
+// RecursAt reports whether the recurrence rule produces an occurrence starting at start,
+// whether it is excluded or not.
+func (e Event) RecursAt(start time.Time) bool {
+	e.ExDates = nil
+	return e.HasOccurrence(start)
+}
+
+// ActiveWithin reports whether the time from the start of the event till the end
+// of its last occurrence intersects [from, to).
+func (e Event) ActiveWithin(from, to time.Time) bool {
+	if !e.StartAt.Before(to) {
+		return false
+	}
+	end, ok := e.LastEnd()
+	return !ok || end.After(from)
+}
+
+// LastEnd returns the end of the last occurrence of the event; ok is false when it recurs forever.
+func (e Event) LastEnd() (end time.Time, ok bool) {
+	if !e.Recurring() {
+		return e.EndAt, true
+	}
+	rule, err := rrule.Parse(e.RRule)
+	if err != nil || !rule.Finite() {
+		return time.Time{}, false
+	}
+	return rule.Last(e.StartAt.In(e.Location())).UTC().Add(e.EndAt.Sub(e.StartAt)), true
+}
+
+// BusySpan returns the interval occurrences of e are checked for conflicts within:
+// the event itself or about a year since the first occurrence of a recurring event.
+func (e Event) BusySpan() (from, to time.Time) {
+	if e.Recurring() {
+		return e.StartAt, e.StartAt.Add(busyHorizon)
+	}
+	return e.StartAt, e.EndAt
+}
+
+// ConflictsWith reports whether occurrences of the events intersect within the BusySpan of e.
+func (e Event) ConflictsWith(other Event) bool {
+	mine := e.Occurrences(e.BusySpan())
+	if len(mine) == 0 {
+		return false
+	}
+	theirs := other.Occurrences(mine[0].StartAt, mine[len(mine)-1].EndAt)
+
+	for i, j := 0, 0; i < len(mine) && j < len(theirs); {
+		if mine[i].Overlaps(theirs[j].StartAt, theirs[j].EndAt) {
+			return true
+		}
+		if mine[i].EndAt.Before(theirs[j].EndAt) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return false
+}
+
+// Exclude returns the event with the occurrence starting at start excluded.
+func (e Event) Exclude(start time.Time) Event {
+	e.ExDates = append(slices.Clone(e.ExDates), start)
+	return e.Normalize()
+}
+
+func (e Event) excluded(start time.Time) bool {
+	return slices.ContainsFunc(e.ExDates, start.Equal)
+}
+
+// SortEvents orders events by start time, then by ID.
+func SortEvents(events []Event) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].StartAt.Equal(events[j].StartAt) {
+			return events[i].ID < events[j].ID
+		}
+		return events[i].StartAt.Before(events[j].StartAt)
+	})
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Channel is a way reminders are delivered, the sender has a sink of the same name for each.
+type Channel string
+
+const (
+	ChannelLog     Channel = "log"
+	ChannelWebhook Channel = "webhook"
+	ChannelEmail   Channel = "email"
+)
+
+// Reminder notifies recipients of the event or each of its occurrences Before they start
+// through the Channel.
+type Reminder struct {
+	Before  time.Duration
+	Channel Channel
+}
+
+func (r Reminder) validate() error {
+	if r.Before < 0 {
+		return fmt.Errorf("%w: reminder must not be after the start", ErrInvalidEvent)
+	}
+	switch r.Channel {
+	case ChannelLog, ChannelWebhook, ChannelEmail:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown reminder channel %q; supported: %s, %s, %s",
+			ErrInvalidEvent, r.Channel, ChannelLog, ChannelWebhook, ChannelEmail)
+	}
+}
+
+// normalizeReminders sorts reminders from the earliest and drops duplicates.
+func normalizeReminders(reminders []Reminder) []Reminder {
+	if len(reminders) == 0 {
+		return nil
+	}
+	reminders = slices.Clone(reminders)
+	sort.Slice(reminders, func(i, j int) bool {
+		if reminders[i].Before != reminders[j].Before {
+			return reminders[i].Before > reminders[j].Before
+		}
+		return reminders[i].Channel < reminders[j].Channel
+	})
+	return slices.Compact(reminders)
+}
+
+// AllReminders returns Reminders and the reminder of NotifyBefore, which has no channel:
+// the sender delivers it through every sink it has.
+func (e Event) AllReminders() []Reminder {
+	if e.NotifyBefore <= 0 {
+		return e.Reminders
+	}
+	return append([]Reminder{{Before: e.NotifyBefore}}, e.Reminders...)
+}
+
+// DueReminder is a reminder of an event or of one of its occurrences.
+type DueReminder struct {
+	Event    Event
+	Reminder Reminder
+}
+
+// NotifyAt returns the moment the reminder fires.
+func (d DueReminder) NotifyAt() time.Time {
+	return d.Event.StartAt.Add(-d.Reminder.Before)
+}
+
+// DueReminders returns reminders of the event and its occurrences firing within [from, to).
+func (e Event) DueReminders(from, to time.Time) []DueReminder {
+	var due []DueReminder
+	for _, r := range e.AllReminders() {
+		for _, o := range e.Occurrences(from.Add(r.Before), to.Add(r.Before)) {
+			d := DueReminder{Event: o, Reminder: r}
+			if !d.NotifyAt().Before(from) && d.NotifyAt().Before(to) {
+				due = append(due, d)
+			}
+		}
+	}
+	return due
+}
+
+// SortDueReminders orders reminders by the moment they fire, then like SortEvents.
+func SortDueReminders(due []DueReminder) {
+	sort.SliceStable(due, func(i, j int) bool {
+		if ti, tj := due[i].NotifyAt(), due[j].NotifyAt(); !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		if si, sj := due[i].Event.StartAt, due[j].Event.StartAt; !si.Equal(sj) {
+			return si.Before(sj)
+		}
+		if due[i].Event.ID != due[j].Event.ID {
+			return due[i].Event.ID < due[j].Event.ID
+		}
+		return due[i].Reminder.Channel < due[j].Reminder.Channel
+	})
+}
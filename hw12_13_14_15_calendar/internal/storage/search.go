@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Weights of search terms found in event fields, the defaults of Postgres ts_rank
+// for the A and B weight labels.
+const (
+	TitleWeight       = 1.0
+	DescriptionWeight = 0.4
+)
+
+// SearchQuery selects events the user owns or attends whose title or description
+// contain every term of Text, see SearchTerms.
+type SearchQuery struct {
+	UserID string
+	Text   string
+	// OwnerID selects only events owned by that user, if set.
+	OwnerID string
+	// From and To select only events active within [From, To), if set.
+	// Recurring events are returned as stored, not expanded.
+	From, To time.Time
+	// After selects only hits ordered after it, see SortHits.
+	After *SearchCursor
+	// Limit is the maximum number of hits, zero means no limit.
+	Limit int
+}
+
+// SearchHit is an event found by a search with its rank, the higher the more relevant.
+// Ranks are comparable only among hits of the same storage.
+type SearchHit struct {
+	Event Event
+	Rank  float64
+}
+
+// SearchCursor is the position of a hit in the order of SortHits.
+type SearchCursor struct {
+	Rank    float64
+	StartAt time.Time
+	ID      string
+}
+
+func (h SearchHit) Cursor() SearchCursor {
+	return SearchCursor{Rank: h.Rank, StartAt: h.Event.StartAt, ID: h.Event.ID}
+}
+
+// Before reports whether the cursor is ordered before the hit.
+func (c SearchCursor) Before(h SearchHit) bool {
+	switch {
+	case c.Rank != h.Rank:
+		return c.Rank > h.Rank
+	case !c.StartAt.Equal(h.Event.StartAt):
+		return c.StartAt.Before(h.Event.StartAt)
+	}
+	return c.ID < h.Event.ID
+}
+
+// Selects reports whether the event matches the filters of the query other than Text and After.
+func (q SearchQuery) Selects(e Event) bool {
+	switch {
+	case !e.Involves(q.UserID):
+		return false
+	case q.OwnerID != "" && e.UserID != q.OwnerID:
+		return false
+	case !q.From.IsZero() && !e.ActiveWithin(q.From, q.To):
+		return false
+	}
+	return true
+}
+
+// SortHits orders hits by descending rank, then by start time and ID.
+func SortHits(hits []SearchHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].Cursor().Before(hits[j])
+	})
+}
+
+// SearchTerms splits text into lowercase words of letters and digits, the way the Postgres
+// "simple" text search configuration does, without duplicates.
+func SearchTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range words(text) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// TermWeights returns the search terms of the event with the sum of the weights
+// of their occurrences in its title and description.
+func (e Event) TermWeights() map[string]float64 {
+	weights := make(map[string]float64)
+	for _, word := range words(e.Title) {
+		weights[word] += TitleWeight
+	}
+	for _, word := range words(e.Description) {
+		weights[word] += DescriptionWeight
+	}
+	return weights
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isNotWordRune)
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
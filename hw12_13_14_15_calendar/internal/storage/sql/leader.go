@@ -0,0 +1,88 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LeaderLock is a session advisory lock, held by one session of the database at a time.
+// Its key is a pair of integers: such keys never collide with the single key ones of inUserTx.
+type LeaderLock struct {
+	storage     *Storage
+	name        string
+	idleTimeout time.Duration
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewLeaderLock returns the lock of the given name. The session holding it is ended by the server,
+// releasing the lock, once idle for idleTimeout, so that a leader lost with its connection is not
+// waited for longer than that. It must exceed the interval of checks; PostgreSQL 14+ is required.
+func (s *Storage) NewLeaderLock(name string, idleTimeout time.Duration) *LeaderLock {
+	return &LeaderLock{storage: s, name: name, idleTimeout: idleTimeout}
+}
+
+// TryAcquire takes a connection out of the pool and locks the name in its session.
+func (l *LeaderLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		return true, nil
+	}
+
+	conn, err := l.storage.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, err = conn.ExecContext(ctx, `SELECT set_config('idle_session_timeout', $1, false)`,
+		strconv.FormatInt(l.idleTimeout.Milliseconds(), 10))
+	if err != nil {
+		discard(conn)
+		return false, err
+	}
+	var acquired bool
+	err = conn.QueryRowContext(ctx,
+		`SELECT pg_try_advisory_lock(hashtext('leader'), hashtext($1))`, l.name).Scan(&acquired)
+	if err != nil || !acquired {
+		discard(conn)
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Check queries the session holding the lock, which also keeps it from being idle.
+func (l *LeaderLock) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return errors.New("lock is not held")
+	}
+	_, err := l.conn.ExecContext(ctx, `SELECT 1`)
+	return err
+}
+
+// Release ends the session holding the lock.
+func (l *LeaderLock) Release(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	discard(l.conn)
+	l.conn = nil
+	return nil
+}
+
+// discard closes the connection instead of returning it to the pool with the session settings
+// and locks it has.
+func discard(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn }) //nolint:errcheck
+	conn.Close()
+}
@@ -0,0 +1,45 @@
+package sqlstorage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderLock(t *testing.T) {
+	ctx := context.Background()
+	setTimeout := q("SELECT set_config('idle_session_timeout', $1, false)")
+	tryLock := q("SELECT pg_try_advisory_lock(hashtext('leader'), hashtext($1))")
+
+	t.Run("acquire, check and release", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectExec(setTimeout).WithArgs("20000").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(tryLock).WithArgs("scheduler").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+		mock.ExpectExec(q("SELECT 1")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		l := s.NewLeaderLock("scheduler", 20*time.Second)
+		acquired, err := l.TryAcquire(ctx)
+		require.NoError(t, err)
+		require.True(t, acquired)
+		require.NoError(t, l.Check(ctx))
+		require.NoError(t, l.Release(ctx))
+		require.Error(t, l.Check(ctx))
+		require.Zero(t, s.db.Stats().OpenConnections, "the session is ended, not pooled")
+	})
+
+	t.Run("held by another session", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectExec(setTimeout).WithArgs("20000").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(tryLock).WithArgs("scheduler").
+			WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+		acquired, err := s.NewLeaderLock("scheduler", 20*time.Second).TryAcquire(ctx)
+		require.NoError(t, err)
+		require.False(t, acquired)
+		require.Zero(t, s.db.Stats().OpenConnections)
+	})
+}
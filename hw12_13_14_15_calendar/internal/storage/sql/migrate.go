@@ -0,0 +1,73 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+var ErrUnknownMigrateCommand = errors.New("unknown migrate command; supported: up, down, status")
+
+// Migrate applies (up), rolls back the last (down) or reports (status) schema migrations,
+// writing a human-readable result to out.
+func Migrate(ctx context.Context, dsn, command string, out io.Writer) error {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("create migration provider: %w", err)
+	}
+
+	switch command {
+	case "up":
+		results, err := provider.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(out, "no migrations to apply")
+		}
+		for _, r := range results {
+			fmt.Fprintf(out, "applied %s (%s)\n", r.Source.Path, r.Duration.Round(time.Millisecond))
+		}
+	case "down":
+		result, err := provider.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Fprintln(out, "no migrations to roll back")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %s (%s)\n", result.Source.Path, result.Duration.Round(time.Millisecond))
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+		for _, st := range statuses {
+			appliedAt := "-"
+			if st.State == goose.StateApplied {
+				appliedAt = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", st.Source.Version, st.State, appliedAt, st.Source.Path)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownMigrateCommand, command)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package sqlstorage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+)
+
+// eventRow is a database representation of storage.Event.
+type eventRow struct {
+	ID           string         `db:"id"`
+	Title        string         `db:"title"`
+	StartAt      time.Time      `db:"start_at"`
+	EndAt        time.Time      `db:"end_at"`
+	Description  string         `db:"description"`
+	UserID       string         `db:"user_id"`
+	NotifyBefore int64          `db:"notify_before"`
+	RRule        string         `db:"rrule"`
+	ExDates      timeList       `db:"exdates"`
+	SeriesID     sql.NullString `db:"series_id"`
+	RecurrenceID sql.NullTime   `db:"recurrence_id"`
+	UID          string         `db:"uid"`
+	TimeZone     string         `db:"time_zone"`
+	Attendees    attendeeList   `db:"attendees"`
+	Reminders    reminderList   `db:"reminders"`
+	Version      int64          `db:"version"`
+}
+
+func toRow(e storage.Event) eventRow {
+	return eventRow{
+		ID:           e.ID,
+		Title:        e.Title,
+		StartAt:      e.StartAt,
+		EndAt:        e.EndAt,
+		Description:  e.Description,
+		UserID:       e.UserID,
+		NotifyBefore: int64(e.NotifyBefore),
+		RRule:        e.RRule,
+		ExDates:      e.ExDates,
+		SeriesID:     sql.NullString{String: e.SeriesID, Valid: e.SeriesID != ""},
+		RecurrenceID: sql.NullTime{Time: e.RecurrenceID, Valid: !e.RecurrenceID.IsZero()},
+		UID:          e.UID,
+		TimeZone:     e.TimeZone,
+		Attendees:    e.Attendees,
+		Reminders:    e.Reminders,
+		Version:      e.Version,
+	}
+}
+
+func (r eventRow) toEvent() storage.Event {
+	return storage.Event{
+		ID:           r.ID,
+		Title:        r.Title,
+		StartAt:      r.StartAt,
+		EndAt:        r.EndAt,
+		Description:  r.Description,
+		UserID:       r.UserID,
+		NotifyBefore: time.Duration(r.NotifyBefore),
+		RRule:        r.RRule,
+		ExDates:      r.ExDates,
+		SeriesID:     r.SeriesID.String,
+		RecurrenceID: r.RecurrenceID.Time,
+		UID:          r.UID,
+		TimeZone:     r.TimeZone,
+		Attendees:    r.Attendees,
+		Reminders:    r.Reminders,
+		Version:      r.Version,
+	}.Normalize()
+}
+
+func toEvents(rows []eventRow) []storage.Event {
+	events := make([]storage.Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, row.toEvent())
+	}
+	return events
+}
+
+// searchRow is an event found by a search with its rank.
+type searchRow struct {
+	eventRow
+	Rank float64 `db:"rank"`
+}
+
+func (r searchRow) toHit() storage.SearchHit {
+	return storage.SearchHit{Event: r.toEvent(), Rank: r.Rank}
+}
+
+// timeList is stored as a JSON array of timestamps.
+type timeList []time.Time
+
+func (l timeList) Value() (driver.Value, error) {
+	if l == nil {
+		l = timeList{}
+	}
+	b, err := json.Marshal([]time.Time(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *timeList) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*l = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into time list", src)
+	}
+	return json.Unmarshal(b, (*[]time.Time)(l))
+}
+
+// attendeeList is stored as a JSON array of objects with user_id and status,
+// so that events of an attendee are found with the @> operator.
+type attendeeList []storage.Attendee
+
+type attendeeJSON struct {
+	UserID string             `json:"user_id"`
+	Status storage.RSVPStatus `json:"status"`
+}
+
+func (l attendeeList) Value() (driver.Value, error) {
+	attendees := make([]attendeeJSON, 0, len(l))
+	for _, a := range l {
+		attendees = append(attendees, attendeeJSON(a))
+	}
+	b, err := json.Marshal(attendees)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *attendeeList) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*l = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into attendee list", src)
+	}
+	var attendees []attendeeJSON
+	if err := json.Unmarshal(b, &attendees); err != nil {
+		return err
+	}
+	*l = make(attendeeList, 0, len(attendees))
+	for _, a := range attendees {
+		*l = append(*l, storage.Attendee(a))
+	}
+	return nil
+}
+
+// reminderList is stored as a JSON array of objects with before in nanoseconds and channel.
+type reminderList []storage.Reminder
+
+type reminderJSON struct {
+	Before  int64           `json:"before"`
+	Channel storage.Channel `json:"channel"`
+}
+
+func (l reminderList) Value() (driver.Value, error) {
+	reminders := make([]reminderJSON, 0, len(l))
+	for _, r := range l {
+		reminders = append(reminders, reminderJSON{Before: int64(r.Before), Channel: r.Channel})
+	}
+	b, err := json.Marshal(reminders)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *reminderList) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*l = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into reminder list", src)
+	}
+	var reminders []reminderJSON
+	if err := json.Unmarshal(b, &reminders); err != nil {
+		return err
+	}
+	*l = make(reminderList, 0, len(reminders))
+	for _, r := range reminders {
+		*l = append(*l, storage.Reminder{Before: time.Duration(r.Before), Channel: r.Channel})
+	}
+	return nil
+}
@@ -1,20 +1,840 @@
 package sqlstorage
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
-type Storage struct { // TODO
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers "pgx" driver.
+	"github.com/jmoiron/sqlx"
+)
+
+const driverName = "pgx"
+
+var ErrNotConnected = errors.New("storage is not connected")
+
+// uniqueViolationCode is the SQLSTATE of unique constraint violations.
+const uniqueViolationCode = "23505"
+
+const eventColumns = "id, title, start_at, end_at, description, user_id, notify_before, " +
+	"rrule, exdates, series_id, recurrence_id, uid, time_zone, attendees, reminders, version"
+
+// Conditions selecting events of the user $1: the ones the user owns, or also attends.
+const (
+	ownedBy   = `user_id = $1`
+	involving = `(user_id = $1 OR attendees @> jsonb_build_array(jsonb_build_object('user_id', $1::text)))`
+)
+
+type Storage struct {
+	dsn            string
+	poolSize       int
+	connectTimeout time.Duration
+	db             *sqlx.DB
 }
 
-func New() *Storage {
-	return &Storage{}
+// New creates a storage that keeps at most poolSize open connections
+// and gives up connecting after connectTimeout. Zero values mean no limit.
+func New(dsn string, poolSize int, connectTimeout time.Duration) *Storage {
+	return &Storage{dsn: dsn, poolSize: poolSize, connectTimeout: connectTimeout}
 }
 
 func (s *Storage) Connect(ctx context.Context) error {
-	// TODO
+	if s.connectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.connectTimeout)
+		defer cancel()
+	}
+
+	db, err := sqlx.ConnectContext(ctx, driverName, s.dsn)
+	if err != nil {
+		return fmt.Errorf("connect to database: %w", err)
+	}
+	db.SetMaxOpenConns(s.poolSize)
+	db.SetMaxIdleConns(s.poolSize)
+	s.db = db
 	return nil
 }
 
 func (s *Storage) Close(ctx context.Context) error {
-	// TODO
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Ping checks that the database is reachable. It fails with ErrNotConnected before Connect.
+func (s *Storage) Ping(ctx context.Context) error {
+	if s.db == nil {
+		return ErrNotConnected
+	}
+	return s.db.PingContext(ctx)
+}
+
+// CreateEvent stores a new event under a generated ID and returns it.
+// The ID becomes the UID of the event unless it has one.
+func (s *Storage) CreateEvent(ctx context.Context, e storage.Event) (storage.Event, error) {
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	e = e.Normalize()
+	e.ID = uuid.NewString()
+	e.Version = 1
+	if e.UID == "" {
+		e.UID = e.ID
+	}
+
+	err := s.inUserTx(ctx, e.UserID, func(tx *sqlx.Tx) error {
+		if err := checkBusy(ctx, tx, e); err != nil {
+			return err
+		}
+		if err := insertEvent(ctx, tx, e); err != nil {
+			return err
+		}
+		return logChange(ctx, tx, e.ID, e)
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+// UpdateEvent replaces the event with the given ID and returns the stored version.
+func (s *Storage) UpdateEvent(ctx context.Context, id string, e storage.Event) (storage.Event, error) {
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	if !isUUID(id) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	e = e.Normalize()
+	e.ID = id
+
+	err := s.inUserTx(ctx, e.UserID, func(tx *sqlx.Tx) error {
+		stored, err := getEventForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if e.Version, err = e.NextVersion(stored); err != nil {
+			return err
+		}
+		e.SeriesID, e.RecurrenceID, e.UID = stored.SeriesID, stored.RecurrenceID, stored.UID
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		if err := checkBusy(ctx, tx, e); err != nil {
+			return err
+		}
+		if err := updateEvent(ctx, tx, e); err != nil {
+			return err
+		}
+		return logChange(ctx, tx, id, stored, e)
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+// DeleteEvent deletes the event, a recurring one together with its edited occurrences.
+func (s *Storage) DeleteEvent(ctx context.Context, id string) error {
+	if !isUUID(id) {
+		return storage.ErrNotFound
+	}
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		deleted, err := deleteEvent(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return storage.ErrNotFound
+		}
+		return logDeleted(ctx, tx, deleted)
+	})
+}
+
+// ExcludeOccurrence deletes the occurrence of the recurring event starting at start.
+// A non-zero version must equal the version of the series.
+func (s *Storage) ExcludeOccurrence(ctx context.Context, id string, start time.Time, version int64) error {
+	series, err := s.GetEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.inUserTx(ctx, series.UserID, func(tx *sqlx.Tx) error {
+		series, err := getEventForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !series.HasOccurrence(start) {
+			return storage.ErrNotFound
+		}
+		if _, err := (storage.Event{Version: version}).NextVersion(series); err != nil {
+			return err
+		}
+		if err := updateExDates(ctx, tx, series.Exclude(start)); err != nil {
+			return err
+		}
+		return logChange(ctx, tx, id, series)
+	})
+}
+
+// DetachOccurrence replaces the occurrence of the recurring event starting at start
+// with e, a one-off event linked to the series, and returns it. The occurrence may be
+// a deleted one; an already edited occurrence is replaced keeping its ID. A non-zero
+// e.Version must equal the version of the series.
+func (s *Storage) DetachOccurrence(
+	ctx context.Context, id string, start time.Time, e storage.Event,
+) (storage.Event, error) {
+	e.SeriesID, e.RecurrenceID = id, start
+	if err := e.Validate(); err != nil {
+		return storage.Event{}, err
+	}
+	series, err := s.GetEvent(ctx, id)
+	if err != nil {
+		return storage.Event{}, err
+	}
+	e = e.Normalize()
+
+	err = s.inUserTx(ctx, series.UserID, func(tx *sqlx.Tx) error {
+		series, err := getEventForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !series.RecursAt(start) {
+			return storage.ErrNotFound
+		}
+		if _, err := e.NextVersion(series); err != nil {
+			return err
+		}
+		e.UID = series.UID
+		edited, ok, err := getEdited(ctx, tx, id, e.RecurrenceID)
+		if err != nil {
+			return err
+		}
+		excluded := series.HasOccurrence(start)
+		if excluded {
+			if err := updateExDates(ctx, tx, series.Exclude(start)); err != nil {
+				return err
+			}
+		}
+
+		e.ID, e.Version = edited.ID, edited.Version+1
+		if !ok {
+			e.ID = uuid.NewString()
+		}
+		if err := checkBusy(ctx, tx, e); err != nil {
+			return err
+		}
+		if ok {
+			err = updateEvent(ctx, tx, e)
+		} else {
+			err = insertEvent(ctx, tx, e)
+		}
+		if err != nil {
+			return err
+		}
+		if excluded {
+			if err := logChange(ctx, tx, id, series); err != nil {
+				return err
+			}
+		}
+		return logChange(ctx, tx, e.ID, edited, e)
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+// RespondToEvent sets the RSVP status of the attendee of the event and returns the event.
+// The event is not found for users who do not attend it.
+func (s *Storage) RespondToEvent(
+	ctx context.Context, id, userID string, status storage.RSVPStatus,
+) (storage.Event, error) {
+	if !status.Valid() {
+		return storage.Event{}, fmt.Errorf("%w: unknown RSVP status %q", storage.ErrInvalidEvent, status)
+	}
+	if !isUUID(id) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+
+	var e storage.Event
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		stored, err := getEventForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if e, ok = stored.Respond(userID, status); !ok {
+			return storage.ErrNotFound
+		}
+		e.Version++
+		_, err = tx.ExecContext(ctx, `UPDATE events SET attendees = $2, version = $3 WHERE id = $1`,
+			id, attendeeList(e.Attendees), e.Version)
+		if err != nil {
+			return err
+		}
+		return logChange(ctx, tx, id, e)
+	})
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return e, nil
+}
+
+func (s *Storage) GetEvent(ctx context.Context, id string) (storage.Event, error) {
+	if !isUUID(id) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	var row eventRow
+	err := s.db.GetContext(ctx, &row, `SELECT `+eventColumns+` FROM events WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return row.toEvent(), nil
+}
+
+// GetEventByUID returns user's event with the given UID. A non-zero recurrenceID
+// selects the edited occurrence of the recurring event starting at that time.
+func (s *Storage) GetEventByUID(
+	ctx context.Context, userID, uid string, recurrenceID time.Time,
+) (storage.Event, error) {
+	var row eventRow
+	err := s.db.GetContext(ctx, &row,
+		`SELECT `+eventColumns+` FROM events
+		WHERE user_id = $1 AND uid = $2 AND recurrence_id IS NOT DISTINCT FROM $3`,
+		userID, uid, sql.NullTime{Time: recurrenceID, Valid: !recurrenceID.IsZero()})
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return row.toEvent(), nil
+}
+
+// ListConflicts returns other events of the user of e whose occurrences intersect occurrences of e
+// within its BusySpan, ordered by start time.
+func (s *Storage) ListConflicts(ctx context.Context, e storage.Event) ([]storage.Event, error) {
+	conflicts, err := selectConflicts(ctx, s.db, e)
+	if err != nil {
+		return nil, err
+	}
+	storage.SortEvents(conflicts)
+	return conflicts, nil
+}
+
+// ListEvents returns user's events active within [from, to) ordered by start time.
+// Unlike the other listings, recurring events are returned as stored, not expanded.
+func (s *Storage) ListEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	candidates, err := s.selectEvents(ctx, ownedBy, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]storage.Event, 0, len(candidates))
+	for _, e := range candidates {
+		if e.ActiveWithin(from, to) {
+			events = append(events, e)
+		}
+	}
+	storage.SortEvents(events)
+	return events, nil
+}
+
+func (s *Storage) ListDayEvents(ctx context.Context, userID string, date time.Time) ([]storage.Event, error) {
+	from, to := storage.DayRange(date)
+	return s.listEvents(ctx, userID, from, to)
+}
+
+func (s *Storage) ListWeekEvents(ctx context.Context, userID string, weekStart time.Time) ([]storage.Event, error) {
+	from, to := storage.WeekRange(weekStart)
+	return s.listEvents(ctx, userID, from, to)
+}
+
+func (s *Storage) ListMonthEvents(ctx context.Context, userID string, monthStart time.Time) ([]storage.Event, error) {
+	from, to := storage.MonthRange(monthStart)
+	return s.listEvents(ctx, userID, from, to)
+}
+
+// ListDueReminders returns reminders of events and their occurrences firing within [from, to)
+// ordered by the moment they fire. Recurring events are selected by their first occurrence
+// and expanded here.
+func (s *Storage) ListDueReminders(ctx context.Context, from, to time.Time) ([]storage.DueReminder, error) {
+	var rows []eventRow
+	err := s.db.SelectContext(ctx, &rows,
+		`SELECT `+eventColumns+` FROM events
+		WHERE EXISTS (
+			SELECT 1 FROM (
+				SELECT notify_before AS before WHERE notify_before > 0
+				UNION ALL
+				SELECT (r->>'before')::bigint FROM jsonb_array_elements(reminders) r
+			) reminder
+			WHERE start_at - reminder.before / 1000 * interval '1 microsecond' < $2
+				AND (rrule <> '' OR start_at - reminder.before / 1000 * interval '1 microsecond' >= $1))`,
+		from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	due := make([]storage.DueReminder, 0, len(rows))
+	for _, e := range toEvents(rows) {
+		due = append(due, e.DueReminders(from, to)...)
+	}
+	storage.SortDueReminders(due)
+	return due, nil
+}
+
+// EnqueueNotifications adds notifications to the outbox unless they were added before
+// and returns the number of added ones. Either all of them are added or none.
+func (s *Storage) EnqueueNotifications(ctx context.Context, ns []storage.Notification, at time.Time) (int, error) {
+	added := 0
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		for _, n := range ns {
+			payload, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			res, err := tx.ExecContext(ctx,
+				`INSERT INTO notification_outbox (id, payload, enqueued_at) VALUES ($1, $2, $3)
+				ON CONFLICT (id) DO NOTHING`, n.ID, payload, at)
+			if err != nil {
+				return err
+			}
+			inserted, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			added += int(inserted)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// ClaimPendingNotifications claims up to limit notifications of the outbox not published yet
+// for the claimer and returns them in the order they were added. Notifications claimed by others
+// are skipped unless their claim was made before staleBefore, and so are rows locked by a concurrent claim.
+func (s *Storage) ClaimPendingNotifications(
+	ctx context.Context, claimer string, at, staleBefore time.Time, limit int,
+) ([]storage.Notification, error) {
+	var payloads [][]byte
+	err := s.db.SelectContext(ctx, &payloads,
+		`WITH claimed AS (
+			UPDATE notification_outbox SET claimed_by = $1, claimed_at = $2
+			WHERE id IN (
+				SELECT id FROM notification_outbox
+				WHERE published_at IS NULL AND (claimed_by IS NULL OR claimed_by = $1 OR claimed_at < $3)
+				ORDER BY enqueued_at, id LIMIT $4
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, payload, enqueued_at
+		)
+		SELECT payload FROM claimed ORDER BY enqueued_at, id`, claimer, at, staleBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := make([]storage.Notification, 0, len(payloads))
+	for _, payload := range payloads {
+		var n storage.Notification
+		if err := json.Unmarshal(payload, &n); err != nil {
+			return nil, fmt.Errorf("decode notification: %w", err)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+// MarkNotificationPublished keeps the notification in the outbox, but no longer lists it as pending.
+func (s *Storage) MarkNotificationPublished(ctx context.Context, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE notification_outbox SET published_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return storage.ErrNotFound
+	}
 	return nil
 }
+
+// CompactOutbox drops published notifications added before the given moment and returns their number.
+func (s *Storage) CompactOutbox(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM notification_outbox WHERE published_at IS NOT NULL AND enqueued_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteEventsEndedBefore deletes events whose last occurrence ended before the given moment
+// and returns their number, counting edited occurrences deleted with their recurring events.
+func (s *Storage) DeleteEventsEndedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var count int64
+	err := s.inTx(ctx, func(tx *sqlx.Tx) error {
+		var deleted []eventRow
+		if err := tx.SelectContext(ctx, &deleted,
+			`DELETE FROM events WHERE rrule = '' AND end_at < $1 RETURNING `+eventColumns, before); err != nil {
+			return err
+		}
+
+		var rows []eventRow
+		if err := tx.SelectContext(ctx, &rows,
+			`SELECT `+eventColumns+` FROM events WHERE rrule <> '' AND start_at < $1`, before); err != nil {
+			return err
+		}
+		for _, e := range toEvents(rows) {
+			if end, ok := e.LastEnd(); !ok || !end.Before(before) {
+				continue
+			}
+			series, err := deleteEvent(ctx, tx, e.ID)
+			if err != nil {
+				return err
+			}
+			deleted = append(deleted, series...)
+		}
+		count = int64(len(deleted))
+		return logDeleted(ctx, tx, deleted)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ListChanges returns changes of the events the user owns or attends after the seq position
+// of the change log, or all of the events when seq is zero. It fails with ErrChangesCompacted
+// when the changes are no longer logged.
+func (s *Storage) ListChanges(ctx context.Context, userID string, seq int64) (storage.Changes, error) {
+	// The last position is read first: changes are committed in seq order, see logChange,
+	// and events changed after it are returned again next time.
+	var last int64
+	err := s.db.GetContext(ctx, &last,
+		`SELECT GREATEST(COALESCE(MAX(seq), 0), (SELECT seq FROM event_changes_compacted)) FROM event_changes`)
+	if err != nil {
+		return storage.Changes{}, err
+	}
+
+	var rows []eventRow
+	if seq == 0 {
+		if err := s.db.SelectContext(ctx, &rows, `SELECT `+eventColumns+` FROM events WHERE `+involving, userID); err != nil {
+			return storage.Changes{}, err
+		}
+		return storage.NewChanges(userID, nil, toEvents(rows), last), nil
+	}
+	if seq > last {
+		return storage.Changes{}, storage.ErrChangesCompacted
+	}
+
+	const changedEvents = `SELECT event_id FROM event_changes WHERE user_id = $1 AND seq > $2 AND seq <= $3`
+	var changed []string
+	if err := s.db.SelectContext(ctx, &changed, changedEvents+` GROUP BY event_id`, userID, seq, last); err != nil {
+		return storage.Changes{}, err
+	}
+	if err := s.db.SelectContext(ctx, &rows,
+		`SELECT `+eventColumns+` FROM events WHERE id IN (`+changedEvents+`)`, userID, seq, last); err != nil {
+		return storage.Changes{}, err
+	}
+	// Compaction is checked last, changes compacted while being read are not returned as complete.
+	var compacted int64
+	if err := s.db.GetContext(ctx, &compacted, `SELECT seq FROM event_changes_compacted`); err != nil {
+		return storage.Changes{}, err
+	}
+	if seq < compacted {
+		return storage.Changes{}, storage.ErrChangesCompacted
+	}
+	return storage.NewChanges(userID, changed, toEvents(rows), last), nil
+}
+
+// CompactChanges removes changes logged before the given moment and returns their number.
+func (s *Storage) CompactChanges(ctx context.Context, before time.Time) (int64, error) {
+	var compacted int64
+	err := s.db.GetContext(ctx, &compacted,
+		`WITH deleted AS (DELETE FROM event_changes WHERE changed_at < $1 RETURNING seq)
+		UPDATE event_changes_compacted SET seq = GREATEST(seq, (SELECT COALESCE(MAX(seq), 0) FROM deleted))
+		RETURNING (SELECT COUNT(*) FROM deleted)`,
+		before)
+	return compacted, err
+}
+
+// SearchEvents returns events selected by the query ordered by storage.SortHits. Events are ranked
+// by ts_rank of the query terms in their search vector of weighted title and description words.
+func (s *Storage) SearchEvents(ctx context.Context, q storage.SearchQuery) ([]storage.SearchHit, error) {
+	terms := storage.SearchTerms(q.Text)
+	if len(terms) == 0 {
+		return []storage.SearchHit{}, nil
+	}
+
+	// Recurring events started before the end of the range are filtered here,
+	// so pages of rows are selected until the limit is reached.
+	hits := make([]storage.SearchHit, 0)
+	for after := q.After; ; {
+		rows, err := s.selectHits(ctx, q, terms, after)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			hit := row.toHit()
+			if !q.Selects(hit.Event) {
+				continue
+			}
+			if hits = append(hits, hit); len(hits) == q.Limit {
+				return hits, nil
+			}
+		}
+		if q.Limit == 0 || len(rows) < q.Limit {
+			return hits, nil
+		}
+		last := rows[len(rows)-1].toHit().Cursor()
+		after = &last
+	}
+}
+
+// selectHits selects at most q.Limit rows of events matching the terms and the query filters
+// after the cursor. Recurring events are selected by their first occurrence.
+func (s *Storage) selectHits(
+	ctx context.Context, q storage.SearchQuery, terms []string, after *storage.SearchCursor,
+) ([]searchRow, error) {
+	const rank = `ts_rank(search, query)`
+	query := `SELECT ` + eventColumns + `, ` + rank + ` AS rank
+		FROM events, plainto_tsquery('simple', $2) query
+		WHERE ` + involving + ` AND search @@ query`
+	args := []interface{}{q.UserID, strings.Join(terms, " ")}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if q.OwnerID != "" {
+		query += ` AND user_id = ` + arg(q.OwnerID)
+	}
+	if !q.From.IsZero() {
+		query += ` AND start_at < ` + arg(q.To) + ` AND (end_at > ` + arg(q.From) + ` OR rrule <> '')`
+	}
+	if after != nil {
+		r := arg(after.Rank) + `::real`
+		query += ` AND (` + rank + ` < ` + r + ` OR ` + rank + ` = ` + r +
+			` AND (start_at, id) > (` + arg(after.StartAt) + `, ` + arg(after.ID) + `::uuid))`
+	}
+	query += ` ORDER BY rank DESC, start_at, id`
+	if q.Limit > 0 {
+		query += ` LIMIT ` + arg(q.Limit)
+	}
+
+	var rows []searchRow
+	if err := s.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// listEvents returns occurrences of events the user owns or attends intersecting [from, to)
+// ordered by start time.
+func (s *Storage) listEvents(ctx context.Context, userID string, from, to time.Time) ([]storage.Event, error) {
+	candidates, err := s.selectEvents(ctx, involving, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]storage.Event, 0, len(candidates))
+	for _, e := range candidates {
+		events = append(events, e.Occurrences(from, to)...)
+	}
+	storage.SortEvents(events)
+	return events, nil
+}
+
+// selectEvents selects events of the user matching the users condition that intersect [from, to)
+// and recurring ones started before to.
+func (s *Storage) selectEvents(
+	ctx context.Context, users, userID string, from, to time.Time,
+) ([]storage.Event, error) {
+	var rows []eventRow
+	err := s.db.SelectContext(ctx, &rows,
+		`SELECT `+eventColumns+` FROM events
+		WHERE `+users+` AND start_at < $3 AND (end_at > $2 OR rrule <> '')`,
+		userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return toEvents(rows), nil
+}
+
+// inUserTx runs fn in a transaction holding an advisory lock on the user,
+// so that concurrent busy checks of the same user are serialized.
+func (s *Storage) inUserTx(ctx context.Context, userID string, fn func(tx *sqlx.Tx) error) error {
+	return s.inTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, userID); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// inTx runs fn in a transaction committed if fn succeeds.
+func (s *Storage) inTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkBusy fails with storage.ErrDateBusy if another event of the same user conflicts with e,
+// unless overlaps are allowed by ctx.
+func checkBusy(ctx context.Context, tx *sqlx.Tx, e storage.Event) error {
+	if storage.OverlapAllowed(ctx) {
+		return nil
+	}
+	conflicts, err := selectConflicts(ctx, tx, e)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) > 0 {
+		return storage.ErrDateBusy
+	}
+	return nil
+}
+
+// selectConflicts selects other events of the same user conflicting with e.
+// Recurring events are selected by their first occurrence and expanded here.
+func selectConflicts(ctx context.Context, q sqlx.QueryerContext, e storage.Event) ([]storage.Event, error) {
+	from, to := e.BusySpan()
+	var rows []eventRow
+	err := sqlx.SelectContext(ctx, q, &rows,
+		`SELECT `+eventColumns+` FROM events
+		WHERE user_id = $1 AND id <> $2 AND start_at < $4 AND (end_at > $3 OR rrule <> '')`,
+		e.UserID, e.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([]storage.Event, 0)
+	for _, other := range toEvents(rows) {
+		if e.ConflictsWith(other) {
+			conflicts = append(conflicts, other)
+		}
+	}
+	return conflicts, nil
+}
+
+func insertEvent(ctx context.Context, tx *sqlx.Tx, e storage.Event) error {
+	_, err := tx.NamedExecContext(ctx,
+		`INSERT INTO events (`+eventColumns+`)
+		VALUES (:id, :title, :start_at, :end_at, :description, :user_id, :notify_before,
+			:rrule, :exdates, :series_id, :recurrence_id, :uid, :time_zone, :attendees, :reminders, :version)`,
+		toRow(e))
+	if isUniqueViolation(err) {
+		return storage.ErrUIDTaken
+	}
+	return err
+}
+
+// updateEvent updates fields of the event that may be changed after it is created.
+func updateEvent(ctx context.Context, tx *sqlx.Tx, e storage.Event) error {
+	_, err := tx.NamedExecContext(ctx,
+		`UPDATE events SET title = :title, start_at = :start_at, end_at = :end_at,
+			description = :description, user_id = :user_id, notify_before = :notify_before,
+			rrule = :rrule, exdates = :exdates, time_zone = :time_zone, attendees = :attendees,
+			reminders = :reminders, version = :version
+		WHERE id = :id`,
+		toRow(e))
+	return err
+}
+
+func getEventForUpdate(ctx context.Context, tx *sqlx.Tx, id string) (storage.Event, error) {
+	var row eventRow
+	err := tx.GetContext(ctx, &row, `SELECT `+eventColumns+` FROM events WHERE id = $1 FOR UPDATE`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, storage.ErrNotFound
+	}
+	if err != nil {
+		return storage.Event{}, err
+	}
+	return row.toEvent(), nil
+}
+
+// getEdited returns the edited occurrence of the series starting at start, if any.
+func getEdited(ctx context.Context, tx *sqlx.Tx, seriesID string, start time.Time) (storage.Event, bool, error) {
+	var row eventRow
+	err := tx.GetContext(ctx, &row,
+		`SELECT `+eventColumns+` FROM events WHERE series_id = $1 AND recurrence_id = $2 FOR UPDATE`, seriesID, start)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Event{}, false, nil
+	}
+	if err != nil {
+		return storage.Event{}, false, err
+	}
+	return row.toEvent(), true, nil
+}
+
+// deleteEvent deletes the event with its edited occurrences and returns them.
+func deleteEvent(ctx context.Context, tx *sqlx.Tx, id string) ([]eventRow, error) {
+	var deleted []eventRow
+	err := tx.SelectContext(ctx, &deleted,
+		`DELETE FROM events WHERE id = $1 OR series_id = $1 RETURNING `+eventColumns, id)
+	return deleted, err
+}
+
+// logChange logs the change of the event for the participants of its versions before and after
+// the change. The lock taken serializes writers of the change log until they commit, so changes
+// become visible in seq order; to keep it short, changes are logged last in transactions.
+func logChange(ctx context.Context, tx *sqlx.Tx, id string, versions ...storage.Event) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('event_changes'))`); err != nil {
+		return err
+	}
+	for _, userID := range storage.Participants(versions...) {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO event_changes (user_id, event_id) VALUES ($1, $2)`, userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func logDeleted(ctx context.Context, tx *sqlx.Tx, deleted []eventRow) error {
+	for _, e := range toEvents(deleted) {
+		if err := logChange(ctx, tx, e.ID, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateExDates stores exception dates of the series as its next version.
+func updateExDates(ctx context.Context, tx *sqlx.Tx, e storage.Event) error {
+	_, err := tx.ExecContext(ctx, `UPDATE events SET exdates = $2, version = version + 1 WHERE id = $1`,
+		e.ID, timeList(e.ExDates))
+	return err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
+
+func isUUID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
@@ -0,0 +1,236 @@
+package sqlstorage
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// testDSNEnv points to a disposable PostgreSQL database, e.g. one started with
+// docker run --rm -p 5432:5432 -e POSTGRES_PASSWORD=calendar postgres:16.
+const testDSNEnv = "CALENDAR_TEST_DSN"
+
+func newPostgresStorage(t *testing.T) *Storage {
+	t.Helper()
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	ctx := context.Background()
+	require.NoError(t, Migrate(ctx, dsn, "up", io.Discard))
+
+	s := New(dsn, 10, 5*time.Second)
+	require.NoError(t, s.Connect(ctx))
+	_, err := s.db.ExecContext(ctx, `TRUNCATE events, notification_outbox`)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close(ctx) })
+	return s
+}
+
+func TestStoragePostgres(t *testing.T) {
+	s := newPostgresStorage(t)
+	ctx := context.Background()
+
+	created, err := s.CreateEvent(ctx, newEvent())
+	require.NoError(t, err)
+
+	got, err := s.GetEvent(ctx, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, created, got)
+
+	overlapping := newEvent()
+	overlapping.StartAt = baseTime.Add(30 * time.Minute)
+	overlapping.EndAt = baseTime.Add(90 * time.Minute)
+	_, err = s.CreateEvent(ctx, overlapping)
+	require.ErrorIs(t, err, storage.ErrDateBusy)
+
+	upd := got
+	upd.Title = "updated"
+	updated, err := s.UpdateEvent(ctx, created.ID, upd)
+	require.NoError(t, err)
+	require.Equal(t, "updated", updated.Title)
+
+	next := newEvent()
+	next.StartAt = baseTime.AddDate(0, 0, 7)
+	next.EndAt = next.StartAt.Add(time.Hour)
+	_, err = s.CreateEvent(ctx, next)
+	require.NoError(t, err)
+
+	day, err := s.ListDayEvents(ctx, "u1", baseTime)
+	require.NoError(t, err)
+	require.Equal(t, []storage.Event{updated}, day)
+
+	month, err := s.ListMonthEvents(ctx, "u1", time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, month, 2)
+
+	due, err := s.ListDueReminders(ctx, baseTime.Add(-15*time.Minute), baseTime.Add(-14*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, []storage.DueReminder{{Event: updated, Reminder: storage.Reminder{Before: 15 * time.Minute}}}, due)
+
+	require.NoError(t, s.DeleteEvent(ctx, created.ID))
+	require.ErrorIs(t, s.DeleteEvent(ctx, created.ID), storage.ErrNotFound)
+	_, err = s.GetEvent(ctx, created.ID)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	series := newEvent()
+	series.StartAt, series.EndAt = baseTime.AddDate(0, 0, 1), baseTime.AddDate(0, 0, 1).Add(time.Hour)
+	series.RRule = "FREQ=DAILY;COUNT=2"
+	series, err = s.CreateEvent(ctx, series)
+	require.NoError(t, err)
+	moved := newEvent()
+	moved.StartAt, moved.EndAt = baseTime.AddDate(0, 0, 2).Add(3*time.Hour), baseTime.AddDate(0, 0, 2).Add(4*time.Hour)
+	_, err = s.DetachOccurrence(ctx, series.ID, baseTime.AddDate(0, 0, 2), moved)
+	require.NoError(t, err)
+
+	deleted, err := s.DeleteEventsEndedBefore(ctx, next.EndAt.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, int64(3), deleted, "with the edited occurrence")
+}
+
+func TestStoragePostgresConcurrentCreates(t *testing.T) {
+	s := newPostgresStorage(t)
+	ctx := context.Background()
+
+	wg := sync.WaitGroup{}
+	var mu sync.Mutex
+	var created int
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.CreateEvent(ctx, newEvent())
+			if err != nil {
+				require.ErrorIs(t, err, storage.ErrDateBusy)
+				return
+			}
+			mu.Lock()
+			created++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, created)
+}
+
+func TestStoragePostgresRecurrence(t *testing.T) {
+	s := newPostgresStorage(t)
+	ctx := context.Background()
+
+	e := newEvent()
+	e.RRule = "FREQ=DAILY;COUNT=3"
+	e.ExDates = []time.Time{baseTime.AddDate(0, 0, 2)}
+	series, err := s.CreateEvent(ctx, e)
+	require.NoError(t, err)
+
+	got, err := s.GetEvent(ctx, series.ID)
+	require.NoError(t, err)
+	require.Equal(t, series, got)
+
+	second := baseTime.AddDate(0, 0, 1)
+	moved := newEvent()
+	moved.StartAt, moved.EndAt = second.Add(2*time.Hour), second.Add(3*time.Hour)
+	detached, err := s.DetachOccurrence(ctx, series.ID, second, moved)
+	require.NoError(t, err)
+	require.Equal(t, series.ID, detached.SeriesID)
+
+	byUID, err := s.GetEventByUID(ctx, "u1", series.UID, second)
+	require.NoError(t, err)
+	require.Equal(t, detached, byUID)
+
+	week, err := s.ListWeekEvents(ctx, "u1", baseTime)
+	require.NoError(t, err)
+	require.Len(t, week, 2)
+	require.Equal(t, detached, week[1])
+
+	require.NoError(t, s.ExcludeOccurrence(ctx, series.ID, baseTime, 0))
+	require.ErrorIs(t, s.ExcludeOccurrence(ctx, series.ID, baseTime, 0), storage.ErrNotFound)
+
+	require.NoError(t, s.DeleteEvent(ctx, series.ID))
+	_, err = s.GetEvent(ctx, detached.ID)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestStoragePostgresSearch(t *testing.T) {
+	s := newPostgresStorage(t)
+	ctx := storage.WithOverlap(context.Background())
+
+	save := func(title, description string) storage.Event {
+		e := newEvent()
+		e.Title, e.Description = title, description
+		saved, err := s.CreateEvent(ctx, e)
+		require.NoError(t, err)
+		return saved
+	}
+	inDescription := save("Planning", "budget review for Q3")
+	inTitle := save("Budget review", "")
+	save("Budget", "")
+
+	hits, err := s.SearchEvents(ctx, storage.SearchQuery{UserID: "u1", Text: "review BUDGET"})
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	require.Equal(t, inTitle, hits[0].Event)
+	require.Equal(t, inDescription, hits[1].Event)
+	require.Greater(t, hits[0].Rank, hits[1].Rank)
+
+	after := hits[0].Cursor()
+	page, err := s.SearchEvents(ctx, storage.SearchQuery{UserID: "u1", Text: "review budget", After: &after, Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, hits[1:], page)
+
+	hits, err = s.SearchEvents(ctx, storage.SearchQuery{UserID: "u2", Text: "budget"})
+	require.NoError(t, err)
+	require.Empty(t, hits)
+}
+
+func TestStoragePostgresReminders(t *testing.T) {
+	s := newPostgresStorage(t)
+	ctx := context.Background()
+
+	e := newEvent()
+	e.NotifyBefore = 0
+	e.RRule = "FREQ=DAILY"
+	e.Reminders = []storage.Reminder{
+		{Before: 24 * time.Hour, Channel: storage.ChannelEmail},
+		{Before: 5 * time.Minute, Channel: storage.ChannelWebhook},
+	}
+	created, err := s.CreateEvent(ctx, e)
+	require.NoError(t, err)
+	require.Equal(t, e.Reminders, created.Reminders)
+
+	second := baseTime.AddDate(0, 0, 1)
+	due, err := s.ListDueReminders(ctx, baseTime.Add(-5*time.Minute), baseTime)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	require.Equal(t, storage.ChannelEmail, due[0].Reminder.Channel)
+	require.Equal(t, second, due[0].Event.StartAt)
+	require.Equal(t, storage.ChannelWebhook, due[1].Reminder.Channel)
+	require.Equal(t, baseTime, due[1].Event.StartAt)
+
+	n := storage.NewNotification(due[0], "u1")
+	added, err := s.EnqueueNotifications(ctx, []storage.Notification{n}, baseTime)
+	require.NoError(t, err)
+	require.Equal(t, 1, added)
+	added, err = s.EnqueueNotifications(ctx, []storage.Notification{n}, baseTime)
+	require.NoError(t, err)
+	require.Zero(t, added)
+	pending, err := s.ClaimPendingNotifications(ctx, "s1", baseTime, baseTime.Add(-time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, n.ID, pending[0].ID)
+	pending, err = s.ClaimPendingNotifications(ctx, "s2", baseTime, baseTime.Add(-time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, pending, "claimed by s1")
+	require.NoError(t, s.MarkNotificationPublished(ctx, n.ID, baseTime))
+	pending, err = s.ClaimPendingNotifications(ctx, "s2", baseTime, baseTime.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+}
@@ -0,0 +1,683 @@
+package sqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+var baseTime = time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+
+const eventID = "5b0b1f7e-3f57-4a43-9d6b-1b6b7f0c2a11"
+
+func newMockStorage(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	return &Storage{db: sqlx.NewDb(db, driverName)}, mock
+}
+
+func newEvent() storage.Event {
+	return storage.Event{
+		Title:        "meeting",
+		StartAt:      baseTime,
+		EndAt:        baseTime.Add(time.Hour),
+		Description:  "weekly sync",
+		UserID:       "u1",
+		NotifyBefore: 15 * time.Minute,
+		TimeZone:     "UTC",
+	}
+}
+
+func newStoredEvent(rule string) storage.Event {
+	e := newEvent()
+	e.ID = eventID
+	e.UID = eventID
+	e.RRule = rule
+	e.Version = 1
+	return e
+}
+
+func q(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+// rowsOf returns events as the database returns them.
+func rowsOf(t *testing.T, events ...storage.Event) *sqlmock.Rows {
+	t.Helper()
+	rows := sqlmock.NewRows(strings.Split(eventColumns, ", "))
+	for _, e := range events {
+		rows.AddRow(rowValues(t, e)...)
+	}
+	return rows
+}
+
+// hitRowsOf returns events found by a search with the same rank as the database returns them.
+func hitRowsOf(t *testing.T, rank float64, events ...storage.Event) *sqlmock.Rows {
+	t.Helper()
+	rows := sqlmock.NewRows(append(strings.Split(eventColumns, ", "), "rank"))
+	for _, e := range events {
+		rows.AddRow(append(rowValues(t, e), rank)...)
+	}
+	return rows
+}
+
+func rowValues(t *testing.T, e storage.Event) []driver.Value {
+	t.Helper()
+	r := toRow(e)
+	values := []driver.Valuer{r.ExDates, r.SeriesID, r.RecurrenceID, r.Attendees, r.Reminders}
+	converted := make([]driver.Value, 0, len(values))
+	for _, v := range values {
+		value, err := v.Value()
+		require.NoError(t, err)
+		converted = append(converted, value)
+	}
+	return []driver.Value{
+		r.ID, r.Title, r.StartAt, r.EndAt, r.Description, r.UserID, r.NotifyBefore, r.RRule,
+		converted[0], converted[1], converted[2], r.UID, r.TimeZone, converted[3], converted[4], r.Version,
+	}
+}
+
+func expectUserTx(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(q("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("u1").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+// expectLogged expects the change of the event to be logged for the users.
+func expectLogged(mock sqlmock.Sqlmock, id driver.Value, users ...string) {
+	mock.ExpectExec(q("SELECT pg_advisory_xact_lock(hashtext('event_changes'))")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, userID := range users {
+		mock.ExpectExec(q("INSERT INTO event_changes (user_id, event_id) VALUES ($1, $2)")).
+			WithArgs(userID, id).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ping before connect", func(t *testing.T) {
+		s := New("postgres://localhost/calendar", 1, time.Second)
+		require.ErrorIs(t, s.Ping(ctx), ErrNotConnected)
+		require.NoError(t, s.Close(ctx))
+	})
+
+	t.Run("create", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).
+			WithArgs("u1", sqlmock.AnyArg(), baseTime, baseTime.Add(time.Hour)).
+			WillReturnRows(rowsOf(t))
+		mock.ExpectExec(q("INSERT INTO events")).
+			WithArgs(sqlmock.AnyArg(), "meeting", baseTime, baseTime.Add(time.Hour), "weekly sync", "u1",
+				int64(15*time.Minute), "", "[]", nil, nil, sqlmock.AnyArg(), "UTC", "[]", "[]", int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, sqlmock.AnyArg(), "u1")
+		mock.ExpectCommit()
+
+		e, err := s.CreateEvent(ctx, newEvent())
+		require.NoError(t, err)
+		require.True(t, isUUID(e.ID))
+		require.Equal(t, e.ID, e.UID)
+		require.Equal(t, "meeting", e.Title)
+	})
+
+	t.Run("create with taken uid", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).WillReturnRows(rowsOf(t))
+		mock.ExpectExec(q("INSERT INTO events")).WillReturnError(&pgconn.PgError{Code: uniqueViolationCode})
+		mock.ExpectRollback()
+
+		e := newEvent()
+		e.UID = "imported@example.com"
+		_, err := s.CreateEvent(ctx, e)
+		require.ErrorIs(t, err, storage.ErrUIDTaken)
+	})
+
+	t.Run("create on busy date", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		other := newEvent()
+		other.ID = eventID
+		other.StartAt = baseTime.Add(-30 * time.Minute)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).WillReturnRows(rowsOf(t, other))
+		mock.ExpectRollback()
+
+		_, err := s.CreateEvent(ctx, newEvent())
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+	})
+
+	t.Run("create with allowed overlap", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		mock.ExpectExec(q("INSERT INTO events")).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, sqlmock.AnyArg(), "u1")
+		mock.ExpectCommit()
+
+		_, err := s.CreateEvent(storage.WithOverlap(ctx), newEvent())
+		require.NoError(t, err)
+	})
+
+	t.Run("list conflicts", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		other := newEvent()
+		other.ID = "6c1c2f8f-4f68-4b54-8e7c-2c7c8f1d3b22"
+		other.StartAt = baseTime.Add(-30 * time.Minute)
+		adjacent := newEvent()
+		adjacent.ID = "7d2d3a9a-5a79-4c65-9f8d-3d8d9a2e4c33"
+		adjacent.StartAt, adjacent.EndAt = baseTime.Add(-time.Hour), baseTime
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).
+			WithArgs("u1", eventID, baseTime, baseTime.Add(time.Hour)).
+			WillReturnRows(rowsOf(t, adjacent, other))
+
+		e := newStoredEvent("")
+		conflicts, err := s.ListConflicts(ctx, e)
+		require.NoError(t, err)
+		require.Len(t, conflicts, 1)
+		require.Equal(t, other.ID, conflicts[0].ID)
+	})
+
+	t.Run("create on busy occurrence", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		// The daily series starts a week before the new event.
+		series := newStoredEvent("FREQ=DAILY")
+		series.StartAt, series.EndAt = baseTime.AddDate(0, 0, -7), baseTime.AddDate(0, 0, -7).Add(time.Hour)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).WillReturnRows(rowsOf(t, series))
+		mock.ExpectRollback()
+
+		_, err := s.CreateEvent(ctx, newEvent())
+		require.ErrorIs(t, err, storage.ErrDateBusy)
+	})
+
+	t.Run("create invalid", func(t *testing.T) {
+		s, _ := newMockStorage(t)
+
+		e := newEvent()
+		e.Title = ""
+		_, err := s.CreateEvent(ctx, e)
+		require.ErrorIs(t, err, storage.ErrInvalidEvent)
+
+		e = newEvent()
+		e.RRule = "FREQ=HOURLY"
+		_, err = s.CreateEvent(ctx, e)
+		require.ErrorIs(t, err, storage.ErrInvalidEvent)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).WithArgs(eventID).
+			WillReturnRows(rowsOf(t, newStoredEvent("")))
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).
+			WithArgs("u1", eventID, baseTime, baseTime.Add(time.Hour)).
+			WillReturnRows(rowsOf(t))
+		mock.ExpectExec(q("UPDATE events SET")).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, eventID, "u1")
+		mock.ExpectCommit()
+
+		e, err := s.UpdateEvent(ctx, eventID, newEvent())
+		require.NoError(t, err)
+		require.Equal(t, eventID, e.ID)
+		require.Equal(t, int64(2), e.Version)
+	})
+
+	t.Run("update stale version", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		stored := newStoredEvent("")
+		stored.Version = 3
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).WithArgs(eventID).
+			WillReturnRows(rowsOf(t, stored))
+		mock.ExpectRollback()
+
+		e := newEvent()
+		e.Version = 2
+		_, err := s.UpdateEvent(ctx, eventID, e)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+	})
+
+	t.Run("update missing", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := s.UpdateEvent(ctx, eventID, newEvent())
+		require.ErrorIs(t, err, storage.ErrNotFound)
+
+		_, err = s.UpdateEvent(ctx, "not-a-uuid", newEvent())
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		edited := newStoredEvent("")
+		edited.ID, edited.SeriesID = "0c7d2f55-5d0e-4d2b-8b3e-6f1c1a7b9e42", eventID
+		edited.Attendees = []storage.Attendee{{UserID: "u2", Status: storage.RSVPAccepted}}
+		mock.ExpectBegin()
+		mock.ExpectQuery(q("DELETE FROM events WHERE id = $1 OR series_id = $1 RETURNING")).WithArgs(eventID).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=DAILY"), edited))
+		expectLogged(mock, eventID, "u1")
+		expectLogged(mock, edited.ID, "u1", "u2")
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectQuery(q("DELETE FROM events WHERE id = $1 OR series_id = $1 RETURNING")).WithArgs(eventID).
+			WillReturnRows(rowsOf(t))
+		mock.ExpectRollback()
+
+		require.NoError(t, s.DeleteEvent(ctx, eventID))
+		require.ErrorIs(t, s.DeleteEvent(ctx, eventID), storage.ErrNotFound)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).WithArgs(eventID).
+			WillReturnRows(rowsOf(t, newStoredEvent("")))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).WithArgs(eventID).
+			WillReturnError(sql.ErrNoRows)
+
+		e, err := s.GetEvent(ctx, eventID)
+		require.NoError(t, err)
+		require.Equal(t, newStoredEvent(""), e)
+
+		_, err = s.GetEvent(ctx, eventID)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		from, to := storage.WeekRange(baseTime)
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).WithArgs("u1", from, to).
+			WillReturnRows(rowsOf(t, newStoredEvent("")))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).WillReturnRows(rowsOf(t))
+
+		events, err := s.ListWeekEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, eventID, events[0].ID)
+
+		events, err = s.ListDayEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.NotNil(t, events)
+		require.Empty(t, events)
+	})
+
+	t.Run("list expands recurring events", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		series := newStoredEvent("FREQ=DAILY;COUNT=10")
+		series.ExDates = []time.Time{baseTime.AddDate(0, 0, 2)}
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).WillReturnRows(rowsOf(t, series))
+
+		events, err := s.ListWeekEvents(ctx, "u1", baseTime)
+		require.NoError(t, err)
+		require.Len(t, events, 6)
+		require.Equal(t, baseTime.AddDate(0, 0, 1), events[1].StartAt)
+		require.Equal(t, baseTime.AddDate(0, 0, 3), events[2].StartAt)
+	})
+
+	t.Run("exclude occurrence", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		occurrence := baseTime.AddDate(0, 0, 1)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=DAILY")))
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=DAILY")))
+		mock.ExpectExec(q("UPDATE events SET exdates = $2, version = version + 1 WHERE id = $1")).
+			WithArgs(eventID, `["2024-03-05T10:00:00Z"]`).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, eventID, "u1")
+		mock.ExpectCommit()
+
+		require.NoError(t, s.ExcludeOccurrence(ctx, eventID, occurrence, 0))
+	})
+
+	t.Run("exclude missing occurrence", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY")))
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY")))
+		mock.ExpectRollback()
+
+		err := s.ExcludeOccurrence(ctx, eventID, baseTime.AddDate(0, 0, 1), 0)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("change occurrence of changed series", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		occurrence := baseTime.AddDate(0, 0, 7)
+		for range 2 {
+			mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).
+				WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY")))
+			expectUserTx(mock)
+			mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+				WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY")))
+			mock.ExpectRollback()
+		}
+
+		require.ErrorIs(t, s.ExcludeOccurrence(ctx, eventID, occurrence, 2), storage.ErrVersionMismatch)
+		moved := newEvent()
+		moved.StartAt, moved.EndAt = occurrence.Add(time.Hour), occurrence.Add(2*time.Hour)
+		moved.Version = 2
+		_, err := s.DetachOccurrence(ctx, eventID, occurrence, moved)
+		require.ErrorIs(t, err, storage.ErrVersionMismatch)
+	})
+
+	t.Run("respond to event", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		stored := newStoredEvent("")
+		stored.Attendees = []storage.Attendee{{UserID: "u2", Status: storage.RSVPNeedsAction}}
+		mock.ExpectBegin()
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnRows(rowsOf(t, stored))
+		mock.ExpectExec(q("UPDATE events SET attendees = $2, version = $3 WHERE id = $1")).
+			WithArgs(eventID, `[{"user_id":"u2","status":"accepted"}]`, int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, eventID, "u1", "u2")
+		mock.ExpectCommit()
+
+		e, err := s.RespondToEvent(ctx, eventID, "u2", storage.RSVPAccepted)
+		require.NoError(t, err)
+		require.Equal(t, storage.RSVPAccepted, e.Attendees[0].Status)
+	})
+
+	t.Run("respond to event not attended", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnRows(rowsOf(t, newStoredEvent("")))
+		mock.ExpectRollback()
+
+		_, err := s.RespondToEvent(ctx, eventID, "u2", storage.RSVPDeclined)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("detach occurrence", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		occurrence := baseTime.AddDate(0, 0, 7)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY")))
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY")))
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events WHERE series_id = $1 AND recurrence_id = $2 FOR UPDATE")).
+			WithArgs(eventID, occurrence).WillReturnRows(rowsOf(t))
+		mock.ExpectExec(q("UPDATE events SET exdates = $2, version = version + 1 WHERE id = $1")).
+			WithArgs(eventID, `["2024-03-11T10:00:00Z"]`).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=WEEKLY").Exclude(occurrence)))
+		mock.ExpectExec(q("INSERT INTO events")).
+			WithArgs(sqlmock.AnyArg(), "moved", occurrence.Add(time.Hour), occurrence.Add(2*time.Hour),
+				"weekly sync", "u1", int64(15*time.Minute), "", "[]", eventID, occurrence, eventID, "UTC", "[]", "[]",
+				int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, eventID, "u1")
+		expectLogged(mock, sqlmock.AnyArg(), "u1")
+		mock.ExpectCommit()
+
+		moved := newEvent()
+		moved.Title = "moved"
+		moved.StartAt, moved.EndAt = occurrence.Add(time.Hour), occurrence.Add(2*time.Hour)
+		e, err := s.DetachOccurrence(ctx, eventID, occurrence, moved)
+		require.NoError(t, err)
+		require.Equal(t, eventID, e.SeriesID)
+		require.Equal(t, occurrence, e.RecurrenceID)
+	})
+
+	t.Run("detach edited occurrence", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		occurrence := baseTime.AddDate(0, 0, 7)
+		editedID := "0c7d2f55-5d0e-4d2b-8b3e-6f1c1a7b9e42"
+		series := newStoredEvent("FREQ=WEEKLY").Exclude(occurrence)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1")).
+			WillReturnRows(rowsOf(t, series))
+		expectUserTx(mock)
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id = $1 FOR UPDATE")).
+			WillReturnRows(rowsOf(t, series))
+		edited := newStoredEvent("")
+		edited.ID, edited.SeriesID, edited.RecurrenceID, edited.Version = editedID, eventID, occurrence, 2
+		edited.Attendees = []storage.Attendee{{UserID: "u2"}}
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE series_id = $1")).
+			WillReturnRows(rowsOf(t, edited))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events")).WillReturnRows(rowsOf(t, series))
+		mock.ExpectExec(q("UPDATE events SET title = $1")).WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLogged(mock, editedID, "u1", "u2")
+		mock.ExpectCommit()
+
+		moved := newEvent()
+		moved.StartAt, moved.EndAt = occurrence.Add(time.Hour), occurrence.Add(2*time.Hour)
+		e, err := s.DetachOccurrence(ctx, eventID, occurrence, moved)
+		require.NoError(t, err)
+		require.Equal(t, editedID, e.ID)
+		require.Equal(t, eventID, e.UID)
+		require.Equal(t, int64(3), e.Version)
+	})
+
+	t.Run("get by uid", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).
+			WithArgs("u1", eventID, nil).WillReturnRows(rowsOf(t, newStoredEvent("")))
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).
+			WithArgs("u1", eventID, baseTime).WillReturnRows(rowsOf(t))
+
+		e, err := s.GetEventByUID(ctx, "u1", eventID, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, newStoredEvent(""), e)
+
+		_, err = s.GetEventByUID(ctx, "u1", eventID, baseTime)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("list events not expanded", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		from, to := storage.MonthRange(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC))
+		ended := newStoredEvent("FREQ=DAILY;COUNT=2")
+		ended.ID = "0c7d2f55-5d0e-4d2b-8b3e-6f1c1a7b9e42"
+		ended.StartAt, ended.EndAt = baseTime.AddDate(0, -1, 0), baseTime.AddDate(0, -1, 0).Add(time.Hour)
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).WithArgs("u1", from, to).
+			WillReturnRows(rowsOf(t, newStoredEvent("FREQ=DAILY"), ended))
+
+		events, err := s.ListEvents(ctx, "u1", from, to)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Event{newStoredEvent("FREQ=DAILY")}, events)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		shared := newStoredEvent("")
+		shared.Attendees = []storage.Attendee{{UserID: "u2", Status: storage.RSVPAccepted}}
+		mock.ExpectQuery(q("SELECT "+eventColumns+", ts_rank(search, query) AS rank")).
+			WithArgs("u2", "budget review", "u1").
+			WillReturnRows(hitRowsOf(t, 0.6, shared))
+
+		hits, err := s.SearchEvents(ctx, storage.SearchQuery{UserID: "u2", Text: "Budget, review!", OwnerID: "u1"})
+		require.NoError(t, err)
+		require.Equal(t, []storage.SearchHit{{Event: shared, Rank: 0.6}}, hits)
+
+		hits, err = s.SearchEvents(ctx, storage.SearchQuery{UserID: "u1", Text: "..."})
+		require.NoError(t, err)
+		require.NotNil(t, hits)
+		require.Empty(t, hits)
+	})
+
+	t.Run("search selects rows until the limit", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		from, to := storage.WeekRange(baseTime.AddDate(0, 0, 7))
+		ended := newStoredEvent("FREQ=DAILY;COUNT=2")
+		ended.ID = "0c7d2f55-5d0e-4d2b-8b3e-6f1c1a7b9e42"
+		active := newStoredEvent("FREQ=WEEKLY")
+		mock.ExpectQuery(q("SELECT "+eventColumns+", ts_rank(search, query) AS rank")).
+			WithArgs("u1", "budget", to, from, 1).
+			WillReturnRows(hitRowsOf(t, 0.6, ended))
+		mock.ExpectQuery(q("AND (ts_rank(search, query) < $5::real OR ts_rank(search, query) = $5::real "+
+			"AND (start_at, id) > ($6, $7::uuid)) ORDER BY rank DESC, start_at, id LIMIT $8")).
+			WithArgs("u1", "budget", to, from, 0.6, ended.StartAt, ended.ID, 1).
+			WillReturnRows(hitRowsOf(t, 0.6, active))
+
+		hits, err := s.SearchEvents(ctx, storage.SearchQuery{UserID: "u1", Text: "budget", From: from, To: to, Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, []storage.SearchHit{{Event: active, Rank: 0.6}}, hits)
+	})
+
+	t.Run("full sync", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectQuery(q("SELECT GREATEST(COALESCE(MAX(seq), 0)")).
+			WillReturnRows(sqlmock.NewRows([]string{"greatest"}).AddRow(42))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE " + involving)).WithArgs("u1").
+			WillReturnRows(rowsOf(t, newStoredEvent("")))
+
+		changes, err := s.ListChanges(ctx, "u1", 0)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), changes.Seq)
+		require.Len(t, changes.Events, 1)
+		require.Empty(t, changes.Deleted)
+	})
+
+	t.Run("changes since", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		deletedID := "0c7d2f55-5d0e-4d2b-8b3e-6f1c1a7b9e42"
+		mock.ExpectQuery(q("SELECT GREATEST(COALESCE(MAX(seq), 0)")).
+			WillReturnRows(sqlmock.NewRows([]string{"greatest"}).AddRow(42))
+		mock.ExpectQuery(q("SELECT event_id FROM event_changes")).WithArgs("u1", int64(40), int64(42)).
+			WillReturnRows(sqlmock.NewRows([]string{"event_id"}).AddRow(eventID).AddRow(deletedID))
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events WHERE id IN")).WithArgs("u1", int64(40), int64(42)).
+			WillReturnRows(rowsOf(t, newStoredEvent("")))
+		mock.ExpectQuery(q("SELECT seq FROM event_changes_compacted")).
+			WillReturnRows(sqlmock.NewRows([]string{"seq"}).AddRow(10))
+
+		changes, err := s.ListChanges(ctx, "u1", 40)
+		require.NoError(t, err)
+		require.Equal(t, storage.Changes{Events: []storage.Event{newStoredEvent("")}, Deleted: []string{deletedID}, Seq: 42},
+			changes)
+	})
+
+	t.Run("changes compacted", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectQuery(q("SELECT GREATEST(COALESCE(MAX(seq), 0)")).
+			WillReturnRows(sqlmock.NewRows([]string{"greatest"}).AddRow(42))
+		mock.ExpectQuery(q("SELECT event_id FROM event_changes")).
+			WillReturnRows(sqlmock.NewRows([]string{"event_id"}))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE id IN")).WillReturnRows(rowsOf(t))
+		mock.ExpectQuery(q("SELECT seq FROM event_changes_compacted")).
+			WillReturnRows(sqlmock.NewRows([]string{"seq"}).AddRow(10))
+
+		_, err := s.ListChanges(ctx, "u1", 5)
+		require.ErrorIs(t, err, storage.ErrChangesCompacted)
+
+		mock.ExpectQuery(q("SELECT GREATEST(COALESCE(MAX(seq), 0)")).
+			WillReturnRows(sqlmock.NewRows([]string{"greatest"}).AddRow(42))
+		_, err = s.ListChanges(ctx, "u1", 43)
+		require.ErrorIs(t, err, storage.ErrChangesCompacted)
+	})
+
+	t.Run("compact changes", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		mock.ExpectQuery(q("WITH deleted AS (DELETE FROM event_changes WHERE changed_at < $1 RETURNING seq)")).
+			WithArgs(baseTime).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+		compacted, err := s.CompactChanges(ctx, baseTime)
+		require.NoError(t, err)
+		require.Equal(t, int64(7), compacted)
+	})
+
+	t.Run("due reminders", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		from, to := baseTime.Add(-15*time.Minute), baseTime.Add(-14*time.Minute)
+		series := newStoredEvent("FREQ=DAILY")
+		series.StartAt, series.EndAt = baseTime.AddDate(0, 0, -3), baseTime.AddDate(0, 0, -3).Add(time.Hour)
+		series.NotifyBefore = 0
+		series.Reminders = []storage.Reminder{{Before: 15 * time.Minute, Channel: storage.ChannelEmail}}
+		mock.ExpectQuery(q("SELECT "+eventColumns+" FROM events")).WithArgs(from, to).
+			WillReturnRows(rowsOf(t, newStoredEvent(""), series))
+
+		due, err := s.ListDueReminders(ctx, from, to)
+		require.NoError(t, err)
+		require.Len(t, due, 2)
+		require.Equal(t, baseTime, due[0].Event.StartAt)
+		require.Equal(t, storage.Reminder{Before: 15 * time.Minute}, due[0].Reminder)
+		require.Equal(t, baseTime, due[1].Event.StartAt)
+		require.Equal(t, series.Reminders, due[1].Event.Reminders)
+		require.Equal(t, storage.ChannelEmail, due[1].Reminder.Channel)
+	})
+
+	t.Run("notification outbox", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		n1 := storage.Notification{ID: "n1", EventID: eventID, Title: "meeting", StartAt: baseTime, UserID: "u1"}
+		n2 := storage.Notification{ID: "n2", EventID: eventID, UserID: "u2", Channel: storage.ChannelEmail}
+		payload1, err := json.Marshal(n1)
+		require.NoError(t, err)
+		payload2, err := json.Marshal(n2)
+		require.NoError(t, err)
+
+		enqueue := q("INSERT INTO notification_outbox (id, payload, enqueued_at) VALUES ($1, $2, $3)")
+		mock.ExpectBegin()
+		mock.ExpectExec(enqueue).WithArgs("n1", payload1, baseTime).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(enqueue).WithArgs("n2", payload2, baseTime).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectQuery(q("UPDATE notification_outbox SET claimed_by = $1, claimed_at = $2")).
+			WithArgs("s1", baseTime, baseTime.Add(-time.Minute), 10).
+			WillReturnRows(sqlmock.NewRows([]string{"payload"}).AddRow(payload1).AddRow(payload2))
+		mark := q("UPDATE notification_outbox SET published_at = $2 WHERE id = $1")
+		mock.ExpectExec(mark).WithArgs("n1", baseTime).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(mark).WithArgs("n3", baseTime).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(q("DELETE FROM notification_outbox WHERE published_at IS NOT NULL AND enqueued_at < $1")).
+			WithArgs(baseTime).WillReturnResult(sqlmock.NewResult(0, 3))
+
+		added, err := s.EnqueueNotifications(ctx, []storage.Notification{n1, n2}, baseTime)
+		require.NoError(t, err)
+		require.Equal(t, 1, added)
+		pending, err := s.ClaimPendingNotifications(ctx, "s1", baseTime, baseTime.Add(-time.Minute), 10)
+		require.NoError(t, err)
+		require.Equal(t, []storage.Notification{n1, n2}, pending)
+		require.NoError(t, s.MarkNotificationPublished(ctx, "n1", baseTime))
+		require.ErrorIs(t, s.MarkNotificationPublished(ctx, "n3", baseTime), storage.ErrNotFound)
+		compacted, err := s.CompactOutbox(ctx, baseTime)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), compacted)
+	})
+
+	t.Run("delete old events", func(t *testing.T) {
+		s, mock := newMockStorage(t)
+		ended := newStoredEvent("FREQ=DAILY;COUNT=2")
+		ended.StartAt, ended.EndAt = baseTime.AddDate(0, 0, -3), baseTime.AddDate(0, 0, -3).Add(time.Hour)
+		oneOff := newStoredEvent("")
+		oneOff.ID = "0c7d2f55-5d0e-4d2b-8b3e-6f1c1a7b9e42"
+		mock.ExpectBegin()
+		mock.ExpectQuery(q("DELETE FROM events WHERE rrule = '' AND end_at < $1 RETURNING")).WithArgs(baseTime).
+			WillReturnRows(rowsOf(t, oneOff))
+		mock.ExpectQuery(q("SELECT " + eventColumns + " FROM events WHERE rrule <> '' AND start_at < $1")).
+			WithArgs(baseTime).WillReturnRows(rowsOf(t, ended, newStoredEvent("FREQ=DAILY")))
+		edited := newEvent()
+		edited.ID, edited.SeriesID = "4f1e7c2a-9b3d-4e8f-a6c5-2d7b1e9f3a60", eventID
+		edited.RecurrenceID = ended.StartAt.AddDate(0, 0, 1)
+		mock.ExpectQuery(q("DELETE FROM events WHERE id = $1 OR series_id = $1 RETURNING")).WithArgs(eventID).
+			WillReturnRows(rowsOf(t, ended, edited))
+		expectLogged(mock, oneOff.ID, "u1")
+		expectLogged(mock, eventID, "u1")
+		expectLogged(mock, edited.ID, "u1")
+		mock.ExpectCommit()
+
+		deleted, err := s.DeleteEventsEndedBefore(ctx, baseTime)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), deleted, "with the edited occurrence")
+	})
+}
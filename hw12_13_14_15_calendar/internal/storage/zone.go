@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// locations caches loaded time zones, time.LoadLocation reads the zone database on every call.
+var locations sync.Map
+
+// LoadLocation returns the time zone with the given IANA name; an empty name means UTC.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE events (
+    id            uuid PRIMARY KEY,
+    title         text        NOT NULL,
+    start_at      timestamptz NOT NULL,
+    end_at        timestamptz NOT NULL,
+    description   text        NOT NULL DEFAULT '',
+    user_id       text        NOT NULL,
+    notify_before bigint      NOT NULL DEFAULT 0, -- nanoseconds, as time.Duration
+    CONSTRAINT events_time_span_check CHECK (end_at > start_at)
+);
+
+CREATE INDEX events_user_id_start_at_idx ON events (user_id, start_at);
+
+-- +goose Down
+DROP TABLE events;
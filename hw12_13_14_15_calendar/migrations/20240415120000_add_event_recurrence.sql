@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE events
+    ADD COLUMN rrule         text        NOT NULL DEFAULT '',
+    ADD COLUMN exdates       jsonb       NOT NULL DEFAULT '[]', -- start times of excluded occurrences
+    ADD COLUMN series_id     uuid        REFERENCES events (id) ON DELETE CASCADE,
+    ADD COLUMN recurrence_id timestamptz;
+
+CREATE INDEX events_series_id_idx ON events (series_id);
+
+-- +goose Down
+ALTER TABLE events
+    DROP COLUMN recurrence_id,
+    DROP COLUMN series_id,
+    DROP COLUMN exdates,
+    DROP COLUMN rrule;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN uid text; -- iCalendar UID, shared by a recurring event and its edited occurrences
+
+UPDATE events SET uid = id::text WHERE series_id IS NULL;
+UPDATE events o SET uid = s.uid FROM events s WHERE o.series_id = s.id;
+
+ALTER TABLE events ALTER COLUMN uid SET NOT NULL;
+
+CREATE UNIQUE INDEX events_user_id_uid_idx ON events (user_id, uid) WHERE series_id IS NULL;
+CREATE UNIQUE INDEX events_series_id_recurrence_id_idx ON events (series_id, recurrence_id);
+
+-- +goose Down
+DROP INDEX events_series_id_recurrence_id_idx;
+DROP INDEX events_user_id_uid_idx;
+ALTER TABLE events DROP COLUMN uid;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN time_zone text NOT NULL DEFAULT 'UTC'; -- IANA name
+
+-- +goose Down
+ALTER TABLE events DROP COLUMN time_zone;
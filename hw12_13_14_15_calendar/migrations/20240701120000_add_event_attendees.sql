@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN attendees jsonb NOT NULL DEFAULT '[]'; -- [{"user_id": ..., "status": ...}]
+
+CREATE INDEX events_attendees_idx ON events USING gin (attendees jsonb_path_ops);
+
+-- +goose Down
+DROP INDEX events_attendees_idx;
+ALTER TABLE events DROP COLUMN attendees;
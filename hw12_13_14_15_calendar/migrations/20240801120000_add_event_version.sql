@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN version bigint NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE events DROP COLUMN version;
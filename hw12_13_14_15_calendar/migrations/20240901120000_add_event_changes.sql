@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE event_changes (
+    seq        bigserial   PRIMARY KEY,
+    user_id    text        NOT NULL,
+    event_id   uuid        NOT NULL, -- not a reference, changes of deleted events are kept
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX event_changes_user_id_seq_idx ON event_changes (user_id, seq);
+CREATE INDEX event_changes_changed_at_idx ON event_changes (changed_at);
+
+-- Position of the last compacted change.
+CREATE TABLE event_changes_compacted (
+    seq bigint NOT NULL
+);
+
+INSERT INTO event_changes_compacted (seq) VALUES (0);
+
+-- +goose Down
+DROP TABLE event_changes_compacted;
+DROP TABLE event_changes;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Weights A and B rank title matches above description ones, the "simple" configuration
+-- matches words as typed, the way memory storage does.
+ALTER TABLE events ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', description), 'B')
+) STORED;
+
+CREATE INDEX events_search_idx ON events USING gin (search);
+
+-- +goose Down
+DROP INDEX events_search_idx;
+ALTER TABLE events DROP COLUMN search;
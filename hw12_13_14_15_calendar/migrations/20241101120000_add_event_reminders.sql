@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE events ADD COLUMN reminders jsonb NOT NULL DEFAULT '[]'; -- [{"before": nanoseconds, "channel": ...}]
+
+-- Notifications claimed by schedulers, so that every one is published once.
+CREATE TABLE notification_claims (
+    id         text        PRIMARY KEY,
+    claimed_at timestamptz NOT NULL
+);
+
+CREATE INDEX notification_claims_claimed_at_idx ON notification_claims (claimed_at);
+
+-- +goose Down
+DROP TABLE notification_claims;
+ALTER TABLE events DROP COLUMN reminders;
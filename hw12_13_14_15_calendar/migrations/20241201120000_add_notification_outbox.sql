@@ -0,0 +1,33 @@
+-- +goose Up
+-- Notifications the scheduler publishes, kept after publishing so that repeated ones are dropped.
+CREATE TABLE notification_outbox (
+    id           text        PRIMARY KEY,
+    payload      jsonb       NOT NULL,
+    enqueued_at  timestamptz NOT NULL,
+    published_at timestamptz,
+    -- The scheduler publishing the notification since claimed_at, see ClaimPendingNotifications.
+    claimed_by   text,
+    claimed_at   timestamptz
+);
+
+CREATE INDEX notification_outbox_pending_idx ON notification_outbox (enqueued_at, id) WHERE published_at IS NULL;
+CREATE INDEX notification_outbox_enqueued_at_idx ON notification_outbox (enqueued_at);
+
+-- Claimed notifications were published, keeping them prevents sending them again.
+INSERT INTO notification_outbox (id, payload, enqueued_at, published_at)
+SELECT id, jsonb_build_object('id', id), claimed_at, claimed_at FROM notification_claims;
+
+DROP TABLE notification_claims;
+
+-- +goose Down
+CREATE TABLE notification_claims (
+    id         text        PRIMARY KEY,
+    claimed_at timestamptz NOT NULL
+);
+
+CREATE INDEX notification_claims_claimed_at_idx ON notification_claims (claimed_at);
+
+INSERT INTO notification_claims (id, claimed_at)
+SELECT id, enqueued_at FROM notification_outbox WHERE published_at IS NOT NULL;
+
+DROP TABLE notification_outbox;
@@ -0,0 +1,7 @@
+// Package migrations embeds versioned SQL migrations of the calendar database schema.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
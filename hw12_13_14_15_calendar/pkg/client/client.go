@@ -0,0 +1,164 @@
+// Package client is a typed Go client of the calendar REST API described in api/openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const userIDHeader = "X-User-ID"
+
+// Error codes of the API, see Error.Code.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeNoUser           = "no_user"
+	CodeInvalidEvent     = "invalid_event"
+	CodeInvalidQuery     = "invalid_query"
+	CodeInvalidSyncToken = "invalid_sync_token"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeDateBusy         = "date_busy"
+	CodeUIDTaken         = "uid_taken"
+	CodeVersionMismatch  = "version_mismatch"
+	CodeSyncTokenExpired = "sync_token_expired"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal"
+)
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// RetryAfter is how long to wait before retrying a request rejected with CodeRateLimited.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("calendar: %s (%d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Client makes requests to the calendar on behalf of a user. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	userID     string
+	httpClient *http.Client
+}
+
+// New returns a client of the calendar at baseURL like "http://localhost:8888" acting
+// on behalf of the user. A nil httpClient means http.DefaultClient.
+func New(baseURL, userID string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), userID: userID, httpClient: httpClient}
+}
+
+// WithUser returns a copy of the client acting on behalf of another user.
+func (c *Client) WithUser(userID string) *Client {
+	cc := *c
+	cc.userID = userID
+	return &cc
+}
+
+type request struct {
+	method, path string
+	query        url.Values
+	header       http.Header
+	body         io.Reader
+}
+
+func jsonBody(v interface{}) (io.Reader, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// do sends the request and returns the response if it has the wanted status,
+// the caller must close its body.
+func (c *Client) do(ctx context.Context, req request, want int) (*http.Response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, u, req.body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		r.Header[k] = v
+	}
+	r.Header.Set(userIDHeader, c.userID)
+
+	resp, err := c.httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != want {
+		defer resp.Body.Close()
+		return nil, decodeError(resp)
+	}
+	return resp, nil
+}
+
+// doJSON sends the request and decodes the JSON response into out unless it is nil.
+func (c *Client) doJSON(ctx context.Context, req request, want int, out interface{}) error {
+	resp, err := c.do(ctx, req, want)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("calendar: invalid response: %w", err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	apiErr := &Error{StatusCode: resp.StatusCode, Code: CodeInternal, Message: http.StatusText(resp.StatusCode)}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.Error.Code != "" {
+		apiErr.Code, apiErr.Message = body.Error.Code, body.Error.Message
+	}
+	return apiErr
+}
+
+func saveQuery(opts SaveOptions) url.Values {
+	if !opts.AllowOverlap {
+		return nil
+	}
+	return url.Values{"allow_overlap": {"true"}}
+}
+
+func jsonHeader(opts SaveOptions) http.Header {
+	return ifMatchHeader(http.Header{"Content-Type": {"application/json"}}, opts.IfVersion)
+}
+
+// ifMatchHeader sets If-Match in h to a non-zero version.
+func ifMatchHeader(h http.Header, version int64) http.Header {
+	if version != 0 {
+		h.Set("If-Match", strconv.Quote(strconv.FormatInt(version, 10)))
+	}
+	return h
+}
@@ -0,0 +1,282 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/app"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/health"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/metrics"
+	"github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/ratelimit"
+	internalhttp "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/server/http"
+	memorystorage "github.com/fixme_my_friend/hw12_13_14_15_calendar/internal/storage/memory"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{})                         {}
+func (nopLogger) Info(string, ...interface{})                          {}
+func (nopLogger) Warn(string, ...interface{})                          {}
+func (nopLogger) Error(string, ...interface{})                         {}
+func (nopLogger) InfoContext(context.Context, string, ...interface{})  {}
+func (nopLogger) ErrorContext(context.Context, string, ...interface{}) {}
+
+func (nopLogger) ContextWith(ctx context.Context, _ ...interface{}) context.Context {
+	return ctx
+}
+
+func newTestClient(t *testing.T, userID string) *Client {
+	t.Helper()
+	return newLimitedTestClient(t, userID, ratelimit.New(nil))
+}
+
+func newLimitedTestClient(t *testing.T, userID string, limiter internalhttp.Limiter) *Client {
+	t.Helper()
+	calendar := app.New(nopLogger{}, memorystorage.New())
+	probes := health.Handler(health.NewChecker(nil), health.BuildInfo{})
+	m := metrics.NewHTTP(prometheus.NewRegistry())
+	s := internalhttp.NewServer(nopLogger{}, calendar, m, limiter, probes, "")
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return New(ts.URL+"/", userID, ts.Client())
+}
+
+func requireCode(t *testing.T, err error, status int, code string) {
+	t.Helper()
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr), err)
+	require.Equal(t, status, apiErr.StatusCode)
+	require.Equal(t, code, apiErr.Code)
+}
+
+var meeting = EventInput{
+	Title:        "meeting",
+	StartAt:      time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC),
+	EndAt:        time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC),
+	NotifyBefore: Duration(15 * time.Minute),
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("event lifecycle", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+
+		created, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+		require.Equal(t, "u1", created.UserID)
+		require.Equal(t, Duration(15*time.Minute), created.NotifyBefore)
+
+		got, err := c.GetEvent(ctx, created.ID)
+		require.NoError(t, err)
+		require.Equal(t, created, got)
+
+		retro := meeting
+		retro.Title = "retro"
+		updated, err := c.UpdateEvent(ctx, created.ID, retro, SaveOptions{IfVersion: created.Version})
+		require.NoError(t, err)
+		require.Equal(t, "retro", updated.Title)
+
+		_, err = c.UpdateEvent(ctx, created.ID, meeting, SaveOptions{IfVersion: created.Version})
+		requireCode(t, err, http.StatusPreconditionFailed, CodeVersionMismatch)
+
+		events, err := c.ListDayEvents(ctx, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+
+		require.NoError(t, c.DeleteEvent(ctx, created.ID))
+		_, err = c.GetEvent(ctx, created.ID)
+		requireCode(t, err, http.StatusNotFound, CodeNotFound)
+	})
+
+	t.Run("overlap", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		first, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+
+		_, err = c.CreateEvent(ctx, meeting, SaveOptions{})
+		requireCode(t, err, http.StatusConflict, CodeDateBusy)
+
+		second, err := c.CreateEvent(ctx, meeting, SaveOptions{AllowOverlap: true})
+		require.NoError(t, err)
+		require.Equal(t, []string{first.ID}, second.Conflicts)
+	})
+
+	t.Run("list in time zone", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		_, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+
+		tokyo, err := time.LoadLocation("Asia/Tokyo")
+		require.NoError(t, err)
+		events, err := c.ListWeekEvents(ctx, time.Date(2024, 3, 4, 0, 0, 0, 0, tokyo))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		events, err = c.ListMonthEvents(ctx, time.Date(2024, 2, 1, 0, 0, 0, 0, tokyo))
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("recurring event", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		daily := meeting
+		daily.RRule = "FREQ=DAILY;COUNT=3"
+		series, err := c.CreateEvent(ctx, daily, SaveOptions{})
+		require.NoError(t, err)
+
+		moved := meeting
+		moved.StartAt = time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+		moved.EndAt = moved.StartAt.Add(time.Hour)
+		occurrence, err := c.UpdateOccurrence(ctx, series.ID, moved.StartAt.Add(-4*time.Hour), moved, SaveOptions{})
+		require.NoError(t, err)
+		require.Equal(t, series.ID, occurrence.SeriesID)
+
+		require.NoError(t, c.DeleteOccurrence(ctx, series.ID, time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC), 0))
+		events, err := c.ListWeekEvents(ctx, meeting.StartAt)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+	})
+
+	t.Run("attendees", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		invite := meeting
+		invite.Attendees = []string{"u2"}
+		e, err := c.CreateEvent(ctx, invite, SaveOptions{})
+		require.NoError(t, err)
+
+		e, err = c.WithUser("u2").RespondToEvent(ctx, e.ID, RSVPAccepted)
+		require.NoError(t, err)
+		require.Equal(t, []Attendee{{UserID: "u2", Status: RSVPAccepted}}, e.Attendees)
+
+		_, err = c.WithUser("u3").RespondToEvent(ctx, e.ID, RSVPAccepted)
+		require.Error(t, err)
+	})
+
+	t.Run("reminders", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		input := meeting
+		input.Reminders = []Reminder{{Before: Duration(10 * time.Minute), Channel: ChannelEmail}}
+		e, err := c.CreateEvent(ctx, input, SaveOptions{})
+		require.NoError(t, err)
+		require.Equal(t, input.Reminders, e.Reminders)
+
+		input.Reminders[0].Channel = "sms"
+		_, err = c.CreateEvent(ctx, input, SaveOptions{AllowOverlap: true})
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, CodeInvalidEvent, apiErr.Code)
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		_, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+
+		ics, err := c.ExportEvents(ctx, meeting.StartAt, meeting.StartAt)
+		require.NoError(t, err)
+		require.Contains(t, string(ics), "BEGIN:VCALENDAR")
+
+		result, err := c.WithUser("u2").ImportEvents(ctx, bytes.NewReader(ics))
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Created)
+		require.Equal(t, ImportCreated, result.Entries[0].Status)
+	})
+
+	t.Run("free busy", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		_, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+
+		fb, err := c.FreeBusy(ctx, FreeBusyQuery{
+			From: meeting.StartAt, To: meeting.StartAt, Slots: 1, SlotDuration: time.Hour,
+			WorkStart: "10:00", WorkEnd: "12:00", WorkDays: []time.Weekday{time.Monday},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []Interval{{Start: meeting.StartAt, End: meeting.EndAt}}, fb.Busy)
+		require.Equal(t, []Interval{{Start: meeting.EndAt, End: meeting.EndAt.Add(time.Hour)}}, fb.Free)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		var ids []string
+		for day := 0; day < 3; day++ {
+			e := meeting
+			e.Description = "quarterly budget"
+			e.StartAt, e.EndAt = e.StartAt.AddDate(0, 0, day), e.EndAt.AddDate(0, 0, day)
+			created, err := c.CreateEvent(ctx, e, SaveOptions{})
+			require.NoError(t, err)
+			ids = append(ids, created.ID)
+		}
+
+		var found []string
+		q := SearchQuery{Text: "Budget", Limit: 2}
+		for {
+			page, err := c.SearchEvents(ctx, q)
+			require.NoError(t, err)
+			for _, e := range page.Events {
+				found = append(found, e.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		require.Equal(t, ids, found)
+
+		page, err := c.SearchEvents(ctx, SearchQuery{
+			Text: "budget meeting", OwnerID: "u1", From: meeting.StartAt.AddDate(0, 0, 1), To: meeting.StartAt.AddDate(0, 0, 1),
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Events, 1)
+		require.Equal(t, ids[1], page.Events[0].ID)
+
+		_, err = c.SearchEvents(ctx, SearchQuery{})
+		requireCode(t, err, http.StatusBadRequest, CodeInvalidQuery)
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		c := newTestClient(t, "u1")
+		first, err := c.Sync(ctx, "")
+		require.NoError(t, err)
+		require.Empty(t, first.Events)
+
+		created, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+		next, err := c.Sync(ctx, first.SyncToken)
+		require.NoError(t, err)
+		require.Len(t, next.Events, 1)
+		require.Equal(t, created.ID, next.Events[0].ID)
+
+		_, err = c.Sync(ctx, "garbage")
+		requireCode(t, err, http.StatusBadRequest, CodeInvalidSyncToken)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		c := newLimitedTestClient(t, "u1", ratelimit.New(map[ratelimit.Class]ratelimit.Limit{
+			ratelimit.ClassWrite: {Rate: 0.5, Burst: 1},
+		}))
+		_, err := c.CreateEvent(ctx, meeting, SaveOptions{})
+		require.NoError(t, err)
+
+		_, err = c.CreateEvent(ctx, meeting, SaveOptions{AllowOverlap: true})
+		requireCode(t, err, http.StatusTooManyRequests, CodeRateLimited)
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, 2*time.Second, apiErr.RetryAfter)
+
+		_, err = c.ListDayEvents(ctx, meeting.StartAt)
+		require.NoError(t, err)
+	})
+
+	t.Run("no user", func(t *testing.T) {
+		_, err := newTestClient(t, "").ListDayEvents(ctx, meeting.StartAt)
+		requireCode(t, err, http.StatusBadRequest, CodeNoUser)
+	})
+}
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func eventPath(id string) string {
+	return "/events/" + url.PathEscape(id)
+}
+
+func occurrencePath(id string, start time.Time) string {
+	return eventPath(id) + "/occurrences/" + url.PathEscape(start.Format(time.RFC3339))
+}
+
+// dayQuery returns the query parameters of a day in the location of date, which must be UTC
+// or loaded by an IANA name.
+func dayQuery(date time.Time) url.Values {
+	return url.Values{"date": {date.Format(dateLayout)}, "tz": {date.Location().String()}}
+}
+
+func rangeQuery(from, to time.Time) url.Values {
+	return url.Values{
+		"from": {from.Format(dateLayout)},
+		"to":   {to.In(from.Location()).Format(dateLayout)},
+		"tz":   {from.Location().String()},
+	}
+}
+
+func (c *Client) CreateEvent(ctx context.Context, e EventInput, opts SaveOptions) (Event, error) {
+	body, err := jsonBody(e)
+	if err != nil {
+		return Event{}, err
+	}
+	opts.IfVersion = 0
+	var saved Event
+	err = c.doJSON(ctx, request{
+		method: http.MethodPost, path: "/events", query: saveQuery(opts), header: jsonHeader(opts), body: body,
+	}, http.StatusCreated, &saved)
+	return saved, err
+}
+
+func (c *Client) GetEvent(ctx context.Context, id string) (Event, error) {
+	var e Event
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: eventPath(id)}, http.StatusOK, &e)
+	return e, err
+}
+
+func (c *Client) UpdateEvent(ctx context.Context, id string, e EventInput, opts SaveOptions) (Event, error) {
+	body, err := jsonBody(e)
+	if err != nil {
+		return Event{}, err
+	}
+	var saved Event
+	err = c.doJSON(ctx, request{
+		method: http.MethodPut, path: eventPath(id), query: saveQuery(opts), header: jsonHeader(opts), body: body,
+	}, http.StatusOK, &saved)
+	return saved, err
+}
+
+func (c *Client) DeleteEvent(ctx context.Context, id string) error {
+	return c.doJSON(ctx, request{method: http.MethodDelete, path: eventPath(id)}, http.StatusNoContent, nil)
+}
+
+// RespondToEvent sets the RSVP status of the user invited to the event.
+func (c *Client) RespondToEvent(ctx context.Context, id string, status RSVPStatus) (Event, error) {
+	body, err := jsonBody(struct {
+		Status RSVPStatus `json:"status"`
+	}{status})
+	if err != nil {
+		return Event{}, err
+	}
+	var e Event
+	err = c.doJSON(ctx, request{
+		method: http.MethodPut, path: eventPath(id) + "/rsvp", header: jsonHeader(SaveOptions{}), body: body,
+	}, http.StatusOK, &e)
+	return e, err
+}
+
+// UpdateOccurrence replaces the occurrence of the recurring event starting at start
+// with a separate event and returns it. opts.IfVersion is checked against the recurring event.
+func (c *Client) UpdateOccurrence(
+	ctx context.Context, id string, start time.Time, e EventInput, opts SaveOptions,
+) (Event, error) {
+	body, err := jsonBody(e)
+	if err != nil {
+		return Event{}, err
+	}
+	var saved Event
+	err = c.doJSON(ctx, request{
+		method: http.MethodPut, path: occurrencePath(id, start), query: saveQuery(opts), header: jsonHeader(opts),
+		body: body,
+	}, http.StatusOK, &saved)
+	return saved, err
+}
+
+// DeleteOccurrence cancels the occurrence of the recurring event starting at start.
+// A non-zero ifVersion fails it with CodeVersionMismatch unless the recurring event has that version.
+func (c *Client) DeleteOccurrence(ctx context.Context, id string, start time.Time, ifVersion int64) error {
+	return c.doJSON(ctx, request{
+		method: http.MethodDelete, path: occurrencePath(id, start), header: ifMatchHeader(http.Header{}, ifVersion),
+	}, http.StatusNoContent, nil)
+}
+
+// ListDayEvents returns events of the user on the day of date in its location,
+// which must be UTC or loaded by an IANA name. So do the other list methods.
+func (c *Client) ListDayEvents(ctx context.Context, date time.Time) ([]Event, error) {
+	return c.listEvents(ctx, "/events/day", date)
+}
+
+func (c *Client) ListWeekEvents(ctx context.Context, date time.Time) ([]Event, error) {
+	return c.listEvents(ctx, "/events/week", date)
+}
+
+func (c *Client) ListMonthEvents(ctx context.Context, date time.Time) ([]Event, error) {
+	return c.listEvents(ctx, "/events/month", date)
+}
+
+func (c *Client) listEvents(ctx context.Context, path string, date time.Time) ([]Event, error) {
+	var resp struct {
+		Events []Event `json:"events"`
+	}
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: path, query: dayQuery(date)}, http.StatusOK, &resp)
+	return resp.Events, err
+}
+
+// ExportEvents returns events of the user from the from till the to day inclusive
+// in the location of from as an iCalendar file.
+func (c *Client) ExportEvents(ctx context.Context, from, to time.Time) ([]byte, error) {
+	resp, err := c.do(ctx, request{
+		method: http.MethodGet, path: "/events/export", query: rangeQuery(from, to),
+	}, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// ImportEvents creates or updates events from the iCalendar file.
+func (c *Client) ImportEvents(ctx context.Context, ics io.Reader) (ImportResult, error) {
+	var result ImportResult
+	err := c.doJSON(ctx, request{
+		method: http.MethodPost, path: "/events/import",
+		header: http.Header{"Content-Type": {"text/calendar"}}, body: ics,
+	}, http.StatusOK, &result)
+	return result, err
+}
+
+func (c *Client) FreeBusy(ctx context.Context, q FreeBusyQuery) (FreeBusy, error) {
+	query := rangeQuery(q.From, q.To)
+	if q.Slots != 0 {
+		query.Set("slots", strconv.Itoa(q.Slots))
+	}
+	if q.SlotDuration != 0 {
+		query.Set("duration", q.SlotDuration.String())
+	}
+	if q.WorkStart != "" {
+		query.Set("work_start", q.WorkStart)
+	}
+	if q.WorkEnd != "" {
+		query.Set("work_end", q.WorkEnd)
+	}
+	if len(q.WorkDays) > 0 {
+		days := make([]string, len(q.WorkDays))
+		for i, d := range q.WorkDays {
+			days[i] = weekdayNames[d]
+		}
+		query.Set("work_days", strings.Join(days, ","))
+	}
+
+	var fb FreeBusy
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/events/freebusy", query: query}, http.StatusOK, &fb)
+	return fb, err
+}
+
+// SearchEvents returns a page of events the user owns or attends matching the query.
+func (c *Client) SearchEvents(ctx context.Context, q SearchQuery) (SearchResult, error) {
+	query := url.Values{"q": {q.Text}}
+	if !q.From.IsZero() || !q.To.IsZero() {
+		query = rangeQuery(q.From, q.To)
+		query.Set("q", q.Text)
+	}
+	if q.OwnerID != "" {
+		query.Set("owner", q.OwnerID)
+	}
+	if q.Limit != 0 {
+		query.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Cursor != "" {
+		query.Set("cursor", q.Cursor)
+	}
+
+	var result SearchResult
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/events/search", query: query}, http.StatusOK, &result)
+	return result, err
+}
+
+// Sync returns events changed since the sync that returned the token, all events of the user
+// when the token is empty. It fails with CodeSyncTokenExpired when a full sync is required.
+func (c *Client) Sync(ctx context.Context, token string) (SyncResult, error) {
+	var query url.Values
+	if token != "" {
+		query = url.Values{"sync_token": {token}}
+	}
+	var result SyncResult
+	err := c.doJSON(ctx, request{method: http.MethodGet, path: "/events/sync", query: query}, http.StatusOK, &result)
+	return result, err
+}
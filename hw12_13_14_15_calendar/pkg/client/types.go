@@ -0,0 +1,187 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration represented in JSON as a string like "1h30m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type RSVPStatus string
+
+const (
+	RSVPNeedsAction RSVPStatus = "needs-action"
+	RSVPAccepted    RSVPStatus = "accepted"
+	RSVPDeclined    RSVPStatus = "declined"
+	RSVPTentative   RSVPStatus = "tentative"
+)
+
+// Channel is a way reminders are delivered.
+type Channel string
+
+const (
+	ChannelLog     Channel = "log"
+	ChannelWebhook Channel = "webhook"
+	ChannelEmail   Channel = "email"
+)
+
+// Reminder notifies recipients of an event or each of its occurrences Before they start through the Channel.
+type Reminder struct {
+	Before  Duration `json:"before"`
+	Channel Channel  `json:"channel"`
+}
+
+// EventInput is an event to create or the new state of an event to update.
+type EventInput struct {
+	Title        string    `json:"title"`
+	StartAt      time.Time `json:"start_at"`
+	EndAt        time.Time `json:"end_at"`
+	Description  string    `json:"description,omitempty"`
+	NotifyBefore Duration  `json:"notify_before,omitempty"`
+	// TimeZone is an IANA time zone name like "Europe/Berlin", UTC by default.
+	TimeZone string `json:"time_zone,omitempty"`
+	// RRule is a recurrence rule in RFC 5545 format, e.g. "FREQ=WEEKLY;BYDAY=MO,WE".
+	RRule   string      `json:"rrule,omitempty"`
+	ExDates []time.Time `json:"exdates,omitempty"`
+	// Attendees are IDs of the invited users.
+	Attendees []string `json:"attendees,omitempty"`
+	// Reminders are sent in addition to NotifyBefore, which goes through every channel.
+	Reminders []Reminder `json:"reminders,omitempty"`
+}
+
+type Event struct {
+	ID           string      `json:"id"`
+	Title        string      `json:"title"`
+	StartAt      time.Time   `json:"start_at"`
+	EndAt        time.Time   `json:"end_at"`
+	Description  string      `json:"description"`
+	UserID       string      `json:"user_id"`
+	NotifyBefore Duration    `json:"notify_before"`
+	TimeZone     string      `json:"time_zone"`
+	RRule        string      `json:"rrule,omitempty"`
+	ExDates      []time.Time `json:"exdates,omitempty"`
+	// SeriesID and RecurrenceID link an edited occurrence to its recurring event.
+	SeriesID     string     `json:"series_id,omitempty"`
+	RecurrenceID *time.Time `json:"recurrence_id,omitempty"`
+	UID          string     `json:"uid"`
+	Attendees    []Attendee `json:"attendees,omitempty"`
+	Reminders    []Reminder `json:"reminders,omitempty"`
+	// Version is the version to pass in SaveOptions.IfVersion of an update.
+	Version int64 `json:"version"`
+	// Conflicts are IDs of the events the saved event overlaps, see SaveOptions.AllowOverlap.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+type Attendee struct {
+	UserID string     `json:"user_id"`
+	Status RSVPStatus `json:"status"`
+}
+
+// SaveOptions control how an event is created or updated.
+type SaveOptions struct {
+	// AllowOverlap saves the event even if it overlaps other events of the user
+	// and lists them in Event.Conflicts instead of failing with CodeDateBusy.
+	AllowOverlap bool
+	// IfVersion fails an update with CodeVersionMismatch unless the stored event has this version,
+	// zero matches any version. It is ignored on creation; an occurrence update checks the version
+	// of its recurring event.
+	IfVersion int64
+}
+
+// SyncResult holds events changed since the previous sync.
+type SyncResult struct {
+	Events []Event `json:"events"`
+	// Deleted are IDs of events deleted or no longer shared with the user.
+	Deleted []string `json:"deleted"`
+	// SyncToken is the token to pass to the next sync.
+	SyncToken string `json:"sync_token"`
+}
+
+// SearchQuery finds events whose title or description contain every word of Text.
+// From and To, if set, select events active from the From till the To day inclusive
+// in the location of From.
+type SearchQuery struct {
+	Text     string
+	OwnerID  string
+	From, To time.Time
+	// Limit is the maximum number of events in the page, 20 if zero.
+	Limit int
+	// Cursor is SearchResult.NextCursor of the previous page.
+	Cursor string
+}
+
+// SearchResult is a page of found events, the most relevant first.
+type SearchResult struct {
+	Events []Event `json:"events"`
+	// NextCursor continues the search, empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type ImportStatus string
+
+const (
+	ImportCreated   ImportStatus = "created"
+	ImportUpdated   ImportStatus = "updated"
+	ImportUnchanged ImportStatus = "unchanged"
+	ImportFailed    ImportStatus = "failed"
+)
+
+// ImportResult reports the outcome of importing every VEVENT of an iCalendar file.
+type ImportResult struct {
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Unchanged int           `json:"unchanged"`
+	Failed    int           `json:"failed"`
+	Entries   []ImportEntry `json:"entries"`
+}
+
+type ImportEntry struct {
+	// Line is the number of the BEGIN:VEVENT line of the entry.
+	Line         int          `json:"line"`
+	UID          string       `json:"uid"`
+	RecurrenceID *time.Time   `json:"recurrence_id,omitempty"`
+	ID           string       `json:"id,omitempty"`
+	Status       ImportStatus `json:"status"`
+	Error        string       `json:"error,omitempty"`
+}
+
+// FreeBusyQuery selects the days from From till To inclusive in the location of From.
+// Unset working hours default to 09:00-18:00 from Monday till Friday.
+type FreeBusyQuery struct {
+	From, To time.Time
+	// Slots is the number of free slots of SlotDuration to suggest.
+	Slots        int
+	SlotDuration time.Duration
+	// WorkStart and WorkEnd are wall clock times in HH:MM format, 24:00 being the end of the day.
+	WorkStart, WorkEnd string
+	WorkDays           []time.Weekday
+}
+
+type FreeBusy struct {
+	Busy []Interval `json:"busy"`
+	Free []Interval `json:"free"`
+}
+
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}